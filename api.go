@@ -0,0 +1,843 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Kinds of chaincode functions
+const (
+	kindInvoke = "invoke"
+	kindQuery  = "query"
+)
+
+// accessPolicy is the access check of a function when access control is on. Role describes
+// who may call it; check is nil when anybody may.
+type accessPolicy struct {
+	Role  string
+	check func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error)
+}
+
+// Access policies of the functions. The contract ID is always the first argument.
+var (
+	policyAny         = accessPolicy{Role: "Any"}
+	policyFiltered    = accessPolicy{Role: "Any, only the caller's contracts are listed"}
+	policyAdmin       = accessPolicy{Role: "Admin, the deployer of the chaincode", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		return t.isCallerAdmin(stub)
+	}}
+	policyParticipant = accessPolicy{Role: "Participant", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		return t.isCallerParticipant(stub, args[:1])
+	}}
+	policyImporterBank = accessPolicy{Role: "ImporterBank", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		return t.isCallerImporterBank(stub, args[:1])
+	}}
+	policyExporterBank = accessPolicy{Role: "ExporterBank", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		return t.isCallerExporterBank(stub, args[:1])
+	}}
+	// The caller must hold the role given as second argument
+	policyConsentingRole = accessPolicy{Role: "ImporterBank, Exporter or ExporterBank, as given in the role argument", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		if args[1] == "ImporterBank" {
+			return t.isCallerImporterBank(stub, args[:1])
+		} else if args[1] == "Exporter" {
+			return t.isCallerExporter(stub, args[:1])
+		} else if args[1] == "ExporterBank" {
+			return t.isCallerExporterBank(stub, args[:1])
+		}
+		return false, nil
+	}}
+)
+
+// Contract checks made before calling a function on the contract given as first argument
+const (
+	contractNone   = iota
+	contractExists // the contract must exist
+	contractOpen   // the contract must exist and be neither cancelled nor closed
+)
+
+// apiFunction is a function of the chaincode. The registry of these is the source of truth
+// for dispatch, argument schemas, access checks, describeAPI and the OpenAPI document.
+type apiFunction struct {
+	Name        string
+	Kind        string
+	Description string
+	Args        argsSchema
+	Contract    int
+	Policy      accessPolicy
+	Errors      []string
+	Result      interface{} // zero value of the result type, nil if there is none
+	Handler     func(t *SBI, stub shim.ChaincodeStubInterface, args []string) ([]byte, error)
+}
+
+// documentContent is the raw content of a stored document
+type documentContent string
+
+var (
+	contractIDArg      = argField{Name: "contractID", Type: argString, Required: true, Description: "ID of the contract"}
+	includeClosedField = argField{Name: "includeClosed", Type: argBoolean, Description: "List cancelled and closed contracts too"}
+	docTypeArg         = argField{Name: "docType", Type: argString, Required: true, Description: "BL, INVOICE, PACKINGLIST, CERT_OF_ORIGIN, INSURANCE_CERT, INSPECTION_CERT or BENEFICIARY_STATEMENT"}
+	poArg              = argField{Name: "po", Type: argJSON, Required: true, Description: "Purchase order with the MT700 fields of the LC, as JSON, as an MT700 FIN message or as an ISO 20022 baseline"}
+)
+
+// Errors every function may return
+var commonErrors = []string{codeInvalidArgs, codeInternal}
+
+// apiFunctions is the registry of the functions of the chaincode, in registration order
+var apiFunctions []apiFunction
+
+// registerFunction adds a function to the registry
+func registerFunction(fn apiFunction) {
+	if _, ok := lookupAPI(fn.Kind, fn.Name); ok {
+		panic("Function " + fn.Kind + " " + fn.Name + " registered twice")
+	}
+	apiFunctions = append(apiFunctions, fn)
+}
+
+func init() {
+	for _, fn := range []apiFunction{
+		{
+			Name:        "initTrade",
+			Kind:        kindInvoke,
+			Description: "Starts a trade with its purchase order and participants and returns its contract ID. Trades naming a party or port of the sanctions list are put on COMPLIANCE_HOLD.",
+			Args: argsSchema{
+				{Name: "contractID", Type: argString, Description: "ID of the contract, allocated from the importer bank name and the year if empty"},
+				poArg,
+				{Name: "importerName", Type: argString, Required: true},
+				{Name: "exporterName", Type: argString, Required: true},
+				{Name: "importerBankName", Type: argString, Required: true},
+				{Name: "exporterBankName", Type: argString, Required: true},
+				{Name: "importerCert", Type: argString, Required: true, Description: "Certificate of the importer"},
+				{Name: "exporterCert", Type: argString, Required: true, Description: "Certificate of the exporter"},
+				{Name: "importerBankCert", Type: argString, Required: true, Description: "Certificate of the importer bank"},
+				{Name: "exporterBankCert", Type: argString, Required: true, Description: "Certificate of the exporter bank"},
+				{Name: "confirmingBankName", Type: argString, Description: "Bank asked to confirm the LC as instructed by Tag49"},
+				{Name: "confirmingBankCert", Type: argString, Description: "Certificate of the confirming bank, required with its name"},
+				{Name: "reimbursingBankName", Type: argString, Description: "Reimbursing bank paying the claims of the exporter bank"},
+				{Name: "reimbursingBankCert", Type: argString, Description: "Certificate of the reimbursing bank, required with its name"},
+			},
+			Contract: contractNone,
+			Policy:   policyAny,
+			Errors:   []string{codeDuplicate},
+			Result:   TradeInitiated{},
+			Handler:  (*SBI).initTrade,
+		},
+		{
+			Name:        "bulkInitTrades",
+			Kind:        kindInvoke,
+			Description: "Imports a batch of in-flight trades with their current documents and statuses. Nothing is imported unless every trade is valid.",
+			Args: argsSchema{
+				{Name: "trades", Type: argArray, Required: true, Description: "Trades to import, contract IDs are allocated for the trades without one", Items: HistoricalTrade{}},
+			},
+			Contract: contractNone,
+			Policy:   policyAdmin,
+			Errors:   []string{codeAccessDenied, codeValidationFailed},
+			Result:   BulkImportResult{},
+			Handler:  (*SBI).bulkInitTrades,
+		},
+		{
+			Name:        "updatePO",
+			Kind:        kindInvoke,
+			Description: "Amends the purchase order",
+			Args:        argsSchema{contractIDArg, poArg},
+			Contract:    contractOpen,
+			Policy:      policyImporterBank,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeInvalidTransition},
+			Handler:     (*SBI).updatePO,
+		},
+		{
+			Name:        "submitED",
+			Kind:        kindInvoke,
+			Description: "Submits the export documents",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "blPDF", Type: argString, Description: "Bill of lading"},
+				{Name: "invoicePDF", Type: argString, Description: "Commercial invoice"},
+				{Name: "packingListPDF", Type: argString, Description: "Packing list"},
+				{Name: "invoiceUBL", Type: argString, Description: "Commercial invoice as UBL 2.1 XML, checked against the core rules"},
+				{Name: "signatures", Type: argArray, Description: "Detached signatures of the documents by their issuers, checked against the documents. Issuers must be participants of the contract or registered parties.", Items: SignedDocument{}},
+			},
+			Contract: contractOpen,
+			Policy:   policyExporterBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate, codeValidationFailed},
+			Handler:  (*SBI).submitED,
+		},
+		{
+			Name:        "submitDocument",
+			Kind:        kindInvoke,
+			Description: "Submits an export document of any registered type, until the importer bank has examined the documents",
+			Args: argsSchema{
+				contractIDArg,
+				docTypeArg,
+				{Name: "content", Type: argString, Required: true, Description: "Document content"},
+				{Name: "signature", Type: argString, Description: "Detached signature of the content by the issuer, base64"},
+				{Name: "signerCert", Type: argString, Description: "Certificate of the issuer, required with a signature"},
+			},
+			Contract: contractOpen,
+			Policy:   policyExporterBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate, codeValidationFailed},
+			Handler:  (*SBI).submitDocument,
+		},
+		{
+			Name:        "submitEDAsISO20022",
+			Kind:        kindInvoke,
+			Description: "Submits the export documents presented in an ISO 20022 data set submission (tsmt.014). The documents are base64 in its supplementary data.",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "message", Type: argString, Required: true, Description: "ISO 20022 XML message"},
+			},
+			Contract: contractOpen,
+			Policy:   policyExporterBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate},
+			Handler:  (*SBI).submitEDAsISO20022,
+		},
+		{
+			Name:        "acceptED",
+			Kind:        kindInvoke,
+			Description: "Accepts the export documents once every document required by Tag46A was submitted, then moves the payment forward",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractOpen,
+			Policy:      policyHonouringBank,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeValidationFailed},
+			Handler:     (*SBI).acceptED,
+		},
+		{
+			Name:        "rejectED",
+			Kind:        kindInvoke,
+			Description: "Rejects the export documents",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractOpen,
+			Policy:      policyHonouringBank,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeInvalidTransition},
+			Handler:     (*SBI).rejectED,
+		},
+		{
+			Name:        "cancelTrade",
+			Kind:        kindInvoke,
+			Description: "Records the consent of a party to cancel the trade, cancelling it once enough parties agree",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "role", Type: argString, Required: true, Description: "ImporterBank, Exporter or ExporterBank"},
+			},
+			Contract: contractOpen,
+			Policy:   policyConsentingRole,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate},
+			Handler:  (*SBI).cancelTrade,
+		},
+		{
+			Name:        "closeTrade",
+			Kind:        kindInvoke,
+			Description: "Closes a settled trade",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractOpen,
+			Policy:      policyImporterBank,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeInvalidTransition},
+			Handler:     (*SBI).closeTrade,
+		},
+		{
+			Name:        "confirmLC",
+			Kind:        kindInvoke,
+			Description: "Adds the undertaking of the confirming bank to the LC, as allowed by Tag49, and records its fee",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "fee", Type: argString, Required: true, Description: "Confirmation fee, currency and amount as in Tag32B"},
+				{Name: "chargedTo", Type: argString, Description: "APPLICANT or BENEFICIARY, BENEFICIARY if empty"},
+			},
+			Contract: contractOpen,
+			Policy:   policyConfirmingBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate},
+			Result:   Confirmation{},
+			Handler:  (*SBI).confirmLC,
+		},
+		{
+			Name:        "authoriseReimbursement",
+			Kind:        kindInvoke,
+			Description: "Authorises the reimbursing bank of the contract, identified by Tag53A, to honour the claims of the exporter bank up to the LC amount (MT740)",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "chargesFor", Type: argString, Description: "CLM or OUR, who bears the reimbursing charges. CLM if empty"},
+			},
+			Contract: contractOpen,
+			Policy:   policyImporterBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate, codeValidationFailed},
+			Result:   ReimbursementAuthorisation{},
+			Handler:  (*SBI).authoriseReimbursement,
+		},
+		{
+			Name:        "claimReimbursement",
+			Kind:        kindInvoke,
+			Description: "Claims the payment of the accepted export documents from the reimbursing bank (MT742)",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "amount", Type: argString, Required: true, Description: "Amount claimed, currency and amount as in Tag32B"},
+			},
+			Contract: contractOpen,
+			Policy:   policyExporterBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeValidationFailed},
+			Result:   ReimbursementClaim{},
+			Handler:  (*SBI).claimReimbursement,
+		},
+		{
+			Name:        "updateSanctionsList",
+			Kind:        kindInvoke,
+			Description: "Replaces the watch list of restricted parties, vessels and ports that trades are screened against",
+			Args: argsSchema{
+				{Name: "entries", Type: argArray, Required: true, Description: "Watch list entries", Items: WatchListEntry{}},
+			},
+			Contract: contractNone,
+			Policy:   policyComplianceAdmin,
+			Errors:   []string{codeAccessDenied, codeValidationFailed},
+			Result:   SanctionsList{},
+			Handler:  (*SBI).updateSanctionsList,
+		},
+		{
+			Name:        "releaseComplianceHold",
+			Kind:        kindInvoke,
+			Description: "Clears the watch list hits of a trade on COMPLIANCE_HOLD and lets it proceed",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "reason", Type: argString, Required: true, Description: "Why the hits are false positives or otherwise cleared"},
+			},
+			Contract: contractExists,
+			Policy:   policyComplianceAdmin,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition},
+			Handler:  (*SBI).releaseComplianceHold,
+		},
+		{
+			Name:        "registerParty",
+			Kind:        kindInvoke,
+			Description: "Registers the caller as a party that can hold electronic BLs",
+			Args: argsSchema{
+				{Name: "partyID", Type: argString, Required: true, Description: "ID of the party"},
+				{Name: "name", Type: argString, Required: true},
+				{Name: "cert", Type: argString, Required: true, Description: "Certificate of the party, the caller's"},
+			},
+			Contract: contractNone,
+			Policy:   policyCertHolder,
+			Errors:   []string{codeAccessDenied, codeDuplicate},
+			Handler:  (*SBI).registerParty,
+		},
+		{
+			Name:        "issueEBL",
+			Kind:        kindInvoke,
+			Description: "Turns the submitted BL into an electronic BL held by a registered party",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "holder", Type: argString, Required: true, Description: "ID of the registered party holding the title"},
+				{Name: "toOrder", Type: argBoolean, Description: "Consigned to order rather than to a named consignee"},
+				{Name: "negotiable", Type: argBoolean, Description: "Title can be transferred by endorsement, only for to-order BLs"},
+			},
+			Contract: contractOpen,
+			Policy:   policyExporterBank,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition, codeDuplicate},
+			Handler:  (*SBI).issueEBL,
+		},
+		{
+			Name:        "endorseBL",
+			Kind:        kindInvoke,
+			Description: "Transfers the title of a negotiable electronic BL to another registered party, also once the contract is closed or on compliance hold",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "endorsee", Type: argString, Required: true, Description: "ID of the registered party receiving the title"},
+			},
+			Contract: contractExists,
+			Policy:   policyBLHolder,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeInvalidTransition},
+			Handler:  (*SBI).endorseBL,
+		},
+		{
+			Name:        "surrenderBL",
+			Kind:        kindInvoke,
+			Description: "Surrenders the electronic BL to the carrier for delivery of the goods, also once the contract is settled, closed or on compliance hold",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyBLHolder,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeInvalidTransition},
+			Handler:     (*SBI).surrenderBL,
+		},
+		{
+			Name:        "migrateTables",
+			Kind:        kindInvoke,
+			Description: "Copies the rows of the legacy tables into the key-value layout. Init already runs it as schema migration 1.",
+			Args:        argsSchema{},
+			Contract:    contractNone,
+			Policy:      policyAdmin,
+			Errors:      []string{codeAccessDenied},
+			Result:      MigrationResult{},
+			Handler:     (*SBI).migrateTables,
+		},
+		{
+			Name:        "getED",
+			Kind:        kindQuery,
+			Description: "Returns an export document",
+			Args: argsSchema{
+				contractIDArg,
+				docTypeArg,
+				{Name: "format", Type: argString, Description: "PDF, the default, or UBL for the original XML of an invoice submitted as UBL"},
+			},
+			Contract: contractExists,
+			Policy:   policyParticipant,
+			Errors:   []string{codeNotFound, codeAccessDenied},
+			Result:   documentContent(""),
+			Handler:  (*SBI).getED,
+		},
+		{
+			Name:        "getInvoice",
+			Kind:        kindQuery,
+			Description: "Returns the totals, currency, parties and line items of an invoice submitted as UBL",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      InvoiceData{},
+			Handler:     (*SBI).getInvoice,
+		},
+		{
+			Name:        "getReimbursement",
+			Kind:        kindQuery,
+			Description: "Returns the reimbursement authorisation of the contract, the claims made on it and the amount left",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      Reimbursement{},
+			Handler:     (*SBI).getReimbursement,
+		},
+		{
+			Name:        "getSettlementEntries",
+			Kind:        kindQuery,
+			Description: "Returns the transfers between the banks paying the export documents, with their status following the payment",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      SettlementList{},
+			Handler:     (*SBI).getSettlementEntries,
+		},
+		{
+			Name:        "getComplianceHold",
+			Kind:        kindQuery,
+			Description: "Returns the watch list hits holding the contract and those cleared by a release",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      ComplianceHold{},
+			Handler:     (*SBI).getComplianceHold,
+		},
+		{
+			Name:        "verifyDocumentSignature",
+			Kind:        kindQuery,
+			Description: "Checks the detached signature of an export document against the document on the ledger and returns the signer",
+			Args:        argsSchema{contractIDArg, docTypeArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeInvalidArgs},
+			Result:      SignatureVerification{},
+			Handler:     (*SBI).verifyDocumentSignature,
+		},
+		{
+			Name:        "getPresentationChecklist",
+			Kind:        kindQuery,
+			Description: "Returns the documents required by Tag46A of the PO with their originals and copies, whether they were submitted and whether they block acceptED",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      PresentationChecklist{},
+			Handler:     (*SBI).getPresentationChecklist,
+		},
+		{
+			Name:        "getBLTitleChain",
+			Kind:        kindQuery,
+			Description: "Returns the holder of the electronic BL and its title chain with the signatures of every transfer",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      EBLTitle{},
+			Handler:     (*SBI).getBLTitleChain,
+		},
+		{
+			Name:        "getPO",
+			Kind:        kindQuery,
+			Description: "Returns the purchase order",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      LC{},
+			Handler:     (*SBI).getPO,
+		},
+		{
+			Name:        "getPOAsMT700",
+			Kind:        kindQuery,
+			Description: "Returns the purchase order as an MT700 FIN message. A PO with fields outside the SWIFT X character set or the lines of their format can not be rendered.",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied, codeValidationFailed},
+			Result:      finMessage(""),
+			Handler:     (*SBI).getPOAsMT700,
+		},
+		{
+			Name:        "getISO20022Message",
+			Kind:        kindQuery,
+			Description: "Returns the issuance (tsmt.019) with the PO as issued, the latest amendment (tsmt.009) or the presentation (tsmt.014) of the contract as an ISO 20022 message",
+			Args: argsSchema{
+				contractIDArg,
+				{Name: "event", Type: argString, Required: true, Description: "issuance, amendment or presentation"},
+			},
+			Contract: contractExists,
+			Policy:   policyParticipant,
+			Errors:   []string{codeNotFound, codeAccessDenied, codeValidationFailed},
+			Result:   isoMessage(""),
+			Handler:  (*SBI).getISO20022Message,
+		},
+		{
+			Name:        "getEDStatus",
+			Kind:        kindQuery,
+			Description: "Returns the status of the export documents",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      DocumentStatus{},
+			Handler:     (*SBI).getEDStatus,
+		},
+		{
+			Name:        "getNumContracts",
+			Kind:        kindQuery,
+			Description: "Counts the contracts",
+			Args:        argsSchema{},
+			Contract:    contractNone,
+			Policy:      policyAny,
+			Result:      ContractCount{},
+			Handler:     (*SBI).getNumContracts,
+		},
+		{
+			Name:        "getSchemaVersion",
+			Kind:        kindQuery,
+			Description: "Returns the schema version of the ledger and the latest version known to the chaincode",
+			Args:        argsSchema{},
+			Contract:    contractNone,
+			Policy:      policyAny,
+			Result:      SchemaStatus{},
+			Handler:     (*SBI).getSchemaVersion,
+		},
+		{
+			Name:        "listContracts",
+			Kind:        kindQuery,
+			Description: "Lists the contracts",
+			Args:        argsSchema{includeClosedField},
+			Contract:    contractNone,
+			Policy:      policyFiltered,
+			Result:      ContractsList{},
+			Handler:     (*SBI).listContracts,
+		},
+		{
+			Name:        "listContractsByRole",
+			Kind:        kindQuery,
+			Description: "Lists the contracts where the caller has the given role",
+			Args: argsSchema{
+				{Name: "role", Type: argString, Required: true, Description: "Importer, Exporter, ImporterBank, ExporterBank, ConfirmingBank or ReimbursingBank"},
+				includeClosedField,
+			},
+			Contract: contractNone,
+			Policy:   policyFiltered,
+			Result:   ContractsList{},
+			Handler:  (*SBI).listContractsByRole,
+		},
+		{
+			Name:        "listContractsByStatus",
+			Kind:        kindQuery,
+			Description: "Lists the contracts in the given business process status",
+			Args: argsSchema{
+				{Name: "status", Type: argString, Required: true, Description: "Business process status"},
+			},
+			Contract: contractNone,
+			Policy:   policyFiltered,
+			Result:   ContractsList{},
+			Handler:  (*SBI).listContractsByStatus,
+		},
+		{
+			Name:        "listEDsByStatus",
+			Kind:        kindQuery,
+			Description: "Lists the contracts whose export documents are in the given status",
+			Args: argsSchema{
+				{Name: "status", Type: argString, Required: true, Description: "Export document status"},
+				includeClosedField,
+			},
+			Contract: contractNone,
+			Policy:   policyFiltered,
+			Result:   ContractsList{},
+			Handler:  (*SBI).listEDsByStatus,
+		},
+		{
+			Name:        "getContractParticipants",
+			Kind:        kindQuery,
+			Description: "Returns the participants of the contract with their roles",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      []Participant{},
+			Handler:     (*SBI).getContractParticipants,
+		},
+		{
+			Name:        "getContractSummary",
+			Kind:        kindQuery,
+			Description: "Returns the participants, key PO terms and document statuses of the contract",
+			Args:        argsSchema{contractIDArg},
+			Contract:    contractExists,
+			Policy:      policyParticipant,
+			Errors:      []string{codeNotFound, codeAccessDenied},
+			Result:      ContractSummary{},
+			Handler:     (*SBI).getContractSummary,
+		},
+		{
+			Name:        "listContractSummaries",
+			Kind:        kindQuery,
+			Description: "Returns the summary of every contract",
+			Args:        argsSchema{includeClosedField},
+			Contract:    contractNone,
+			Policy:      policyFiltered,
+			Result:      ContractSummaryList{},
+			Handler:     (*SBI).listContractSummaries,
+		},
+		{
+			Name:        "describeAPI",
+			Kind:        kindQuery,
+			Description: "Describes the functions of the chaincode",
+			Args:        argsSchema{},
+			Contract:    contractNone,
+			Policy:      policyAny,
+			Result:      APIDescription{},
+			Handler:     (*SBI).describeAPI,
+		},
+	} {
+		registerFunction(fn)
+	}
+}
+
+// lookupAPI returns the description of the function of the given kind
+func lookupAPI(kind string, name string) (apiFunction, bool) {
+	for _, fn := range apiFunctions {
+		if fn.Kind == kind && fn.Name == name {
+			return fn, true
+		}
+	}
+	return apiFunction{}, false
+}
+
+// jsonSchema is the subset of JSON Schema used to describe arguments and results
+type jsonSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// FunctionDescription is the description of a function returned by describeAPI
+type FunctionDescription struct {
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind"`
+	Description string      `json:"description"`
+	Args        *jsonSchema `json:"args"`
+	Role        string      `json:"role"`
+	Errors      []string    `json:"errors"`
+	Result      *jsonSchema `json:"result,omitempty"`
+}
+
+// APIDescription is the result of describeAPI
+type APIDescription struct {
+	Functions []FunctionDescription `json:"functions"`
+}
+
+// argsJSONSchema describes the JSON request object of a function
+func argsJSONSchema(args argsSchema) *jsonSchema {
+	closed := false
+	schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}, AdditionalProperties: &closed}
+	for _, field := range args {
+		property := &jsonSchema{Type: field.Type, Description: field.Description}
+		if field.Type == argJSON {
+			property.Type = "object"
+		}
+		if field.Items != nil {
+			property.Items = typeJSONSchema(reflect.TypeOf(field.Items))
+		}
+		schema.Properties[field.Name] = property
+		if field.Required {
+			schema.Required = append(schema.Required, field.Name)
+		}
+	}
+	return schema
+}
+
+// typeJSONSchema describes the JSON encoding of a Go type
+func typeJSONSchema(t reflect.Type) *jsonSchema {
+	return typeJSONSchemaOf(t, map[reflect.Type]bool{})
+}
+
+// typeJSONSchemaOf describes t. Structs already being described, such as jsonSchema itself,
+// are left as plain objects.
+func typeJSONSchemaOf(t reflect.Type, seen map[reflect.Type]bool) *jsonSchema {
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Ptr:
+		return typeJSONSchemaOf(t.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if t == reflect.TypeOf(json.RawMessage{}) {
+			return &jsonSchema{Type: "object"}
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string", Description: "base64"}
+		}
+		return &jsonSchema{Type: "array", Items: typeJSONSchemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return &jsonSchema{Type: "object"}
+	case reflect.Struct:
+		if seen[t] {
+			return &jsonSchema{Type: "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			if tag := field.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if parts := strings.Split(tag, ","); parts[0] != "" {
+					name = parts[0]
+				}
+			}
+			schema.Properties[name] = typeJSONSchemaOf(field.Type, seen)
+		}
+		return schema
+	}
+	return &jsonSchema{}
+}
+
+// describe returns the description of the function
+func (fn apiFunction) describe() FunctionDescription {
+	d := FunctionDescription{
+		Name:        fn.Name,
+		Kind:        fn.Kind,
+		Description: fn.Description,
+		Args:        argsJSONSchema(fn.requestSchema()),
+		Role:        fn.Policy.Role,
+		Errors:      append(append([]string{}, fn.Errors...), commonErrors...),
+	}
+
+	if fn.Kind == kindInvoke && !containsString(fn.Errors, codeDuplicate) {
+		// A request ID reused for a different request
+		d.Errors = append(d.Errors, codeDuplicate)
+	}
+
+	if fn.Result != nil {
+		d.Result = typeJSONSchema(reflect.TypeOf(fn.Result))
+		switch fn.Result.(type) {
+		case documentContent:
+			d.Result.Description = "Document content as submitted"
+		case finMessage:
+			d.Result.Description = "SWIFT FIN message with CRLF line ends"
+		case isoMessage:
+			d.Result.Description = "ISO 20022 XML message"
+		}
+	}
+
+	return d
+}
+
+// requestSchema lists the fields of a JSON request of the function. Invokes also accept a request ID.
+func (fn apiFunction) requestSchema() argsSchema {
+	if fn.Kind != kindInvoke {
+		return fn.Args
+	}
+
+	return append(append(argsSchema{}, fn.Args...), requestIDField)
+}
+
+// describeAPI returns the description of every function
+func (t *SBI) describeAPI(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var api APIDescription
+	for _, fn := range apiFunctions {
+		api.Functions = append(api.Functions, fn.describe())
+	}
+	return json.Marshal(api)
+}
+
+// openAPIDocument returns an OpenAPI 3 document describing every function as a POST operation
+// taking the JSON request object
+func openAPIDocument() map[string]interface{} {
+	errorSchema := typeJSONSchema(reflect.TypeOf(ChaincodeError{}))
+
+	paths := map[string]interface{}{}
+	for _, fn := range apiFunctions {
+		d := fn.describe()
+
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{"description": "Success"},
+			"default": map[string]interface{}{
+				"description": "Error, one of " + strings.Join(d.Errors, ", "),
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorSchema}},
+			},
+		}
+		if d.Result != nil {
+			responses["200"] = map[string]interface{}{
+				"description": "Success",
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": d.Result}},
+			}
+		}
+
+		paths["/"+fn.Kind+"/"+fn.Name] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": fn.Name,
+				"summary":     d.Description,
+				"description": "Required role: " + d.Role,
+				"tags":        []string{fn.Kind},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": d.Args}},
+				},
+				"responses": responses,
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "SBI trade finance chaincode",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
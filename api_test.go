@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Regenerate openapi.json with: go test -run TestOpenAPIDocument -update
+var update = flag.Bool("update", false, "update the generated API documents")
+
+const openAPIFile = "openapi.json"
+
+func TestOpenAPIDocument(t *testing.T) {
+	b, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, '\n')
+
+	if *update {
+		if err := ioutil.WriteFile(openAPIFile, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := ioutil.ReadFile(openAPIFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(golden, b) {
+		t.Fatalf("%s is out of date, regenerate it with go test -run TestOpenAPIDocument -update", openAPIFile)
+	}
+}
+
+func TestDescribeAPI(t *testing.T) {
+	stub := newMockStub()
+
+	var api APIDescription
+	b, err := stub.query(nil, "describeAPI")
+	if err = json.Unmarshal(b, &api); err != nil || len(api.Functions) != len(apiFunctions) {
+		t.Fatalf("describeAPI: %s %v", b, err)
+	}
+
+	for _, fn := range api.Functions {
+		if fn.Name == "initTrade" && (len(fn.Args.Required) != 9 || fn.Args.Required[0] != "po") {
+			t.Errorf("initTrade arguments: %v", fn.Args.Required)
+		}
+		if fn.Name == "getContractSummary" && fn.Result.Properties["participants"].Type != "array" {
+			t.Errorf("getContractSummary result: %v", fn.Result)
+		}
+	}
+
+	// Every described function is served
+	for _, fn := range apiFunctions {
+		if fn.Kind == kindInvoke {
+			_, err = stub.invoke(nil, fn.Name)
+		} else {
+			_, err = stub.query(nil, fn.Name)
+		}
+		if err != nil && err.Error() == newError(codeInvalidArgs, "Invalid %s function name.", fn.Kind).Error() {
+			t.Errorf("%s %s is described but not served", fn.Kind, fn.Name)
+		}
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	saved := apiFunctions
+	defer func() { apiFunctions = saved }()
+	apiFunctions = append([]apiFunction(nil), saved...)
+
+	registerFunction(apiFunction{
+		Name:     "echo",
+		Kind:     kindQuery,
+		Args:     argsSchema{contractIDArg, {Name: "text", Type: argString, Required: true}},
+		Contract: contractExists,
+		Policy:   policyParticipant,
+		Handler: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return []byte(args[1]), nil
+		},
+	})
+
+	stub := newMockStub()
+	if err := initTrade(stub, nil, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := stub.query(nil, "echo", `{"contractID": "1000", "text": "hi"}`); err != nil || string(b) != "hi" {
+		t.Fatalf("echo: %s %v", b, err)
+	}
+	if _, err := stub.query(nil, "echo", "1000"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("echo without text: %v", err)
+	}
+	if _, err := stub.query(nil, "echo", "9999", "hi"); errorCode(err) != codeNotFound {
+		t.Fatalf("echo on an unknown contract: %v", err)
+	}
+	if _, err := stub.invoke(nil, "echo", "1000", "hi"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("echo is not an invoke: %v", err)
+	}
+
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+	if _, err := stub.query([]byte(`OCert`), "echo", "1000", "hi"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("echo by an outsider: %v", err)
+	}
+	if b, err := stub.query([]byte(`ECert`), "echo", "1000", "hi"); err != nil || string(b) != "hi" {
+		t.Fatalf("echo by the exporter: %s %v", b, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering echo twice must panic")
+		}
+	}()
+	registerFunction(apiFunction{Name: "echo", Kind: kindQuery})
+}
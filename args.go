@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Types of the fields of a JSON request
+const (
+	argString  = "string"
+	argBoolean = "boolean"
+	argJSON    = "json"  // a JSON object, or a string holding one
+	argArray   = "array" // a JSON array, or a string holding one
+)
+
+// argField is a named argument of a function. Its position in the schema is its
+// position in the positional form.
+type argField struct {
+	Name        string
+	Type        string
+	Required    bool
+	Description string
+	Items       interface{} // value of the JSON encoding of the elements of an argArray field
+}
+
+// argsSchema lists the arguments of a function in positional order
+type argsSchema []argField
+
+// isJSONRequest reports whether args hold a single JSON request object rather than positional arguments
+func isJSONRequest(args []string) bool {
+	return len(args) == 1 && strings.HasPrefix(strings.TrimSpace(args[0]), "{")
+}
+
+// positionalArgs converts a JSON request object to the positional arguments of function.
+// Positional arguments are returned unchanged.
+func positionalArgs(function string, schema argsSchema, args []string) ([]string, error) {
+	if !isJSONRequest(args) {
+		return args, nil
+	}
+
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(args[0]), &fields)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Request of %s is not a valid JSON object: %s", function, err.Error())
+	}
+
+	var problems []string
+
+	known := make(map[string]bool)
+	for _, field := range schema {
+		known[field.Name] = true
+	}
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, "unknown field \""+name+"\"")
+	}
+
+	values := make([]string, len(schema))
+	for i, field := range schema {
+		raw, ok := fields[field.Name]
+		if !ok || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if field.Required {
+				problems = append(problems, "missing field \""+field.Name+"\"")
+			}
+			continue
+		}
+
+		value, ok := fieldValue(field.Type, raw)
+		if !ok {
+			problems = append(problems, "field \""+field.Name+"\" should be a "+field.Type)
+			continue
+		}
+		values[i] = value
+	}
+
+	if len(problems) != 0 {
+		return nil, newError(codeInvalidArgs, "Invalid request for %s: %s.", function, strings.Join(problems, "; "))
+	}
+
+	// Missing optional fields are empty
+	return values, nil
+}
+
+// checkArity checks the number of positional arguments against the schema. Optional fields
+// followed by a required field still take their position, so they count as required.
+func (schema argsSchema) checkArity(args []string) error {
+	required := 0
+	for i, field := range schema {
+		if field.Required {
+			required = i + 1
+		}
+	}
+
+	if len(args) < required || len(args) > len(schema) {
+		if required == len(schema) {
+			return newError(codeInvalidArgs, "Incorrect number of arguments. Expecting %d.", required)
+		}
+		return newError(codeInvalidArgs, "Incorrect number of arguments. Expecting %d to %d.", required, len(schema))
+	}
+
+	return nil
+}
+
+// fieldValue converts a JSON value of the given type to its positional form
+func fieldValue(fieldType string, raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, fieldType == argString || fieldType == argJSON || fieldType == argArray
+	}
+
+	if fieldType == argBoolean {
+		var b bool
+		if json.Unmarshal(raw, &b) != nil {
+			return "", false
+		}
+		if b {
+			return "true", true
+		}
+		return "false", true
+	}
+
+	if fieldType == argJSON {
+		var obj map[string]interface{}
+		if json.Unmarshal(raw, &obj) != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+
+	if fieldType == argArray {
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+
+	return "", false
+}
+
+// boolArg parses the optional flag named name at position i, false if missing or empty
+func boolArg(args []string, i int, name string) (bool, error) {
+	if len(args) <= i || args[i] == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(args[i])
+	if err != nil {
+		return false, newError(codeInvalidArgs, "%s should be true or false.", name)
+	}
+
+	return b, nil
+}
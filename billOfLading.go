@@ -2,8 +2,6 @@ package main
 
 import (
 	//"encoding/json"
-	"errors"
-	"fmt"
 	//"regexp"
 	//"strconv"
 	//"strings"
@@ -65,27 +63,7 @@ BLPDF []byte
 
 //Init initializes the document smart contract
 func (t *BL) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	// Check if table already exists
-	_, err := stub.GetTable("BLTable")
-	if err == nil {
-		// Table already exists; do not recreate
-		return nil, nil
-	}
-
-	// Create L/C Table
-	err = stub.CreateTable("BLTable", []*shim.ColumnDefinition{
-		&shim.ColumnDefinition{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "UID", Type: shim.ColumnDefinition_STRING, Key: true},
-		//&shim.ColumnDefinition{Name: "DocJSON", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "DocPDF", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "Status", Type: shim.ColumnDefinition_STRING, Key: false},
-	})
-	if err != nil {
-		return nil, errors.New("Failed creating BLTable.")
-	}
-
 	return nil, nil
-
 }
 
 
@@ -96,63 +74,44 @@ func (t *BL) Init(stub shim.ChaincodeStubInterface, function string, args []stri
 func (t *BL) SubmitDoc(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 2.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
 	}
 
 	UID := args[0]
 	docPDF := []byte(args[1])
 
-
 	// Insert a row
-	ok, err := stub.InsertRow("BLTable", shim.Row{
-		Columns: []*shim.Column{
-			&shim.Column{Value: &shim.Column_String_{String_: "DOC"}},
-			&shim.Column{Value: &shim.Column_String_{String_: UID}},
-			&shim.Column{Value: &shim.Column_Bytes{Bytes: docPDF}},
-			&shim.Column{Value: &shim.Column_String_{String_: "SUBMITTED_BY_EB"}}},
-	})
+	ok, err := blStore.Insert(stub, &Document{UID: UID, Content: docPDF, Status: "SUBMITTED_BY_EB"})
 
 	if !ok && err == nil {
-		return nil, errors.New("Document already exists.")
+		return nil, newError(codeDuplicate, "Document already exists.")
 	}
 
-	if err != nil {
-		return nil, err
-	}
-
-	return nil, nil
+	return nil, err
 }
 
 //UpdateStatus () – Updates current document Status. Enforces Status transition logic.
 func (t *BL) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 2.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
 	}
 
 	UID := args[0]
 	newStatus := args[1]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("BLTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := blStore.Get(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Bill of lading of contract %s has not been submitted.", UID)
 	}
 
-	
-	docPDF := row.Columns[2].GetBytes()
-	currStatus := row.Columns[3].GetString_()
+	currStatus := doc.Status
 
 	//Start- Check that the currentStatus to newStatus transition is accurate
 
@@ -173,39 +132,20 @@ func (t *BL) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]by
 		stateTransitionAllowed = true
 	}
 
-	fmt.Println("state is to identify  ", stateTransitionAllowed)
-
 	if stateTransitionAllowed == false {
-		return nil, errors.New("This state transition is not allowed.")
+		return nil, newError(codeInvalidTransition, "This state transition is not allowed.")
 	}
-	
 
 	//End- Check that the currentStatus to newStatus transition is accurate
 
+	doc.Status = newStatus
 
-	err = stub.DeleteRow(
-		"BLTable",
-		columns,
-	)
-	if err != nil {
-		return nil, errors.New("Failed deleting row.")
-	}
-
-	_, err = stub.InsertRow(
-		"BLTable",
-		shim.Row{
-			Columns: []*shim.Column{
-				&shim.Column{Value: &shim.Column_String_{String_: "DOC"}},
-				&shim.Column{Value: &shim.Column_String_{String_: UID}},
-				&shim.Column{Value: &shim.Column_Bytes{Bytes: docPDF}},
-				&shim.Column{Value: &shim.Column_String_{String_: newStatus}}},
-		})
+	err = blStore.Replace(stub, doc)
 	if err != nil {
-		return nil, errors.New("Failed inserting row.")
+		return nil, err
 	}
 
 	return nil, nil
-
 }
 
 
@@ -214,60 +154,44 @@ func (t *BL) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]by
 func (t *BL) GetPDF(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
 	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("BLTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := blStore.Get(stub, UID)
 	if err != nil {
-		jsonResp := "{\"Error\":\"Failed retrieveing document with UID " + UID + ". Error " + err.Error() + ". \"}"
-		return nil, errors.New(jsonResp)
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Bill of lading of contract %s has not been submitted.", UID)
 	}
 
-	//return row.Columns[3].GetBytes(), nil
-	return row.Columns[2].GetBytes(), nil
-
+	return doc.Content, nil
 }
 
 // GetStatus () – returns as JSON the Status w.r.t. the UID
 func (t *BL) GetStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
 	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("BLTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := blStore.Get(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Bill of lading of contract %s has not been submitted.", UID)
 	}
 
-	//return []byte(row.Columns[4].GetString_()), nil
-	return []byte(row.Columns[3].GetString_()), nil
+	return []byte(doc.Status), nil
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// maxBulkTrades bounds the size of a bulkInitTrades batch so that it fits in one transaction
+const maxBulkTrades = 500
+
+// edImportStatuses are the statuses the export documents of an imported trade may have
+var edImportStatuses = map[string]bool{
+	"SUBMITTED_BY_EB":    true,
+	"ACCEPTED_BY_IB":     true,
+	"PAYMENT_INITIATED":  true,
+	"PAYMENT_INPROGRESS": true,
+	"PAYMENT_COMPLETED":  true,
+	"REJECTED_BY_IB":     true,
+}
+
+// HistoricalTrade is an in-flight trade imported from another system with its current documents and statuses
+type HistoricalTrade struct {
+	ContractID          string          `json:"contractID"`
+	PO                  json.RawMessage `json:"po"`
+	POStatus            string          `json:"poStatus"`
+	ImporterName        string          `json:"importerName"`
+	ExporterName        string          `json:"exporterName"`
+	ImporterBankName    string          `json:"importerBankName"`
+	ExporterBankName    string          `json:"exporterBankName"`
+	ImporterCert        string          `json:"importerCert"`
+	ExporterCert        string          `json:"exporterCert"`
+	ImporterBankCert    string          `json:"importerBankCert"`
+	ExporterBankCert    string          `json:"exporterBankCert"`
+	ConfirmingBankName  string          `json:"confirmingBankName,omitempty"`
+	ConfirmingBankCert  string          `json:"confirmingBankCert,omitempty"`
+	ReimbursingBankName string          `json:"reimbursingBankName,omitempty"`
+	ReimbursingBankCert string          `json:"reimbursingBankCert,omitempty"`
+	BLPDF               string          `json:"blPDF"`
+	InvoicePDF          string          `json:"invoicePDF"`
+	PackingListPDF      string          `json:"packingListPDF"`
+	EDStatus            string          `json:"edStatus"`
+	Status              string          `json:"status"`
+}
+
+// BulkImportResult is the result of bulkInitTrades
+type BulkImportResult struct {
+	Imported    int      `json:"imported"`
+	ContractIDs []string `json:"contractIDs"`
+}
+
+// validate checks a trade on its own. Its contract ID is checked against the ledger and the batch by bulkInitTrades.
+func (trade *HistoricalTrade) validate() []string {
+	var problems []string
+
+	var po map[string]interface{}
+	if len(trade.PO) == 0 || json.Unmarshal(trade.PO, &po) != nil {
+		problems = append(problems, "po should be a JSON object")
+	}
+	if trade.POStatus != "" && trade.POStatus != "SUBMITTED_BY_IB" && trade.POStatus != "PAYMENT_INITIATED" {
+		problems = append(problems, "unknown poStatus "+trade.POStatus)
+	}
+
+	for name, value := range map[string]string{
+		"importerName":     trade.ImporterName,
+		"exporterName":     trade.ExporterName,
+		"importerBankName": trade.ImporterBankName,
+		"exporterBankName": trade.ExporterBankName,
+		"importerCert":     trade.ImporterCert,
+		"exporterCert":     trade.ExporterCert,
+		"importerBankCert": trade.ImporterBankCert,
+		"exporterBankCert": trade.ExporterBankCert,
+	} {
+		if value == "" {
+			problems = append(problems, "missing "+name)
+		}
+	}
+
+	if (trade.ConfirmingBankName == "") != (trade.ConfirmingBankCert == "") {
+		problems = append(problems, "confirmingBankName and confirmingBankCert go together")
+	}
+	if (trade.ReimbursingBankName == "") != (trade.ReimbursingBankCert == "") {
+		problems = append(problems, "reimbursingBankName and reimbursingBankCert go together")
+	}
+
+	hasDocs := trade.BLPDF != "" || trade.InvoicePDF != "" || trade.PackingListPDF != ""
+	if hasDocs && !edImportStatuses[trade.EDStatus] {
+		problems = append(problems, "documents need a known edStatus, got \""+trade.EDStatus+"\"")
+	}
+	if !hasDocs && trade.EDStatus != "" {
+		problems = append(problems, "edStatus given without documents")
+	}
+
+	switch trade.Status {
+	case "", statusCancelled:
+	case statusClosed:
+		if trade.EDStatus != "PAYMENT_COMPLETED" {
+			problems = append(problems, "only trades with a completed payment can be closed")
+		}
+	default:
+		problems = append(problems, "status may only be "+statusCancelled+" or "+statusClosed+", other statuses are derived")
+	}
+
+	// Map iteration order is random, keep the messages deterministic
+	sort.Strings(problems)
+	return problems
+}
+
+// bulkInitTrades imports a batch of in-flight trades. Every trade is validated first. If any is invalid
+// nothing is written and the error lists the problems of each invalid trade.
+func (t *SBI) bulkInitTrades(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
+
+	var trades []HistoricalTrade
+	err := json.Unmarshal([]byte(args[0]), &trades)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Trades are not a valid JSON array of trades: %s", err.Error())
+	}
+	if len(trades) == 0 || len(trades) > maxBulkTrades {
+		return nil, newError(codeInvalidArgs, "Expecting 1 to %d trades. Got: %d.", maxBulkTrades, len(trades))
+	}
+
+	var itemErrors []ItemError
+	seen := make(map[string]int)
+	for i := range trades {
+		trade := &trades[i]
+		if problems := trade.validate(); len(problems) != 0 {
+			itemErrors = append(itemErrors, ItemError{Index: i, Code: codeValidationFailed, Message: strings.Join(problems, "; ")})
+			continue
+		}
+
+		if trade.ContractID == "" {
+			continue
+		}
+		if first, ok := seen[trade.ContractID]; ok {
+			itemErrors = append(itemErrors, ItemError{Index: i, Code: codeDuplicate, Message: fmt.Sprintf("Contract %s is also trade %d of the batch.", trade.ContractID, first)})
+			continue
+		}
+		seen[trade.ContractID] = i
+
+		bp, err := bpStore.Get(stub, trade.ContractID)
+		if err != nil {
+			itemErrors = append(itemErrors, ItemError{Index: i, Code: errorCode(toChaincodeError(err)), Message: err.Error()})
+			continue
+		}
+		if bp != nil {
+			itemErrors = append(itemErrors, ItemError{Index: i, Code: codeDuplicate, Message: fmt.Sprintf("Contract %s already exists.", trade.ContractID)})
+		}
+	}
+	if len(itemErrors) != 0 {
+		return nil, &ChaincodeError{
+			Code:    codeValidationFailed,
+			Message: fmt.Sprintf("%d of %d trades are invalid, none was imported.", len(itemErrors), len(trades)),
+			Items:   itemErrors,
+		}
+	}
+
+	result := BulkImportResult{ContractIDs: make([]string, 0, len(trades))}
+	for i := range trades {
+		UID, err := t.importTrade(stub, &trades[i])
+		if cerr, ok := err.(*ChaincodeError); ok && cerr.Code == codeValidationFailed {
+			return nil, &ChaincodeError{
+				Code:    codeValidationFailed,
+				Message: fmt.Sprintf("Trade %d of %d can not be imported, none was imported.", i, len(trades)),
+				Items:   []ItemError{{Index: i, Code: cerr.Code, Message: cerr.Message}},
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Imported++
+		result.ContractIDs = append(result.ContractIDs, UID)
+	}
+
+	myLogger.Debugf("Imported %d trades", result.Imported)
+
+	return json.Marshal(result)
+}
+
+// importTrade writes a validated trade and returns its contract ID
+func (t *SBI) importTrade(stub shim.ChaincodeStubInterface, trade *HistoricalTrade) (string, error) {
+	UID := trade.ContractID
+	if UID == "" {
+		var err error
+		UID, err = t.allocateContractID(stub, trade.ImporterBankName)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, err := t.createTrade(stub, []string{UID, string(trade.PO),
+		trade.ImporterName, trade.ExporterName, trade.ImporterBankName, trade.ExporterBankName,
+		trade.ImporterCert, trade.ExporterCert, trade.ImporterBankCert, trade.ExporterBankCert,
+		trade.ConfirmingBankName, trade.ConfirmingBankCert, trade.ReimbursingBankName, trade.ReimbursingBankCert})
+	if err != nil {
+		return "", err
+	}
+
+	if trade.POStatus != "" {
+		err = poStore.Replace(stub, &Document{UID: UID, Content: trade.PO, Status: trade.POStatus})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, doc := range []struct {
+		store   DocumentStore
+		content string
+	}{
+		{blStore, trade.BLPDF},
+		{invoiceStore, trade.InvoicePDF},
+		{plStore, trade.PackingListPDF},
+	} {
+		if doc.content == "" {
+			continue
+		}
+		_, err = doc.store.Insert(stub, &Document{UID: UID, Content: []byte(doc.content), Status: trade.EDStatus})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if trade.Status != "" {
+		bp, err := t.getContract(stub, UID)
+		if err != nil {
+			return "", err
+		}
+		err = t.setContractStatus(stub, bp, trade.Status)
+	} else {
+		err = t.refreshContract(stub, UID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return UID, t.screenImportedTrade(stub, UID)
+}
+
+// screenImportedTrade screens a trade once its documents and final status are imported. An open trade
+// with hits is held, a cancelled or closed one can no longer be held and is refused.
+func (t *SBI) screenImportedTrade(stub shim.ChaincodeStubInterface, UID string) error {
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return err
+	}
+	if !isTerminalStatus(bp.Status) {
+		return t.screenContract(stub, UID)
+	}
+
+	list, err := complianceStore.GetList(stub)
+	if err != nil {
+		return err
+	}
+	if len(list.Entries) == 0 {
+		return nil
+	}
+
+	hits, _, err := t.unclearedHits(stub, bp, list)
+	if err != nil {
+		return err
+	}
+	if len(hits) != 0 {
+		return newError(codeValidationFailed, "Contract %s is %s but its %s is on the watch list as %s.", UID, bp.Status, hits[0].Field, hits[0].Entry)
+	}
+
+	return nil
+}
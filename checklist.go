@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// ChecklistItem is a document required by the documents required clause (Tag46A) of the PO
+type ChecklistItem struct {
+	DocType     string `json:"docType"` // registered document type, empty if none matches
+	Description string `json:"description"`
+	Originals   int    `json:"originals"`
+	Copies      int    `json:"copies"`
+}
+
+// ChecklistStatus is a required document and where its presentation stands
+type ChecklistStatus struct {
+	ChecklistItem
+	Submitted bool   `json:"submitted"`
+	Status    string `json:"status"`
+	Blocking  bool   `json:"blocking"` // missing, acceptED is refused until it is submitted
+}
+
+// PresentationChecklist is the result of getPresentationChecklist
+type PresentationChecklist struct {
+	ContractID string            `json:"contractID"`
+	Items      []ChecklistStatus `json:"items"`
+	Blocking   bool              `json:"blocking"`
+}
+
+var (
+	countWords    = map[string]int{"ONE": 1, "TWO": 2, "THREE": 3, "FOUR": 4, "FIVE": 5, "SIX": 6, "SEVEN": 7, "EIGHT": 8, "NINE": 9, "TEN": 10}
+	originalsRe   = regexp.MustCompile(`([0-9]+|ONE|TWO|THREE|FOUR|FIVE|SIX|SEVEN|EIGHT|NINE|TEN)\s*(/\s*[0-9]+\s*)?ORIGINAL`)
+	fullSetRe     = regexp.MustCompile(`\b([0-9]+)\s*/\s*([0-9]+)\b`)
+	copiesRe      = regexp.MustCompile(`([0-9]+|ONE|TWO|THREE|FOUR|FIVE|SIX|SEVEN|EIGHT|NINE|TEN)\s*(ADDITIONAL\s+|NON[- ]NEGOTIABLE\s+)?COP(Y|IES)`)
+	plicateRe     = regexp.MustCompile(`IN (DUPLICATE|TRIPLICATE|QUADRUPLICATE)`)
+	sentenceEndRe = regexp.MustCompile(`\.(\s+|$)`)
+	plicateCounts = map[string]int{"DUPLICATE": 2, "TRIPLICATE": 3, "QUADRUPLICATE": 4}
+)
+
+// countOf parses a count written in figures or in words
+func countOf(s string) int {
+	if n, ok := countWords[s]; ok {
+		return n
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// checklistClauses splits a documents required clause into one clause per document. MT700 lists them
+// on lines starting with +, free text as sentences.
+func checklistClauses(tag46A string) []string {
+	var clauses []string
+	text := strings.Replace(tag46A, "\r\n", "\n", -1)
+
+	if strings.HasPrefix(strings.TrimSpace(text), "+") || strings.Contains(text, "\n+") {
+		for _, clause := range strings.Split(text, "\n+") {
+			clause = strings.TrimPrefix(strings.TrimSpace(clause), "+")
+			clauses = append(clauses, strings.Join(strings.Fields(clause), " "))
+		}
+	} else {
+		for _, line := range strings.Split(text, "\n") {
+			clauses = append(clauses, sentenceEndRe.Split(line, -1)...)
+		}
+	}
+
+	nonEmpty := clauses[:0]
+	for _, clause := range clauses {
+		if clause = strings.TrimSpace(clause); clause != "" {
+			nonEmpty = append(nonEmpty, clause)
+		}
+	}
+	return nonEmpty
+}
+
+// classifyClause returns the registered document type a clause requires, or "" if none matches. Clauses
+// often name other documents ("certificate of origin stating the invoice number"), so the keyword found
+// first, the leading noun of the clause, decides. The longer keyword wins a tie.
+func classifyClause(upper string) string {
+	docType, first, length := "", -1, 0
+	for _, dt := range documentTypes {
+		for _, keyword := range dt.Keywords {
+			i := strings.Index(upper, keyword)
+			if i < 0 {
+				continue
+			}
+			if first < 0 || i < first || (i == first && len(keyword) > length) {
+				docType, first, length = dt.Code, i, len(keyword)
+			}
+		}
+	}
+
+	return docType
+}
+
+// parseChecklist derives the required documents from Tag46A. Following UCP 600 article 17, a document
+// required without counts needs one original, and one required in N copies needs one original and N-1 copies.
+func parseChecklist(tag46A string) []ChecklistItem {
+	items := make([]ChecklistItem, 0)
+
+	for _, clause := range checklistClauses(tag46A) {
+		upper := strings.ToUpper(clause)
+		item := ChecklistItem{Description: clause, DocType: classifyClause(upper)}
+
+		originals := originalsRe.FindStringSubmatch(upper)
+		copies := copiesRe.FindStringSubmatch(upper)
+		fullSet := fullSetRe.FindStringSubmatch(upper)
+		plicate := plicateRe.FindStringSubmatch(upper)
+		switch {
+		case originals != nil:
+			item.Originals = countOf(originals[1])
+			if copies != nil {
+				item.Copies = countOf(copies[1])
+			}
+		case fullSet != nil:
+			// A full set of bills of lading, e.g. 3/3
+			item.Originals = countOf(fullSet[1])
+		case copies != nil:
+			item.Originals = 1
+			item.Copies = countOf(copies[1]) - 1
+		case plicate != nil:
+			item.Originals = 1
+			item.Copies = plicateCounts[plicate[1]] - 1
+		default:
+			item.Originals = 1
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// captureChecklist stores the required documents of the current PO of the contract
+func (t *SBI) captureChecklist(stub shim.ChaincodeStubInterface, UID string) error {
+	lc, err := t.getLC(stub, UID)
+	if err != nil {
+		return err
+	}
+
+	return checklistStore.Put(stub, &Checklist{UID: UID, Items: parseChecklist(lc.Tag46A)})
+}
+
+// presentationChecklist returns the required documents of the contract with their presentation status
+func (t *SBI) presentationChecklist(stub shim.ChaincodeStubInterface, UID string) (*PresentationChecklist, error) {
+	checklist, err := checklistStore.Get(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PresentationChecklist{ContractID: UID, Items: make([]ChecklistStatus, 0)}
+	if checklist == nil {
+		return result, nil
+	}
+
+	for _, item := range checklist.Items {
+		status := ChecklistStatus{ChecklistItem: item}
+
+		// Documents of no registered type can not be checked on the ledger
+		if dt, ok := lookupDocumentType(item.DocType); ok {
+			doc, err := dt.Store.Get(stub, UID)
+			if err != nil {
+				return nil, err
+			}
+			if doc != nil {
+				status.Submitted = true
+				status.Status = doc.Status
+			} else {
+				status.Blocking = true
+				result.Blocking = true
+			}
+		}
+
+		result.Items = append(result.Items, status)
+	}
+
+	return result, nil
+}
+
+// checkPresentationComplete returns an error listing the required documents not submitted yet
+func (t *SBI) checkPresentationComplete(stub shim.ChaincodeStubInterface, UID string) error {
+	checklist, err := t.presentationChecklist(stub, UID)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, item := range checklist.Items {
+		if item.Blocking && !containsString(missing, item.DocType) {
+			missing = append(missing, item.DocType)
+		}
+	}
+	if len(missing) != 0 {
+		return newError(codeValidationFailed, "Documents required by the LC are missing: %s.", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// getPresentationChecklist returns the documents required by the PO and whether they have been submitted
+func (t *SBI) getPresentationChecklist(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	checklist, err := t.presentationChecklist(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(checklist)
+}
+
+// captureChecklists derives the checklist of the contracts created before checklists were captured
+func captureChecklists(t *SBI, stub shim.ChaincodeStubInterface) error {
+	bps, err := bpStore.List(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, bp := range bps {
+		err = t.captureChecklist(stub, bp.UID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseChecklist(t *testing.T) {
+	for _, test := range []struct {
+		tag46A string
+		items  []ChecklistItem
+	}{
+		{
+			" Signed commercial invoice in 1Original(s) plus3 Copy(ies). Packing list in 1Original(s) plus1 Copy(ies).",
+			[]ChecklistItem{
+				{"INVOICE", "Signed commercial invoice in 1Original(s) plus3 Copy(ies)", 1, 3},
+				{"PACKINGLIST", "Packing list in 1Original(s) plus1 Copy(ies)", 1, 1},
+			},
+		},
+		{
+			"+FULL SET 3/3 CLEAN ON BOARD BILLS OF LADING\r\nMADE OUT TO ORDER\r\n+COMMERCIAL INVOICE IN TRIPLICATE\r\n+CERTIFICATE OF ORIGIN ISSUED BY THE CHAMBER OF COMMERCE\r\n+WEIGHT LIST IN 2 COPIES",
+			[]ChecklistItem{
+				{"BL", "FULL SET 3/3 CLEAN ON BOARD BILLS OF LADING MADE OUT TO ORDER", 3, 0},
+				{"INVOICE", "COMMERCIAL INVOICE IN TRIPLICATE", 1, 2},
+				{"CERT_OF_ORIGIN", "CERTIFICATE OF ORIGIN ISSUED BY THE CHAMBER OF COMMERCE", 1, 0},
+				{"", "WEIGHT LIST IN 2 COPIES", 1, 1},
+			},
+		},
+		{
+			"+CERTIFICATE OF ORIGIN STATING THE INVOICE NUMBER\n+BENEFICIARY'S CERTIFICATE THAT ONE COPY OF B/L WAS SENT TO THE APPLICANT\n+INSURANCE POLICY COVERING THE INVOICE VALUE PLUS 10 PCT",
+			[]ChecklistItem{
+				{"CERT_OF_ORIGIN", "CERTIFICATE OF ORIGIN STATING THE INVOICE NUMBER", 1, 0},
+				{"BENEFICIARY_STATEMENT", "BENEFICIARY'S CERTIFICATE THAT ONE COPY OF B/L WAS SENT TO THE APPLICANT", 1, 0},
+				{"INSURANCE_CERT", "INSURANCE POLICY COVERING THE INVOICE VALUE PLUS 10 PCT", 1, 0},
+			},
+		},
+		{"", []ChecklistItem{}},
+	} {
+		if items := parseChecklist(test.tag46A); !reflect.DeepEqual(items, test.items) {
+			t.Errorf("parseChecklist(%q) = %+v, want %+v", test.tag46A, items, test.items)
+		}
+	}
+}
+
+func TestPresentationChecklist(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	var po map[string]string
+	if err := json.Unmarshal(testPOJSON, &po); err != nil {
+		t.Fatal(err)
+	}
+	po["Tag46A"] = "+COMMERCIAL INVOICE IN 2 ORIGINALS\n+CERTIFICATE OF ORIGIN\n+WEIGHT LIST"
+	poJSON, _ := json.Marshal(po)
+
+	if err := initTrade(stub, adminCert, "1000", poJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := submitED(stub, adminCert, "1000", []byte(`BLPDF`), []byte(`InvoicePDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var checklist PresentationChecklist
+	b, err := stub.query(nil, "getPresentationChecklist", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(b, &checklist); err != nil {
+		t.Fatal(err)
+	}
+	if len(checklist.Items) != 3 || !checklist.Blocking ||
+		!checklist.Items[0].Submitted || checklist.Items[0].Status != "SUBMITTED_BY_EB" || checklist.Items[0].Originals != 2 ||
+		checklist.Items[1].Submitted || !checklist.Items[1].Blocking ||
+		checklist.Items[2].Blocking {
+		t.Fatalf("checklist with the certificate of origin missing: %s", b)
+	}
+
+	// The documents can not be accepted until the certificate of origin is presented
+	if err = acceptED(stub, adminCert, "1000"); errorCode(err) != codeValidationFailed {
+		t.Fatalf("acceptED with the certificate of origin missing: %v", err)
+	}
+	if _, err = stub.invoke(adminCert, "submitDocument", "1000", "CERT_OF_ORIGIN", "COOPDF"); err != nil {
+		t.Fatal(err)
+	}
+	if err = acceptED(stub, adminCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = stub.query(nil, "getPresentationChecklist", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(b, &checklist); err != nil {
+		t.Fatal(err)
+	}
+	if checklist.Blocking || checklist.Items[1].Status != "ACCEPTED_BY_IB" {
+		t.Fatalf("checklist after the acceptance: %s", b)
+	}
+}
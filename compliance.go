@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Watch list entry types
+const (
+	watchParty  = "PARTY"
+	watchVessel = "VESSEL"
+	watchPort   = "PORT"
+)
+
+// Compliance hold statuses
+const (
+	holdHeld     = "HELD"
+	holdReleased = "RELEASED"
+)
+
+// WatchListEntry is a restricted party, vessel or port of the sanctions list
+type WatchListEntry struct {
+	Type    string   `json:"type"` // PARTY, VESSEL or PORT
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	Program string   `json:"program,omitempty"` // sanctions program listing the entry
+}
+
+// ScreeningHit is a watch list entry found in a field of a contract
+type ScreeningHit struct {
+	Field   string `json:"field"` // e.g. importerName, Tag44E or blPDF
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Entry   string `json:"entry"`
+	Program string `json:"program,omitempty"`
+}
+
+// ComplianceHold is the screening outcome of a contract. Hits cleared by a release do not hold the contract again.
+type ComplianceHold struct {
+	UID         string         `json:"contractID"`
+	Status      string         `json:"status,omitempty"` // HELD or RELEASED, empty if the contract was never held
+	Hits        []ScreeningHit `json:"hits,omitempty"`
+	ListVersion int            `json:"listVersion,omitempty"`
+	HeldAt      string         `json:"heldAt,omitempty"`
+	Cleared     []ScreeningHit `json:"cleared,omitempty"`
+	ReleasedAt  string         `json:"releasedAt,omitempty"`
+	Reason      string         `json:"reason,omitempty"`
+}
+
+// screenedField is a field of a contract and the watch list entries it is screened against
+type screenedField struct {
+	name      string
+	value     string
+	entryType string
+}
+
+// policyComplianceAdmin lets only the compliance admin given at deployment act
+var policyComplianceAdmin = accessPolicy{Role: "ComplianceAdmin", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+	config, err := complianceStore.GetConfig(stub)
+	if err != nil || len(config.AdminCert) == 0 {
+		return false, err
+	}
+
+	return t.isCaller(stub, config.AdminCert)
+}}
+
+// setComplianceAdmin records the certificate of the compliance admin given to Init. Without one the admin is kept.
+func setComplianceAdmin(stub shim.ChaincodeStubInterface, args []string) error {
+	if len(args) == 0 || args[0] == "" {
+		return nil
+	}
+
+	return complianceStore.PutConfig(stub, &ComplianceConfig{AdminCert: []byte(args[0])})
+}
+
+// normalizeName uppercases name and reduces everything but letters and digits to single spaces
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	}), " ")
+}
+
+// watchListMatch reports whether name appears as whole words in value
+func watchListMatch(value string, name string) bool {
+	name = normalizeName(name)
+	if name == "" {
+		return false
+	}
+
+	return strings.Contains(" "+normalizeName(value)+" ", " "+name+" ")
+}
+
+// screen returns the hits of the watch list in the fields
+func screen(fields []screenedField, entries []WatchListEntry) []ScreeningHit {
+	hits := make([]ScreeningHit, 0)
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Type != field.entryType {
+				continue
+			}
+			for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+				if watchListMatch(field.value, name) {
+					hits = append(hits, ScreeningHit{Field: field.name, Value: field.value, Type: entry.Type, Entry: entry.Name, Program: entry.Program})
+					break
+				}
+			}
+		}
+	}
+
+	return hits
+}
+
+// screenedFields returns the parties, the ports of the PO and the BL of the contract
+func (t *SBI) screenedFields(stub shim.ChaincodeStubInterface, bp *BusinessProcess) ([]screenedField, error) {
+	lc, err := t.getLC(stub, bp.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The vessel is named somewhere in the BL, which has no structured form
+	var bl string
+	doc, err := blStore.Get(stub, bp.UID)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil {
+		bl = string(doc.Content)
+	}
+
+	return []screenedField{
+		{"importerName", bp.ImporterName, watchParty},
+		{"exporterName", bp.ExporterName, watchParty},
+		{"importerBankName", bp.ImporterBankName, watchParty},
+		{"exporterBankName", bp.ExporterBankName, watchParty},
+		{"Tag44A", lc.Tag44A, watchPort},
+		{"Tag44E", lc.Tag44E, watchPort},
+		{"Tag44F", lc.Tag44F, watchPort},
+		{"Tag44B", lc.Tag44B, watchPort},
+		{"blPDF", bl, watchVessel},
+	}, nil
+}
+
+// isClearedHit reports whether the compliance admin already released the contract with this hit
+func isClearedHit(hold *ComplianceHold, hit ScreeningHit) bool {
+	if hold == nil {
+		return false
+	}
+	for _, cleared := range hold.Cleared {
+		if cleared == hit {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unclearedHits screens the contract against the sanctions list and returns the hits the compliance admin
+// has not cleared, with the screening outcome so far
+func (t *SBI) unclearedHits(stub shim.ChaincodeStubInterface, bp *BusinessProcess, list *SanctionsList) ([]ScreeningHit, *ComplianceHold, error) {
+	fields, err := t.screenedFields(stub, bp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hold, err := complianceStore.GetHold(stub, bp.UID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hits []ScreeningHit
+	for _, hit := range screen(fields, list.Entries) {
+		if !isClearedHit(hold, hit) {
+			hits = append(hits, hit)
+		}
+	}
+
+	return hits, hold, nil
+}
+
+// screenContract screens the contract against the sanctions list. Hits not cleared before put the
+// contract on COMPLIANCE_HOLD until the compliance admin releases it.
+func (t *SBI) screenContract(stub shim.ChaincodeStubInterface, UID string) error {
+	list, err := complianceStore.GetList(stub)
+	if err != nil {
+		return err
+	}
+	if len(list.Entries) == 0 {
+		return nil
+	}
+
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return err
+	}
+	if isTerminalStatus(bp.Status) {
+		return nil
+	}
+
+	hits, hold, err := t.unclearedHits(stub, bp, list)
+	if err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	if hold == nil {
+		hold = &ComplianceHold{UID: UID}
+	}
+	if hold.Status != holdHeld {
+		hold.HeldAt = now
+	}
+	hold.Status = holdHeld
+	hold.Hits = hits
+	hold.ListVersion = list.Version
+
+	err = complianceStore.PutHold(stub, hold)
+	if err != nil {
+		return err
+	}
+
+	myLogger.Infof("Contract %s is on compliance hold: %d watch list hits", UID, len(hits))
+	return t.setContractStatus(stub, bp, statusComplianceHold)
+}
+
+// updateSanctionsList replaces the watch list the contracts are screened against
+func (t *SBI) updateSanctionsList(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var entries []WatchListEntry
+	err := json.Unmarshal([]byte(args[0]), &entries)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Entries are not a valid JSON array of watch list entries: %s", err.Error())
+	}
+
+	var problems []string
+	for i, entry := range entries {
+		if entry.Type != watchParty && entry.Type != watchVessel && entry.Type != watchPort {
+			problems = append(problems, "entry "+strconv.Itoa(i)+": type must be "+watchParty+", "+watchVessel+" or "+watchPort)
+		}
+		if normalizeName(entry.Name) == "" {
+			problems = append(problems, "entry "+strconv.Itoa(i)+": name is empty")
+		}
+	}
+	if len(problems) != 0 {
+		return nil, newError(codeValidationFailed, "Invalid watch list: %s.", strings.Join(problems, "; "))
+	}
+
+	list, err := complianceStore.GetList(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	list.Version++
+	list.Entries = entries
+	list.UpdatedAt = now
+
+	err = complianceStore.PutList(stub, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(list)
+}
+
+// releaseComplianceHold clears the hits of a contract on compliance hold and lets the trade proceed
+func (t *SBI) releaseComplianceHold(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	bp, err := t.getContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := complianceStore.GetHold(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if bp.Status != statusComplianceHold || hold == nil || hold.Status != holdHeld {
+		return nil, newError(codeInvalidTransition, "Contract %s is not on compliance hold.", contractID)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = holdReleased
+	hold.Cleared = append(hold.Cleared, hold.Hits...)
+	hold.Hits = nil
+	hold.ReleasedAt = now
+	hold.Reason = args[1]
+
+	err = complianceStore.PutHold(stub, hold)
+	if err != nil {
+		return nil, err
+	}
+
+	// The status is derived again from the PO and the documents
+	err = t.setContractStatus(stub, bp, statusPOPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, t.refreshContract(stub, contractID)
+}
+
+// getComplianceHold returns the screening outcome of the contract
+func (t *SBI) getComplianceHold(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	hold, err := complianceStore.GetHold(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil {
+		hold = &ComplianceHold{UID: args[0]}
+	}
+
+	return json.Marshal(hold)
+}
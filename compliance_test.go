@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestComplianceHold(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+
+	stub := newMockStub()
+	complianceCert := []byte(`ComplianceCert`)
+	importerCert, exporterCert := []byte(`ICert`), []byte(`ECert`)
+	importerBankCert, exporterBankCert := []byte(`IBCert`), []byte(`EBCert`)
+
+	watchList := `[
+		{"type": "PARTY", "name": "Blocked Trading Co", "aliases": ["BTC Ltd"], "program": "SDN"},
+		{"type": "VESSEL", "name": "Dark Star"},
+		{"type": "PORT", "name": "Forbidden Harbour"}
+	]`
+	if _, err := stub.invoke(complianceCert, "updateSanctionsList", watchList); errorCode(err) != codeAccessDenied {
+		t.Fatalf("updateSanctionsList without a compliance admin: %v", err)
+	}
+	if _, err := stub.init(string(complianceCert)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(importerBankCert, "updateSanctionsList", watchList); errorCode(err) != codeAccessDenied {
+		t.Fatalf("updateSanctionsList by the importer bank: %v", err)
+	}
+	if _, err := stub.invoke(complianceCert, "updateSanctionsList", `[{"type": "AIRCRAFT", "name": "X"}]`); errorCode(err) != codeValidationFailed {
+		t.Fatalf("updateSanctionsList with an unknown type: %v", err)
+	}
+	if _, err := stub.invoke(complianceCert, "updateSanctionsList", watchList); err != nil {
+		t.Fatal(err)
+	}
+
+	contractStatus := func(contractID string) string {
+		var summary ContractSummary
+		b, err := stub.query(importerBankCert, "getContractSummary", contractID)
+		if err = json.Unmarshal(b, &summary); err != nil {
+			t.Fatal(err)
+		}
+		return summary.ContractStatus
+	}
+
+	// Clean trade
+	if err := initTrade(stub, importerBankCert, "1000", testPOJSON, "I", "E", "IB", "EB", importerCert, exporterCert, importerBankCert, exporterBankCert); err != nil {
+		t.Fatal(err)
+	}
+	if status := contractStatus("1000"); status != statusDocsPending {
+		t.Fatalf("status of a clean trade: %s", status)
+	}
+
+	// The exporter goes by an alias of a restricted party
+	if err := initTrade(stub, importerBankCert, "1001", testPOJSON, "I", "btc, ltd.", "IB", "EB", importerCert, exporterCert, importerBankCert, exporterBankCert); err != nil {
+		t.Fatal(err)
+	}
+	if status := contractStatus("1001"); status != statusComplianceHold {
+		t.Fatalf("status of a trade with a restricted exporter: %s", status)
+	}
+	var hold ComplianceHold
+	b, err := stub.query(importerBankCert, "getComplianceHold", "1001")
+	if err = json.Unmarshal(b, &hold); err != nil || hold.Status != holdHeld || len(hold.Hits) != 1 || hold.Hits[0].Field != "exporterName" || hold.Hits[0].Entry != "Blocked Trading Co" {
+		t.Fatalf("getComplianceHold: %s %v", b, err)
+	}
+
+	// Nothing proceeds on hold, only the compliance admin releases it
+	if err = submitED(stub, exporterBankCert, "1001", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("submitED on hold: %v", err)
+	}
+	if _, err = stub.invoke(importerBankCert, "releaseComplianceHold", "1001", "cleared"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("releaseComplianceHold by the importer bank: %v", err)
+	}
+	if _, err = stub.invoke(complianceCert, "releaseComplianceHold", "1000", "cleared"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("releaseComplianceHold of a clean trade: %v", err)
+	}
+	if _, err = stub.invoke(complianceCert, "releaseComplianceHold", "1001", "Different entity, checked registration"); err != nil {
+		t.Fatal(err)
+	}
+	if status := contractStatus("1001"); status != statusDocsPending {
+		t.Fatalf("status after the release: %s", status)
+	}
+
+	// Cleared hits do not hold the trade again, a vessel on the watch list does
+	if err = submitED(stub, exporterBankCert, "1001", []byte("Vessel: DARK STAR\nVoyage 12"), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	if status := contractStatus("1001"); status != statusComplianceHold {
+		t.Fatalf("status with a restricted vessel: %s", status)
+	}
+	b, err = stub.query(importerBankCert, "getComplianceHold", "1001")
+	if err = json.Unmarshal(b, &hold); err != nil || len(hold.Hits) != 1 || hold.Hits[0].Type != watchVessel || len(hold.Cleared) != 1 {
+		t.Fatalf("getComplianceHold with a restricted vessel: %s %v", b, err)
+	}
+	if _, err = stub.invoke(complianceCert, "releaseComplianceHold", "1001", "Vessel renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if status := contractStatus("1001"); status != statusUnderExamination {
+		t.Fatalf("status after the second release: %s", status)
+	}
+
+	// A port of the PO
+	var po map[string]string
+	if err = json.Unmarshal(testPOJSON, &po); err != nil {
+		t.Fatal(err)
+	}
+	po["Tag44F"] = "FORBIDDEN HARBOUR"
+	if _, err = stub.invoke(importerBankCert, "updatePO", "1000", jsonObject(po)); err != nil {
+		t.Fatal(err)
+	}
+	if status := contractStatus("1000"); status != statusComplianceHold {
+		t.Fatalf("status with a restricted port: %s", status)
+	}
+}
+
+func TestWatchListMatch(t *testing.T) {
+	for _, test := range []struct {
+		value, name string
+		match       bool
+	}{
+		{"Blocked Trading Co.", "BLOCKED TRADING CO", true},
+		{"Port of Forbidden Harbour, XX", "Forbidden Harbour", true},
+		{"Unblocked Trading Co", "Blocked Trading Co", false},
+		{"Anything", "--", false},
+	} {
+		if match := watchListMatch(test.value, test.name); match != test.match {
+			t.Errorf("watchListMatch(%q, %q) = %v", test.value, test.name, match)
+		}
+	}
+}
+
+// jsonObject encodes v as a JSON string argument
+func jsonObject(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestBulkImportScreening(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+
+	stub := newMockStub()
+	deployerCert, complianceCert := []byte(`DeployerCert`), []byte(`ComplianceCert`)
+	if _, err := stub.deploy(deployerCert, string(complianceCert)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(complianceCert, "updateSanctionsList", `[{"type": "PARTY", "name": "Blocked Trading Co"}]`); err != nil {
+		t.Fatal(err)
+	}
+
+	trade := func(contractID string, exporterName string, extra string) string {
+		return `{"contractID": "` + contractID + `", "po": ` + string(testPOJSON) + `,
+			"importerName": "I", "exporterName": "` + exporterName + `", "importerBankName": "IB", "exporterBankName": "EB",
+			"importerCert": "ICert", "exporterCert": "ECert", "importerBankCert": "IBCert", "exporterBankCert": "EBCert"` + extra + `}`
+	}
+
+	// A closed trade can not be held, so it is refused with its index
+	_, err := stub.invoke(deployerCert, "bulkInitTrades", "["+
+		trade("2000", "E", "")+","+
+		trade("2001", "Blocked Trading Co", `, "blPDF": "BLPDF", "edStatus": "PAYMENT_COMPLETED", "status": "CLOSED"`)+"]")
+	cerr, ok := err.(*ChaincodeError)
+	if !ok || cerr.Code != codeValidationFailed || len(cerr.Items) != 1 || cerr.Items[0].Index != 1 {
+		t.Fatalf("bulkInitTrades of a closed trade with a hit: %v", err)
+	}
+
+	// An open trade is held once, with its final status
+	if _, err = stub.invoke(deployerCert, "bulkInitTrades", "["+
+		trade("2002", "Blocked Trading Co", `, "blPDF": "BLPDF", "edStatus": "SUBMITTED_BY_EB"`)+","+
+		trade("2003", "E", `, "confirmingBankName": "CB", "confirmingBankCert": "CBCert", "reimbursingBankName": "RB", "reimbursingBankCert": "RBCert"`)+"]"); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary ContractSummary
+	b, err := stub.query([]byte(`IBCert`), "getContractSummary", "2002")
+	if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != statusComplianceHold {
+		t.Fatalf("imported trade with a hit: %s %v", b, err)
+	}
+
+	var participants []Participant
+	b, err = stub.query([]byte(`CBCert`), "getContractParticipants", "2003")
+	if err = json.Unmarshal(b, &participants); err != nil || len(participants) != 6 || participants[4].Role != "ConfirmingBank" || participants[5].Role != "ReimbursingBank" {
+		t.Fatalf("participants of an imported trade: %s %v", b, err)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Confirmation instructions of Tag49
+const (
+	tag49Confirm = "CONFIRM"
+	tag49MayAdd  = "MAY ADD"
+	tag49Without = "WITHOUT"
+)
+
+// Undertakings of the banks to pay a complying presentation
+const (
+	undertakingIssuing    = "ISSUING"
+	undertakingConfirming = "CONFIRMING"
+)
+
+// Statuses of a payment obligation, derived from the status of the export documents
+const (
+	obligationContingent = "CONTINGENT" // until a complying presentation is accepted
+	obligationDue        = "DUE"
+	obligationDischarged = "DISCHARGED"
+	obligationReleased   = "RELEASED" // the documents were rejected
+)
+
+// Confirmation is the undertaking added to the LC by the confirming bank
+type Confirmation struct {
+	UID            string `json:"contractID"`
+	ConfirmingBank string `json:"confirmingBank"`
+	Fee            string `json:"fee"`       // currency and amount, as in Tag32B
+	ChargedTo      string `json:"chargedTo"` // APPLICANT or BENEFICIARY
+	ConfirmedAt    string `json:"confirmedAt"`
+	TxID           string `json:"txID"`
+}
+
+// PaymentObligation is the undertaking of a bank to pay the exporter against complying documents
+type PaymentObligation struct {
+	Bank        string `json:"bank"`
+	Role        string `json:"role"`
+	Undertaking string `json:"undertaking"`
+	Status      string `json:"status"`
+}
+
+var (
+	policyConfirmingBank = accessPolicy{Role: "ConfirmingBank", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		return t.isCallerConfirmingBank(stub, args[:1])
+	}}
+	// Once it confirmed the LC, the confirming bank may honour the presentation in place of the importer bank
+	policyHonouringBank = accessPolicy{Role: "ImporterBank, or the ConfirmingBank once it confirmed the LC", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+		ok, err := t.isCallerImporterBank(stub, args[:1])
+		if err != nil || ok {
+			return ok, err
+		}
+
+		confirmation, err := confirmationStore.Get(stub, args[0])
+		if err != nil || confirmation == nil {
+			return false, err
+		}
+		return t.isCallerConfirmingBank(stub, args[:1])
+	}}
+)
+
+// confirmationInstruction normalizes Tag49, which is WITHOUT if not given
+func confirmationInstruction(lc LC) string {
+	instruction := strings.Join(strings.Fields(strings.ToUpper(lc.Tag49)), " ")
+	if instruction == "" {
+		return tag49Without
+	}
+	return instruction
+}
+
+// obligationStatus maps the export documents status onto the status of the payment obligations
+func obligationStatus(edStatus string) string {
+	switch edStatus {
+	case "", "SUBMITTED_BY_EB":
+		return obligationContingent
+	case "REJECTED_BY_IB":
+		return obligationReleased
+	case "PAYMENT_COMPLETED":
+		return obligationDischarged
+	}
+
+	//ACCEPTED_BY_IB, PAYMENT_INITIATED and PAYMENT_INPROGRESS
+	return obligationDue
+}
+
+// paymentObligations lists the undertakings of the importer bank and, once it confirmed, the confirming bank
+func paymentObligations(bp *BusinessProcess, confirmation *Confirmation, edStatus string) []PaymentObligation {
+	status := obligationStatus(edStatus)
+
+	obligations := []PaymentObligation{
+		{Bank: bp.ImporterBankName, Role: "ImporterBank", Undertaking: undertakingIssuing, Status: status},
+	}
+	if confirmation != nil {
+		obligations = append(obligations, PaymentObligation{Bank: confirmation.ConfirmingBank, Role: "ConfirmingBank", Undertaking: undertakingConfirming, Status: status})
+	}
+
+	return obligations
+}
+
+// confirmLC records the confirmation of the LC by the confirming bank of the contract and its fee
+func (t *SBI) confirmLC(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+	fee := args[1]
+
+	chargedTo := "BENEFICIARY"
+	if len(args) > 2 && args[2] != "" {
+		chargedTo = args[2]
+	}
+	if chargedTo != "APPLICANT" && chargedTo != "BENEFICIARY" {
+		return nil, newError(codeInvalidArgs, "Confirmation charges are for the APPLICANT or the BENEFICIARY. Got: %s.", chargedTo)
+	}
+	if !mtAmountPattern.MatchString(fee) {
+		return nil, newError(codeInvalidArgs, "Fee should be a currency code followed by an amount, such as USD150,00. Got: %s.", fee)
+	}
+
+	bp, err := t.getContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if bp.ConfirmingBankName == "" {
+		return nil, newError(codeInvalidTransition, "Contract %s has no confirming bank.", contractID)
+	}
+
+	lc, err := t.getLC(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if instruction := confirmationInstruction(lc); instruction != tag49Confirm && instruction != tag49MayAdd {
+		return nil, newError(codeInvalidTransition, "The confirmation instruction of contract %s is %s.", contractID, instruction)
+	}
+
+	// A confirmation covers the documents yet to be honoured
+	edStatus, err := t.currentEDStatus(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if edStatus != "" && edStatus != "SUBMITTED_BY_EB" {
+		return nil, newError(codeInvalidTransition, "Export documents of contract %s are %s, the LC can no longer be confirmed.", contractID, edStatus)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation := &Confirmation{
+		UID:            contractID,
+		ConfirmingBank: bp.ConfirmingBankName,
+		Fee:            fee,
+		ChargedTo:      chargedTo,
+		ConfirmedAt:    now,
+		TxID:           stub.GetTxID(),
+	}
+	ok, err := confirmationStore.Insert(stub, confirmation)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(codeDuplicate, "The LC of contract %s is already confirmed.", contractID)
+	}
+
+	err = t.refreshContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(confirmation)
+}
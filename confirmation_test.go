@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfirmLC(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+
+	stub := newMockStub()
+	importerCert, exporterCert := []byte(`ICert`), []byte(`ECert`)
+	importerBankCert, exporterBankCert := []byte(`IBCert`), []byte(`EBCert`)
+	confirmingBankCert := []byte(`CBCert`)
+
+	var po map[string]string
+	if err := json.Unmarshal(testPOJSON, &po); err != nil {
+		t.Fatal(err)
+	}
+	po["Tag49"] = "CONFIRM"
+	poJSON, _ := json.Marshal(po)
+
+	if _, err := stub.invoke(importerBankCert, "initTrade", "1000", string(poJSON), "I", "E", "IB", "EB", "ICert", "ECert", "IBCert", "EBCert", "CB"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("initTrade with a confirming bank without certificate: %v", err)
+	}
+	if _, err := stub.invoke(importerBankCert, "initTrade", "1000", string(poJSON), "I", "E", "IB", "EB", "ICert", "ECert", "IBCert", "EBCert", "CB", "CBCert"); err != nil {
+		t.Fatal(err)
+	}
+	if err := initTrade(stub, importerBankCert, "1001", testPOJSON, "I", "E", "IB", "EB", importerCert, exporterCert, importerBankCert, exporterBankCert); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		caller []byte
+		args   []string
+		code   string
+	}{
+		{"by the importer bank", importerBankCert, []string{"1000", "USD150,00"}, codeAccessDenied},
+		{"with a malformed fee", confirmingBankCert, []string{"1000", "150 dollars"}, codeInvalidArgs},
+		{"with charges for the carrier", confirmingBankCert, []string{"1000", "USD150,00", "CARRIER"}, codeInvalidArgs},
+		{"of a contract without confirming bank", confirmingBankCert, []string{"1001", "USD150,00"}, codeAccessDenied},
+	} {
+		if _, err := stub.invoke(test.caller, "confirmLC", test.args...); errorCode(err) != test.code {
+			t.Errorf("confirmLC %s: %v, want %s", test.name, err, test.code)
+		}
+	}
+
+	// The confirming bank may not honour before it confirms
+	if err := submitED(stub, exporterBankCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := acceptED(stub, confirmingBankCert, "1000"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("acceptED by the confirming bank before the confirmation: %v", err)
+	}
+
+	var confirmation Confirmation
+	b, err := stub.invoke(confirmingBankCert, "confirmLC", "1000", "USD150,00")
+	if err = json.Unmarshal(b, &confirmation); err != nil || confirmation.ConfirmingBank != "CB" || confirmation.ChargedTo != "BENEFICIARY" || confirmation.Fee != "USD150,00" {
+		t.Fatalf("confirmLC: %s %v", b, err)
+	}
+	if _, err = stub.invoke(confirmingBankCert, "confirmLC", "1000", "USD150,00"); errorCode(err) != codeDuplicate {
+		t.Fatalf("second confirmLC: %v", err)
+	}
+
+	var participants []Participant
+	b, err = stub.query(confirmingBankCert, "getContractParticipants", "1000")
+	if err = json.Unmarshal(b, &participants); err != nil || len(participants) != 5 || participants[4] != (Participant{ID: "CB", Role: "ConfirmingBank", Confirmed: true}) {
+		t.Fatalf("getContractParticipants: %s %v", b, err)
+	}
+
+	obligations := func() []PaymentObligation {
+		var summary ContractSummary
+		b, err := stub.query(confirmingBankCert, "getContractSummary", "1000")
+		if err = json.Unmarshal(b, &summary); err != nil {
+			t.Fatal(err)
+		}
+		return summary.Obligations
+	}
+	if o := obligations(); len(o) != 2 || o[1].Undertaking != undertakingConfirming || o[1].Status != obligationContingent {
+		t.Fatalf("obligations before the acceptance: %+v", o)
+	}
+
+	// Once confirmed, the confirming bank honours the presentation
+	if err = acceptED(stub, confirmingBankCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+	if o := obligations(); o[0].Status != obligationDue || o[1].Status != obligationDue {
+		t.Fatalf("obligations after the acceptance: %+v", o)
+	}
+	for i := 0; i < 3; i++ {
+		if err = acceptED(stub, confirmingBankCert, "1000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if o := obligations(); o[1].Status != obligationDischarged {
+		t.Fatalf("obligations after the payment: %+v", o)
+	}
+
+	var contracts ContractsList
+	b, err = stub.query(confirmingBankCert, "listContractsByRole", "ConfirmingBank")
+	if err = json.Unmarshal(b, &contracts); err != nil || len(contracts.Contracts) != 1 || contracts.Contracts[0].ContractID != "1000" {
+		t.Fatalf("listContractsByRole of the confirming bank: %s %v", b, err)
+	}
+}
+
+func TestConfirmLCWithoutInstruction(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	if _, err := stub.invoke(adminCert, "initTrade", "1000", string(testPOJSON), "I", "E", "IB", "EB", "ICert", "ECert", "IBCert", "EBCert", "CB", "CBCert"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(adminCert, "confirmLC", "1000", "USD150,00"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("confirmLC of an LC without confirmation instruction: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// documentType is a type of export document. A new type only needs to be registered, its documents
+// are stored under DOC~code~UID and served by submitDocument and getED.
+type documentType struct {
+	Code          string
+	Description   string
+	Store         DocumentStore
+	Keywords      []string                   // phrases naming the type in a documents required clause (Tag46A)
+	Validate      func(content string) error // nil accepts any content
+	FollowsStatus bool                       // moves with the BL through acceptance and payment
+	Optional      bool                       // only listed in contract summaries once submitted
+}
+
+// documentTypes is the registry of the export document types, in registration order
+var documentTypes []documentType
+
+// edStatusTransitions are the allowed status transitions of the export documents
+var edStatusTransitions = map[string][]string{
+	"SUBMITTED_BY_EB":    {"ACCEPTED_BY_IB", "REJECTED_BY_IB"},
+	"ACCEPTED_BY_IB":     {"PAYMENT_INITIATED"},
+	"PAYMENT_INITIATED":  {"PAYMENT_INPROGRESS"},
+	"PAYMENT_INPROGRESS": {"PAYMENT_COMPLETED"},
+}
+
+func init() {
+	registerDocumentType(documentType{Code: "BL", Description: "Bill of lading", Store: blStore, FollowsStatus: true,
+		Keywords: []string{"BILL OF LADING", "BILLS OF LADING", "B/L"}})
+	registerDocumentType(documentType{Code: "INVOICE", Description: "Commercial invoice", Store: invoiceStore, FollowsStatus: true,
+		Keywords: []string{"INVOICE"}})
+	registerDocumentType(documentType{Code: "PACKINGLIST", Description: "Packing list", Store: plStore, FollowsStatus: true,
+		Keywords: []string{"PACKING LIST"}})
+	registerDocumentType(documentType{Code: "CERT_OF_ORIGIN", Description: "Certificate of origin", Store: DocumentStore{DocType: "CERT_OF_ORIGIN"}, FollowsStatus: true, Optional: true,
+		Keywords: []string{"CERTIFICATE OF ORIGIN"}})
+	registerDocumentType(documentType{Code: "INSURANCE_CERT", Description: "Insurance certificate", Store: DocumentStore{DocType: "INSURANCE_CERT"}, FollowsStatus: true, Optional: true,
+		Keywords: []string{"INSURANCE"}})
+	registerDocumentType(documentType{Code: "INSPECTION_CERT", Description: "Inspection certificate", Store: DocumentStore{DocType: "INSPECTION_CERT"}, FollowsStatus: true, Optional: true,
+		Keywords: []string{"INSPECTION"}})
+	registerDocumentType(documentType{Code: "BENEFICIARY_STATEMENT", Description: "Beneficiary statement", Store: DocumentStore{DocType: "BENEFICIARY_STATEMENT"}, Validate: validateStatement, FollowsStatus: true, Optional: true,
+		Keywords: []string{"BENEFICIARY'S STATEMENT", "BENEFICIARY STATEMENT", "BENEFICIARY'S CERTIFICATE", "BENEFICIARY CERTIFICATE"}})
+}
+
+// registerDocumentType adds a document type to the registry
+func registerDocumentType(dt documentType) {
+	if _, ok := lookupDocumentType(dt.Code); ok {
+		panic("document type " + dt.Code + " is registered twice")
+	}
+	documentTypes = append(documentTypes, dt)
+}
+
+// lookupDocumentType returns the registered document type with the given code
+func lookupDocumentType(code string) (documentType, bool) {
+	for _, dt := range documentTypes {
+		if dt.Code == code {
+			return dt, true
+		}
+	}
+	return documentType{}, false
+}
+
+// documentTypeError is the error returned for an unknown document type
+func documentTypeError(code string) error {
+	codes := make([]string, 0, len(documentTypes))
+	for _, dt := range documentTypes {
+		codes = append(codes, dt.Code)
+	}
+	return newError(codeInvalidArgs, "Document type should be one of %s, got %s.", strings.Join(codes, ", "), code)
+}
+
+// validateStatement accepts a beneficiary statement with some text in it
+func validateStatement(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return newError(codeValidationFailed, "Beneficiary statement is blank.")
+	}
+	return nil
+}
+
+// submit stores a new document of this type for the contract
+func (dt documentType) submit(stub shim.ChaincodeStubInterface, UID string, content string) error {
+	if dt.Validate != nil {
+		err := dt.Validate(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	ok, err := dt.Store.Insert(stub, &Document{UID: UID, Content: []byte(content), Status: "SUBMITTED_BY_EB"})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newError(codeDuplicate, "%s of contract %s already exists.", dt.Code, UID)
+	}
+
+	return nil
+}
+
+// updateStatus moves the document of the contract to newStatus. It returns false if the contract has no
+// document of this type.
+func (dt documentType) updateStatus(stub shim.ChaincodeStubInterface, UID string, newStatus string) (bool, error) {
+	doc, err := dt.Store.Get(stub, UID)
+	if err != nil || doc == nil {
+		return false, err
+	}
+
+	if !containsString(edStatusTransitions[doc.Status], newStatus) {
+		return false, newError(codeInvalidTransition, "This state transition is not allowed.")
+	}
+
+	doc.Status = newStatus
+
+	return true, dt.Store.Replace(stub, doc)
+}
+
+// submitDocument submits an export document of any registered type. Documents can be added to a
+// presentation until the importer bank has examined it.
+func (t *SBI) submitDocument(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	dt, ok := lookupDocumentType(args[1])
+	if !ok {
+		return nil, documentTypeError(args[1])
+	}
+
+	//since all export documents are always kept in the same state, it is enough to check against one.
+	edStatus, err := t.currentEDStatus(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if edStatus != "" && edStatus != "SUBMITTED_BY_EB" {
+		return nil, newError(codeInvalidTransition, "Export documents of contract %s are %s, no document can be added.", contractID, edStatus)
+	}
+
+	err = dt.submit(stub, contractID, args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	var signatureB64, signerCert string
+	if len(args) > 3 {
+		signatureB64 = args[3]
+	}
+	if len(args) > 4 {
+		signerCert = args[4]
+	}
+	if signatureB64 != "" || signerCert != "" {
+		signature, err := signatureArg(signatureB64)
+		if err != nil {
+			return nil, err
+		}
+
+		err = signDocument(stub, contractID, dt.Code, signature, signerCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = t.refreshContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, t.screenContract(stub, contractID)
+}
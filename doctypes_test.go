@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAdditionalDocumentTypes(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	if err := initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Certificates may come before or after the core documents
+	if _, err := stub.invoke(adminCert, "submitDocument", "1000", "CERT_OF_ORIGIN", "COOPDF"); err != nil {
+		t.Fatal(err)
+	}
+	if err := submitED(stub, adminCert, "1000", []byte(`BLPDF`), []byte(`InvoicePDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(adminCert, "submitDocument", `{"contractID": "1000", "docType": "INSURANCE_CERT", "content": "INSPDF"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		args []string
+		code string
+	}{
+		{"unknown type", []string{"1000", "VISA", "PDF"}, codeInvalidArgs},
+		{"second certificate of origin", []string{"1000", "CERT_OF_ORIGIN", "PDF"}, codeDuplicate},
+		{"blank statement", []string{"1000", "BENEFICIARY_STATEMENT", " "}, codeValidationFailed},
+	} {
+		if _, err := stub.invoke(adminCert, "submitDocument", test.args...); errorCode(err) != test.code {
+			t.Errorf("submitDocument with %s: %v", test.name, err)
+		}
+	}
+
+	if b, err := stub.query(nil, "getED", "1000", "CERT_OF_ORIGIN"); err != nil || string(b) != "COOPDF" {
+		t.Fatalf("getED of the certificate of origin: %s %v", b, err)
+	}
+	if _, err := stub.query(nil, "getED", "1000", "INSPECTION_CERT"); errorCode(err) != codeNotFound {
+		t.Fatalf("getED of a document left out: %v", err)
+	}
+
+	// A type without a document is skipped, but some document must move
+	dt, _ := lookupDocumentType("INSPECTION_CERT")
+	if ok, err := dt.updateStatus(stub, "1000", "ACCEPTED_BY_IB"); ok || err != nil {
+		t.Fatalf("updateStatus of a document left out: %v %v", ok, err)
+	}
+	if err := initTrade(stub, adminCert, "1001", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(SBI).updateEDStatus(stub, "1001", "REJECTED_BY_IB"); errorCode(err) != codeNotFound {
+		t.Fatalf("updateEDStatus of a contract without documents: %v", err)
+	}
+
+	// The certificates follow the acceptance of the documents
+	if err := acceptED(stub, adminCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary ContractSummary
+	b, err := getContractSummary(stub, "1000")
+	if err = json.Unmarshal(b, &summary); err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]string)
+	for _, doc := range summary.Documents {
+		statuses[doc.DocType] = doc.Status
+	}
+	if len(summary.Documents) != 5 || statuses["CERT_OF_ORIGIN"] != "ACCEPTED_BY_IB" || statuses["INSURANCE_CERT"] != "ACCEPTED_BY_IB" || statuses["PACKINGLIST"] != "ACCEPTED_BY_IB" {
+		t.Fatalf("documents after the acceptance: %s", b)
+	}
+
+	if _, err = stub.invoke(adminCert, "submitDocument", "1000", "INSPECTION_CERT", "INSPDF"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("submitDocument after the acceptance: %v", err)
+	}
+}
+
+func TestRegisterDocumentType(t *testing.T) {
+	saved := documentTypes
+	defer func() { documentTypes = saved }()
+	documentTypes = append([]documentType(nil), saved...)
+
+	registerDocumentType(documentType{Code: "WEIGHT_CERT", Store: DocumentStore{DocType: "WEIGHT_CERT"}, Optional: true})
+
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+	if err := initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := submitED(stub, adminCert, "1000", []byte(`BLPDF`), []byte(`InvoicePDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(adminCert, "submitDocument", "1000", "WEIGHT_CERT", "WPDF"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Types not following the status keep theirs
+	if err := acceptED(stub, adminCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := (DocumentStore{DocType: "WEIGHT_CERT"}).Get(stub, "1000"); err != nil || doc == nil || doc.Status != "SUBMITTED_BY_EB" {
+		t.Fatalf("weight certificate after the acceptance: %+v %v", doc, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering WEIGHT_CERT twice must panic")
+		}
+	}()
+	registerDocumentType(documentType{Code: "WEIGHT_CERT"})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Entries of the title chain of an electronic BL
+const (
+	titleIssue     = "ISSUE"
+	titleEndorse   = "ENDORSE"
+	titleSurrender = "SURRENDER"
+)
+
+// EBLTitle is the title of the electronic BL of a contract: who holds it and how it got there
+type EBLTitle struct {
+	UID         string          `json:"contractID"`
+	Holder      string          `json:"holder"`
+	ToOrder     bool            `json:"toOrder"`
+	Negotiable  bool            `json:"negotiable"`
+	Surrendered bool            `json:"surrendered"`
+	Chain       []TitleTransfer `json:"chain"`
+}
+
+// TitleTransfer is an entry of the title chain. Signature is the caller's signature of the transaction.
+type TitleTransfer struct {
+	Sequence  int    `json:"sequence"`
+	Type      string `json:"type"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	TxID      string `json:"txID"`
+	Timestamp string `json:"timestamp"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// policyBLHolder lets only the current holder of the electronic BL of the contract act on it
+var policyBLHolder = accessPolicy{Role: "Holder of the electronic BL", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+	title, err := eblStore.Get(stub, args[0])
+	if err != nil || title == nil || title.Holder == "" {
+		return false, err
+	}
+
+	party, err := partyStore.Get(stub, title.Holder)
+	if err != nil || party == nil {
+		return false, err
+	}
+
+	return t.isCaller(stub, party.Cert)
+}}
+
+// policyCertHolder lets only the holder of the certificate given in the cert argument act, so that a party
+// registers itself and its ID is bound to its certificate
+var policyCertHolder = accessPolicy{Role: "Holder of the certificate registered", check: func(t *SBI, stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+	return t.isCaller(stub, []byte(args[2]))
+}}
+
+// registerParty registers a party that can hold electronic BLs. A certificate is registered for one party only.
+func (t *SBI) registerParty(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	parties, err := partyStore.List(stub)
+	if err != nil {
+		return nil, err
+	}
+	for _, party := range parties {
+		if bytes.Equal(party.Cert, []byte(args[2])) {
+			return nil, newError(codeDuplicate, "Certificate is already registered for party %s.", party.ID)
+		}
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := partyStore.Insert(stub, &Party{ID: args[0], Name: args[1], Cert: []byte(args[2]), RegisteredAt: now})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(codeDuplicate, "Party %s is already registered.", args[0])
+	}
+
+	return nil, nil
+}
+
+// registeredParty returns the registered party with the given ID
+func registeredParty(stub shim.ChaincodeStubInterface, ID string) (*Party, error) {
+	party, err := partyStore.Get(stub, ID)
+	if err != nil {
+		return nil, err
+	}
+	if party == nil {
+		return nil, newError(codeNotFound, "Party %s is not registered.", ID)
+	}
+
+	return party, nil
+}
+
+// appendTransfer records a title transfer signed by the caller of the transaction
+func appendTransfer(stub shim.ChaincodeStubInterface, title *EBLTitle, transferType string, from string, to string) error {
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	signature, err := stub.GetCallerMetadata()
+	if err != nil {
+		return newError(codeInternal, "Failed getting metadata. Error %s", err.Error())
+	}
+
+	title.Chain = append(title.Chain, TitleTransfer{
+		Sequence:  len(title.Chain) + 1,
+		Type:      transferType,
+		From:      from,
+		To:        to,
+		TxID:      stub.GetTxID(),
+		Timestamp: now,
+		Signature: signature,
+	})
+
+	return eblStore.Put(stub, title)
+}
+
+// issueEBL turns the submitted BL of the contract into an electronic BL held by a registered party.
+// Only to-order BLs can be negotiable.
+func (t *SBI) issueEBL(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+	holder := args[1]
+	toOrder, err := boolArg(args, 2, "toOrder")
+	if err != nil {
+		return nil, err
+	}
+	negotiable, err := boolArg(args, 3, "negotiable")
+	if err != nil {
+		return nil, err
+	}
+
+	bl, err := blStore.Get(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if bl == nil {
+		return nil, newError(codeNotFound, "BL of contract %s has not been submitted.", contractID)
+	}
+
+	title, err := eblStore.Get(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if title != nil {
+		return nil, newError(codeDuplicate, "Electronic BL of contract %s is already issued.", contractID)
+	}
+
+	if negotiable && !toOrder {
+		return nil, newError(codeInvalidArgs, "A straight BL can not be negotiable.")
+	}
+
+	_, err = registeredParty(stub, holder)
+	if err != nil {
+		return nil, err
+	}
+
+	title = &EBLTitle{UID: contractID, Holder: holder, ToOrder: toOrder, Negotiable: negotiable}
+
+	return nil, appendTransfer(stub, title, titleIssue, "", holder)
+}
+
+// eblTitle returns the title of the electronic BL of the contract, which must not be surrendered
+func eblTitle(stub shim.ChaincodeStubInterface, contractID string) (*EBLTitle, error) {
+	title, err := eblStore.Get(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if title == nil {
+		return nil, newError(codeNotFound, "Electronic BL of contract %s has not been issued.", contractID)
+	}
+	if title.Surrendered {
+		return nil, newError(codeInvalidTransition, "Electronic BL of contract %s is surrendered.", contractID)
+	}
+
+	return title, nil
+}
+
+// endorseBL transfers the title of a negotiable electronic BL from its holder to another registered party
+func (t *SBI) endorseBL(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+	endorsee := args[1]
+
+	title, err := eblTitle(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if !title.Negotiable {
+		return nil, newError(codeInvalidTransition, "Electronic BL of contract %s is not negotiable.", contractID)
+	}
+	if endorsee == title.Holder {
+		return nil, newError(codeInvalidArgs, "Party %s already holds the electronic BL.", endorsee)
+	}
+
+	_, err = registeredParty(stub, endorsee)
+	if err != nil {
+		return nil, err
+	}
+
+	from := title.Holder
+	title.Holder = endorsee
+
+	return nil, appendTransfer(stub, title, titleEndorse, from, endorsee)
+}
+
+// surrenderBL surrenders the electronic BL to the carrier for delivery of the goods. It can not be endorsed afterwards.
+func (t *SBI) surrenderBL(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	title, err := eblTitle(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	from := title.Holder
+	title.Holder = ""
+	title.Surrendered = true
+
+	return nil, appendTransfer(stub, title, titleSurrender, from, "")
+}
+
+// getBLTitleChain returns the holder and the endorsement history of the electronic BL of the contract
+func (t *SBI) getBLTitleChain(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	title, err := eblStore.Get(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if title == nil {
+		return nil, newError(codeNotFound, "Electronic BL of contract %s has not been issued.", args[0])
+	}
+
+	return json.Marshal(title)
+}
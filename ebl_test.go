@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEBLTitleChain(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+
+	stub := newMockStub()
+	importerCert, exporterCert := []byte(`ICert`), []byte(`ECert`)
+	importerBankCert, exporterBankCert := []byte(`IBCert`), []byte(`EBCert`)
+
+	for _, contractID := range []string{"1000", "1001"} {
+		if err := initTrade(stub, importerBankCert, contractID, testPOJSON, "I", "E", "IB", "EB", importerCert, exporterCert, importerBankCert, exporterBankCert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stub.invoke(exporterBankCert, "issueEBL", "1000", "SHIPPER", "true", "true"); errorCode(err) != codeNotFound {
+		t.Fatalf("issueEBL before the BL: %v", err)
+	}
+	for _, contractID := range []string{"1000", "1001"} {
+		if err := submitED(stub, exporterBankCert, contractID, []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Parties register themselves, once
+	for _, party := range [][]string{{"SHIPPER", "E", "ECert"}, {"BANK", "IB", "IBCert"}, {"BUYER", "I", "ICert"}} {
+		if _, err := stub.invoke([]byte(party[2]), "registerParty", party...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stub.invoke([]byte(`OCert`), "registerParty", "BANK", "Other", "OCert"); errorCode(err) != codeDuplicate {
+		t.Fatalf("registering BANK twice: %v", err)
+	}
+	if _, err := stub.invoke([]byte(`OCert`), "registerParty", "OTHER", "Other", "ICert"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("registering the certificate of another party: %v", err)
+	}
+	if _, err := stub.invoke(importerCert, "registerParty", "BUYER2", "I", "ICert"); errorCode(err) != codeDuplicate {
+		t.Fatalf("registering a certificate twice: %v", err)
+	}
+
+	if _, err := stub.invoke(exporterBankCert, "issueEBL", "1000", "NOBODY", "true", "true"); errorCode(err) != codeNotFound {
+		t.Fatalf("issueEBL to an unregistered party: %v", err)
+	}
+	if _, err := stub.invoke(importerBankCert, "issueEBL", "1000", "SHIPPER", "true", "true"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("issueEBL by the importer bank: %v", err)
+	}
+	if _, err := stub.invoke(exporterBankCert, "issueEBL", `{"contractID": "1000", "holder": "SHIPPER", "toOrder": true, "negotiable": true}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(exporterBankCert, "issueEBL", "1000", "SHIPPER", "true", "true"); errorCode(err) != codeDuplicate {
+		t.Fatalf("issueEBL twice: %v", err)
+	}
+
+	// Only the holder endorses, to a registered party
+	if _, err := stub.invoke(importerBankCert, "endorseBL", "1000", "BANK"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("endorseBL by a party not holding the title: %v", err)
+	}
+	if _, err := stub.invoke(exporterCert, "endorseBL", "1000", "NOBODY"); errorCode(err) != codeNotFound {
+		t.Fatalf("endorseBL to an unregistered party: %v", err)
+	}
+	if _, err := stub.invoke(exporterCert, "endorseBL", "1000", "BANK"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(importerBankCert, "endorseBL", "1000", "BUYER"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(importerCert, "surrenderBL", "1000"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(importerCert, "endorseBL", "1000", "BANK"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("endorseBL after the surrender: %v", err)
+	}
+
+	var title EBLTitle
+	b, err := stub.query(exporterCert, "getBLTitleChain", "1000")
+	if err = json.Unmarshal(b, &title); err != nil || !title.Surrendered || title.Holder != "" || len(title.Chain) != 4 {
+		t.Fatalf("getBLTitleChain: %s %v", b, err)
+	}
+	for i, want := range []TitleTransfer{
+		{Type: titleIssue, To: "SHIPPER"},
+		{Type: titleEndorse, From: "SHIPPER", To: "BANK"},
+		{Type: titleEndorse, From: "BANK", To: "BUYER"},
+		{Type: titleSurrender, From: "BUYER"},
+	} {
+		got := title.Chain[i]
+		if got.Sequence != i+1 || got.Type != want.Type || got.From != want.From || got.To != want.To || len(got.Signature) == 0 {
+			t.Errorf("transfer %d: %+v, want %+v", i+1, got, want)
+		}
+	}
+
+	// A straight BL is not negotiable
+	if _, err = stub.invoke(exporterBankCert, "issueEBL", "1001", "SHIPPER", "false", "true"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("issueEBL of a negotiable straight BL: %v", err)
+	}
+	if _, err = stub.invoke(exporterBankCert, "issueEBL", "1001", "SHIPPER"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stub.invoke(exporterCert, "endorseBL", "1001", "BANK"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("endorseBL of a straight BL: %v", err)
+	}
+}
+
+func TestEBLTitleOnClosedAndHeldContracts(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+
+	stub := newMockStub()
+	complianceCert := []byte(`ComplianceCert`)
+	importerCert, exporterCert := []byte(`ICert`), []byte(`ECert`)
+	importerBankCert, exporterBankCert := []byte(`IBCert`), []byte(`EBCert`)
+
+	if _, err := stub.init(string(complianceCert)); err != nil {
+		t.Fatal(err)
+	}
+	for _, contractID := range []string{"1000", "1001"} {
+		if err := initTrade(stub, importerBankCert, contractID, testPOJSON, "I", "E", "IB", "EB", importerCert, exporterCert, importerBankCert, exporterBankCert); err != nil {
+			t.Fatal(err)
+		}
+		if err := submitED(stub, exporterBankCert, contractID, []byte(`BLPDF Vessel Dark Star`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, party := range [][]string{{"SHIPPER", "E", "ECert"}, {"BANK", "IB", "IBCert"}, {"BUYER", "I", "ICert"}} {
+		if _, err := stub.invoke([]byte(party[2]), "registerParty", party...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, contractID := range []string{"1000", "1001"} {
+		if _, err := stub.invoke(exporterBankCert, "issueEBL", contractID, "SHIPPER", "true", "true"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1000 is paid and closed
+	for i := 0; i < 4; i++ {
+		if err := acceptED(stub, importerBankCert, "1000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := closeTrade(stub, importerBankCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1001 is held for its vessel
+	if _, err := stub.invoke(complianceCert, "updateSanctionsList", `[{"type": "VESSEL", "name": "Dark Star"}]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := updatePO(stub, importerBankCert, "1001", testPOJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	for contractID, want := range map[string]string{"1000": statusClosed, "1001": statusComplianceHold} {
+		var summary ContractSummary
+		b, err := stub.query(importerBankCert, "getContractSummary", contractID)
+		if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != want {
+			t.Fatalf("status of contract %s: %s %v", contractID, b, err)
+		}
+
+		// The title still moves and is surrendered for delivery of the goods
+		if _, err = stub.invoke(exporterCert, "endorseBL", contractID, "BANK"); err != nil {
+			t.Fatalf("endorseBL of contract %s: %v", contractID, err)
+		}
+		if _, err = stub.invoke(importerBankCert, "endorseBL", contractID, "BUYER"); err != nil {
+			t.Fatalf("endorseBL of contract %s: %v", contractID, err)
+		}
+		if _, err = stub.invoke(importerCert, "surrenderBL", contractID); err != nil {
+			t.Fatalf("surrenderBL of contract %s: %v", contractID, err)
+		}
+
+		var title EBLTitle
+		b, err = stub.query(exporterCert, "getBLTitleChain", contractID)
+		if err = json.Unmarshal(b, &title); err != nil || !title.Surrendered || len(title.Chain) != 4 {
+			t.Fatalf("getBLTitleChain of contract %s: %s %v", contractID, b, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Error codes returned by Invoke and Query. Client applications branch on them, do not rename.
+const (
+	codeNotFound          = "NOT_FOUND"
+	codeInvalidArgs       = "INVALID_ARGS"
+	codeAccessDenied      = "ACCESS_DENIED"
+	codeInvalidTransition = "INVALID_TRANSITION"
+	codeDuplicate         = "DUPLICATE"
+	codeValidationFailed  = "VALIDATION_FAILED"
+	codeInternal          = "INTERNAL"
+)
+
+// ChaincodeError is an error with a stable code. It is returned to the client serialised as
+// {"code":"NOT_FOUND","message":"..."}.
+type ChaincodeError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Items   []ItemError `json:"items,omitempty"`
+}
+
+// ItemError is the error of one item of a batch request, identified by its position in the batch
+type ItemError struct {
+	Index   int    `json:"index"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ChaincodeError) Error() string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(b)
+}
+
+// newError returns a ChaincodeError with the given code and formatted message
+func newError(code string, format string, args ...interface{}) error {
+	return &ChaincodeError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// toChaincodeError gives an INTERNAL code to errors that have none, such as shim failures
+func toChaincodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ChaincodeError); ok {
+		return err
+	}
+	return &ChaincodeError{Code: codeInternal, Message: err.Error()}
+}
+
+// errorCode returns the code of err, or "" if it has none
+func errorCode(err error) string {
+	if e, ok := err.(*ChaincodeError); ok {
+		return e.Code
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// requestIDField is the optional client-supplied ID of an invoke request. An invoke retried
+// with the same ID returns the result of the first one instead of being applied again.
+var requestIDField = argField{Name: "requestID", Type: argString, Description: "Client-supplied ID making retries of this invoke idempotent, unique within the contract the invoke acts on. " +
+	"Positional invokes pass it as " + requestIDPrefix + "<id> after all the other arguments, optional ones included"}
+
+// requestIDPrefix marks the request ID of a positional invoke, e.g. requestID=req-1
+const requestIDPrefix = "requestID="
+
+// splitRequestID removes the request ID from the positional arguments of an invoke request. A JSON
+// request carries it as its last field. A positional one carries it as an extra argument prefixed
+// with requestID= after all the arguments of the function, so that a last argument of the function
+// starting with the prefix is never taken for it.
+func splitRequestID(fn apiFunction, request []string, args []string) (string, []string) {
+	if fn.Kind != kindInvoke {
+		return "", args
+	}
+	if isJSONRequest(request) {
+		return args[len(args)-1], args[:len(args)-1]
+	}
+
+	last := len(fn.Args)
+	if len(args) == last+1 && strings.HasPrefix(args[last], requestIDPrefix) {
+		return strings.TrimPrefix(args[last], requestIDPrefix), args[:last]
+	}
+
+	return "", args
+}
+
+// requestContract returns the contract a request acts on, or "" if it acts on none. Request IDs are scoped
+// to it, so that the clients of different contracts may pick the same IDs.
+func requestContract(fn apiFunction, args []string) string {
+	if len(fn.Args) == 0 || fn.Args[0].Name != contractIDArg.Name || len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
+
+// requestHash identifies the function and arguments of a request
+func requestHash(function string, args []string) string {
+	b, _ := json.Marshal(append([]string{function}, args...))
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayRequest returns the result of the request with the given ID on the contract if it was already processed.
+// Reusing an ID for a different request is an error.
+func replayRequest(stub shim.ChaincodeStubInterface, contractID string, requestID string, function string, args []string) ([]byte, bool, error) {
+	record, err := requestStore.Get(stub, contractID, requestID)
+	if err != nil {
+		return nil, false, err
+	}
+	if record == nil {
+		return nil, false, nil
+	}
+
+	if record.Function != function || record.ArgsHash != requestHash(function, args) {
+		return nil, false, newError(codeDuplicate, "Request ID %s was already used for a different request.", requestID)
+	}
+
+	myLogger.Debugf("Request %s already processed at %s", requestID, record.ProcessedAt)
+
+	return record.Result, true, nil
+}
+
+// recordRequest stores the result of a processed request under its ID on the contract
+func recordRequest(stub shim.ChaincodeStubInterface, contractID string, requestID string, function string, args []string, result []byte) error {
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	ok, err := requestStore.Insert(stub, &RequestRecord{
+		RequestID:   requestID,
+		ContractID:  contractID,
+		Function:    function,
+		ArgsHash:    requestHash(function, args),
+		Result:      result,
+		ProcessedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newError(codeDuplicate, "Request ID %s was already used.", requestID)
+	}
+
+	return nil
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Namespaces of the ISO 20022 trade services management messages carrying the events of a contract
+const (
+	nsBaselineSubmission = "urn:iso:std:iso:20022:tech:xsd:tsmt.019.001.04" // LC issuance
+	nsBaselineAmendment  = "urn:iso:std:iso:20022:tech:xsd:tsmt.009.001.04" // LC amendment
+	nsDataSetSubmission  = "urn:iso:std:iso:20022:tech:xsd:tsmt.014.001.04" // presentation of the export documents
+
+	// The data sets of tsmt.014 are structured data only, the presented documents travel as supplementary data
+	nsPresentedDocuments = "urn:sbi:xsd:presenteddocuments.001.01"
+)
+
+// Events of a contract with an ISO 20022 representation
+const (
+	eventIssuance     = "issuance"
+	eventAmendment    = "amendment"
+	eventPresentation = "presentation"
+)
+
+// Codes and conventions of the mapping between MT700 and the baseline
+const (
+	isoInstructionMatch = "MTCH"     // InstructionType3Code: match the data sets against the baseline
+	isoServiceLevel1    = "LEV1"     // TradeFinanceService2Code: matching without payment obligation
+	isoSightPayment     = "AT SIGHT" // payment terms of a credit without drafts (42C)
+	isoLotUnit          = "LOT"      // the goods of a credit are a single line item of one lot
+	isoMT700Label       = "MT700 "   // label of the buyer defined information carrying an MT700 field
+
+	maxIsoNameLength    = 70  // Max70Text
+	maxIsoTownLength    = 35  // Max35Text
+	maxIsoContactLength = 35  // Max35Text
+	maxIsoInfoLength    = 140 // Max140Text
+)
+
+// isoMessage is an ISO 20022 XML message
+type isoMessage string
+
+// isoDocument is the Document root of the messages. Only the element of its message is set.
+type isoDocument struct {
+	XMLName    xml.Name
+	Submission *isoBaselineSubmission `xml:"InitlBaselnSubmissn,omitempty"`
+	Amendment  *isoBaselineAmendment  `xml:"BaselnAmdmntReq,omitempty"`
+	DataSet    *isoDataSetSubmission  `xml:"DataSetSubmissn,omitempty"`
+}
+
+// isoMessageID identifies a message
+type isoMessageID struct {
+	ID       string `xml:"Id"`
+	Creation string `xml:"CreDtTm"`
+}
+
+// isoContact is a contact person of a bank
+type isoContact struct {
+	Name string `xml:"Nm"`
+}
+
+// isoBaselineSubmission is the InitialBaselineSubmission carrying the PO at issuance
+type isoBaselineSubmission struct {
+	MessageID          isoMessageID `xml:"SubmissnId"`
+	TxRef              string       `xml:"SubmitrTxRef>Id"`
+	Instruction        string       `xml:"Instr>Tp"`
+	Baseline           isoBaseline  `xml:"Baseln"`
+	BuyerBankContacts  []isoContact `xml:"BuyrBkCtctPrsn"`
+	SellerBankContacts []isoContact `xml:"SellrBkCtctPrsn"`
+}
+
+// isoBaselineAmendment is the BaselineAmendmentRequest carrying the amended PO under a new baseline version
+type isoBaselineAmendment struct {
+	RequestID          isoMessageID `xml:"ReqId"`
+	TxID               string       `xml:"TxId>Id"`
+	TxRef              string       `xml:"SubmitrTxRef>Id"`
+	Baseline           isoBaseline  `xml:"Baseln"`
+	BuyerBankContacts  []isoContact `xml:"BuyrBkCtctPrsn"`
+	SellerBankContacts []isoContact `xml:"SellrBkCtctPrsn"`
+}
+
+// isoBaseline holds the PO. MT700 fields without an element of their own travel as buyer defined information.
+type isoBaseline struct {
+	ID              string            `xml:"SubmitrBaselnId>Id"`
+	Version         int               `xml:"SubmitrBaselnId>Vrsn"`
+	Submitter       string            `xml:"SubmitrBaselnId>Submitr>BIC"`
+	ServiceCode     string            `xml:"SvcCd"`
+	PurchaseOrderID string            `xml:"PurchsOrdrRef>Id"`
+	IssueDate       string            `xml:"PurchsOrdrRef>DtOfIsse"`
+	Buyer           isoParty          `xml:"Buyr"`
+	Seller          isoParty          `xml:"Sellr"`
+	BuyerBank       string            `xml:"BuyrBk>BIC"`
+	SellerBank      string            `xml:"SellrBk>BIC"`
+	Goods           isoGoods          `xml:"Goods"`
+	PaymentTerms    []isoPaymentTerms `xml:"PmtTerms"`
+	CreditorAgent   string            `xml:"SttlmTerms>CdtrAgt>BIC"`
+}
+
+// isoParty is the buyer or the seller with its postal address
+type isoParty struct {
+	Name     string `xml:"Nm"`
+	Street   string `xml:"PstlAdr>StrtNm,omitempty"`
+	PostCode string `xml:"PstlAdr>PstCdId"`
+	Town     string `xml:"PstlAdr>TwnNm"`
+	Country  string `xml:"PstlAdr>Ctry"`
+}
+
+// isoGoods are the goods of the baseline, a single line item for the whole credit
+type isoGoods struct {
+	LineItems   []isoLineItem `xml:"ComrclLineItms"`
+	TotalAmount isoAmount     `xml:"LineItmsTtlAmt"`
+	NetAmount   isoAmount     `xml:"TtlNetAmt"`
+	BuyerInfo   []isoUserInfo `xml:"BuyrDfndInf"`
+}
+
+// isoLineItem is a commercial line item
+type isoLineItem struct {
+	ID       string               `xml:"LineItmId"`
+	Unit     string               `xml:"Qty>UnitOfMeasr>OthrUnitOfMeasr"`
+	Quantity string               `xml:"Qty>Val"`
+	Product  string               `xml:"ProdNm,omitempty"`
+	Schedule *isoShipmentSchedule `xml:"ShipmntSchdl,omitempty"`
+	Amount   isoAmount            `xml:"TtlAmt"`
+}
+
+// isoShipmentSchedule is the shipment date range of a line item
+type isoShipmentSchedule struct {
+	LatestShipmentDate string `xml:"ShipmntDtRg>LatstShipmntDt"`
+}
+
+// isoAmount is an amount with its currency
+type isoAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// isoPaymentTerms are the payment terms of the baseline, always for the full amount
+type isoPaymentTerms struct {
+	Other      string `xml:"OthrPmtTerms"`
+	Percentage string `xml:"AmtOrPctg>Pctg"`
+}
+
+// isoUserInfo is buyer defined information. Long MT700 fields take several under the same label.
+type isoUserInfo struct {
+	Label string `xml:"Labl"`
+	Info  string `xml:"Inf"`
+}
+
+// isoDataSetSubmission is the DataSetSubmission presenting the export documents under a contract
+type isoDataSetSubmission struct {
+	MessageID         isoMessageID           `xml:"SubmissnId"`
+	TxID              string                 `xml:"RltdTxRefs>TxId"`
+	PurchaseOrderID   string                 `xml:"RltdTxRefs>PurchsOrdrRef>Id"`
+	PurchaseOrderDate string                 `xml:"RltdTxRefs>PurchsOrdrRef>DtOfIsse"`
+	CommonRef         string                 `xml:"CmonSubmissnRef>Id"`
+	Instruction       string                 `xml:"Instr>Tp"`
+	BuyerBank         string                 `xml:"BuyrBk>BIC"`
+	SellerBank        string                 `xml:"SellrBk>BIC"`
+	Supplementary     []isoSupplementaryData `xml:"SplmtryData"`
+}
+
+// isoSupplementaryData is supplementary data. Its envelope may hold the presented documents.
+type isoSupplementaryData struct {
+	Place    string      `xml:"PlcAndNm,omitempty"`
+	Envelope isoEnvelope `xml:"Envlp"`
+}
+
+// isoEnvelope is the envelope of supplementary data. Envelopes of other namespaces are ignored.
+type isoEnvelope struct {
+	Documents *isoPresentedDocuments `xml:"urn:sbi:xsd:presenteddocuments.001.01 Docs"`
+}
+
+// isoPresentedDocuments are the documents presented under a contract
+type isoPresentedDocuments struct {
+	Documents []isoPresentedDocument `xml:"Doc"`
+}
+
+// isoPresentedDocument is a presented document. Its type is a registered document type, its content base64.
+type isoPresentedDocument struct {
+	Type    string `xml:"DocTp"`
+	Content string `xml:"AttchdBinryFile>InclBinryObj"`
+}
+
+// isoTrade is what the messages of a contract carry besides its PO and its documents
+type isoTrade struct {
+	ContractID        string
+	MessageID         isoMessageID
+	BuyerBankContact  string
+	SellerBankContact string
+	Version           int // baseline version, 1 at issuance
+}
+
+// isoMappedTags are the MT700 fields mapped to elements of the baseline. 44C is mapped too when it is a date.
+var isoMappedTags = map[string]bool{
+	"20": true, "31C": true, "50": true, "59": true, "32B": true, "42C": true,
+}
+
+var (
+	mtDatePattern    = regexp.MustCompile(`^([0-9]{2})([0-9]{2})([0-9]{2})$`)
+	isoDatePattern   = regexp.MustCompile(`^20([0-9]{2})-([0-9]{2})-([0-9]{2})$`)
+	mtAmountPattern  = regexp.MustCompile(`^([A-Z]{3})([0-9]+(,[0-9]*)?)$`)
+	postalCodeFormat = regexp.MustCompile(`^[0-9A-Z-]*[0-9][0-9A-Z-]*$`)
+)
+
+// isoDate converts an MT YYMMDD date to an ISO date. Other values are kept as they are.
+func isoDate(date string) string {
+	if m := mtDatePattern.FindStringSubmatch(date); m != nil {
+		return "20" + m[1] + "-" + m[2] + "-" + m[3]
+	}
+	return date
+}
+
+// mtDate converts an ISO date to an MT YYMMDD date. Other values are kept as they are.
+func mtDate(date string) string {
+	if m := isoDatePattern.FindStringSubmatch(date); m != nil {
+		return m[1] + m[2] + m[3]
+	}
+	return date
+}
+
+// isISO20022Message reports whether a PO argument is an XML message
+func isISO20022Message(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "<")
+}
+
+// truncate cuts s to at most n characters
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// userDefinedInfo splits an MT700 field into buyer defined information of at most 140 characters
+func userDefinedInfo(tag string, value string) []isoUserInfo {
+	var infos []isoUserInfo
+	for runes := []rune(value); len(runes) != 0; {
+		n := len(runes)
+		if n > maxIsoInfoLength {
+			n = maxIsoInfoLength
+		}
+		infos = append(infos, isoUserInfo{Label: isoMT700Label + tag, Info: string(runes[:n])})
+		runes = runes[n:]
+	}
+
+	return infos
+}
+
+// isoPartyFromMT maps an MT700 name and address (50 or 59) to a party. The first line is the name, the last
+// the town with its postal code and the lines between the street. The country is the one of the party's bank.
+func isoPartyFromMT(tag string, value string, bank string) (isoParty, []string) {
+	var party isoParty
+	var problems []string
+
+	lines := strings.Split(value, "\n")
+	if len(lines) < 2 {
+		return party, []string{tag + " needs a name and a town line"}
+	}
+
+	party.Name = lines[0]
+	party.Street = strings.Join(lines[1:len(lines)-1], "\n")
+	party.Town = lines[len(lines)-1]
+	for _, word := range strings.Fields(party.Town) {
+		if postalCodeFormat.MatchString(word) {
+			party.PostCode = word
+			break
+		}
+	}
+	if bicPattern.MatchString(bank) {
+		party.Country = bank[4:6]
+	}
+
+	if party.Name == "" || utf8.RuneCountInString(party.Name) > maxIsoNameLength {
+		problems = append(problems, tag+" name should have 1 to 70 characters")
+	}
+	if utf8.RuneCountInString(party.Street) > maxIsoNameLength {
+		problems = append(problems, tag+" street should have at most 70 characters")
+	}
+	if utf8.RuneCountInString(party.Town) > maxIsoTownLength {
+		problems = append(problems, tag+" town should have at most 35 characters")
+	}
+	if party.PostCode == "" {
+		problems = append(problems, tag+" town line has no postal code")
+	}
+
+	return party, problems
+}
+
+// mtFromIsoParty maps a party back to an MT700 name and address
+func mtFromIsoParty(party isoParty) string {
+	lines := []string{party.Name}
+	if party.Street != "" {
+		lines = append(lines, party.Street)
+	}
+	return strings.Join(append(lines, party.Town), "\n")
+}
+
+// isoReferences checks the MT700 fields every message refers to: the credit number, its date of issue and the banks
+func isoReferences(lc *LC) []string {
+	var problems []string
+
+	if lc.Tag20 == "" || utf8.RuneCountInString(lc.Tag20) > 35 {
+		problems = append(problems, "20 should have 1 to 35 characters")
+	}
+	if !isoDatePattern.MatchString(isoDate(lc.Tag31C)) {
+		problems = append(problems, "31C should be a YYMMDD date")
+	}
+	if !bicPattern.MatchString(lc.Sender) {
+		problems = append(problems, "sender should be the BIC of the issuing bank")
+	}
+	if !bicPattern.MatchString(lc.Receiver) {
+		problems = append(problems, "receiver should be the BIC of the advising bank")
+	}
+
+	return problems
+}
+
+// baselineFromLC maps a PO to a baseline. It returns the fields of the PO the baseline can not represent.
+func baselineFromLC(lc *LC, version int) (isoBaseline, []string) {
+	problems := isoReferences(lc)
+
+	buyer, buyerProblems := isoPartyFromMT("50", lc.Tag50, lc.Sender)
+	seller, sellerProblems := isoPartyFromMT("59", lc.Tag59, lc.Receiver)
+	problems = append(append(problems, buyerProblems...), sellerProblems...)
+
+	var amount isoAmount
+	if m := mtAmountPattern.FindStringSubmatch(lc.Tag32B); m != nil {
+		amount = isoAmount{Currency: m[1], Value: strings.TrimSuffix(strings.Replace(m[2], ",", ".", 1), ".")}
+	} else {
+		problems = append(problems, "32B should be a currency code and an amount")
+	}
+
+	terms := lc.Tag42C
+	if terms == "" {
+		terms = isoSightPayment
+	}
+	if utf8.RuneCountInString(terms) > maxIsoInfoLength {
+		problems = append(problems, "42C should have at most 140 characters")
+	}
+
+	item := isoLineItem{
+		ID:       "1",
+		Unit:     isoLotUnit,
+		Quantity: "1",
+		Product:  truncate(strings.TrimPrefix(strings.Split(lc.Tag45A, "\n")[0], "+"), maxIsoNameLength),
+		Amount:   amount,
+	}
+	if mtDatePattern.MatchString(lc.Tag44C) {
+		item.Schedule = &isoShipmentSchedule{LatestShipmentDate: isoDate(lc.Tag44C)}
+	}
+
+	baseline := isoBaseline{
+		ID:              lc.Tag20,
+		Version:         version,
+		Submitter:       lc.Sender,
+		ServiceCode:     isoServiceLevel1,
+		PurchaseOrderID: lc.Tag20,
+		IssueDate:       isoDate(lc.Tag31C),
+		Buyer:           buyer,
+		Seller:          seller,
+		BuyerBank:       lc.Sender,
+		SellerBank:      lc.Receiver,
+		Goods:           isoGoods{LineItems: []isoLineItem{item}, TotalAmount: amount, NetAmount: amount},
+		PaymentTerms:    []isoPaymentTerms{{Other: terms, Percentage: "100"}},
+		CreditorAgent:   lc.Receiver,
+	}
+
+	for _, field := range mt700Fields {
+		if isoMappedTags[field.Tag] || (field.Tag == "44C" && item.Schedule != nil) {
+			continue
+		}
+		baseline.Goods.BuyerInfo = append(baseline.Goods.BuyerInfo, userDefinedInfo(field.Tag, lcField(lc, field.Field))...)
+	}
+
+	return baseline, problems
+}
+
+// lcFromBaseline maps a baseline back to a PO
+func lcFromBaseline(baseline *isoBaseline) (*LC, error) {
+	lc := &LC{
+		Sender:   baseline.BuyerBank,
+		Receiver: baseline.SellerBank,
+		Tag20:    baseline.ID,
+		Tag31C:   mtDate(baseline.IssueDate),
+		Tag50:    mtFromIsoParty(baseline.Buyer),
+		Tag59:    mtFromIsoParty(baseline.Seller),
+	}
+	if lc.Tag20 == "" {
+		lc.Tag20 = baseline.PurchaseOrderID
+	}
+
+	if amount := baseline.Goods.TotalAmount; amount.Currency != "" {
+		value := strings.Replace(amount.Value, ".", ",", 1)
+		if !strings.Contains(value, ",") {
+			value += ","
+		}
+		lc.Tag32B = amount.Currency + value
+	}
+	if len(baseline.PaymentTerms) != 0 && baseline.PaymentTerms[0].Other != isoSightPayment {
+		lc.Tag42C = baseline.PaymentTerms[0].Other
+	}
+	if len(baseline.Goods.LineItems) != 0 && baseline.Goods.LineItems[0].Schedule != nil {
+		lc.Tag44C = mtDate(baseline.Goods.LineItems[0].Schedule.LatestShipmentDate)
+	}
+
+	// Long fields are split over several pieces of information with the same label
+	values := make(map[string]string)
+	var tags []string
+	for _, info := range baseline.Goods.BuyerInfo {
+		if !strings.HasPrefix(info.Label, isoMT700Label) {
+			continue
+		}
+		tag := strings.TrimPrefix(info.Label, isoMT700Label)
+		if _, ok := values[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		values[tag] += info.Info
+	}
+	for _, tag := range tags {
+		if !setLCField(lc, tag, values[tag]) {
+			return nil, newError(codeInvalidArgs, "Invalid ISO 20022 baseline: MT700 field %s is not supported.", tag)
+		}
+	}
+
+	return lc, nil
+}
+
+// parseISO20022Baseline converts an issuance or amendment message to a PO
+func parseISO20022Baseline(msg string) (*LC, error) {
+	var doc isoDocument
+	err := xml.Unmarshal([]byte(msg), &doc)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Invalid ISO 20022 message: %s", err.Error())
+	}
+
+	switch {
+	case doc.XMLName.Space == nsBaselineSubmission && doc.Submission != nil:
+		return lcFromBaseline(&doc.Submission.Baseline)
+	case doc.XMLName.Space == nsBaselineAmendment && doc.Amendment != nil:
+		return lcFromBaseline(&doc.Amendment.Baseline)
+	}
+
+	return nil, newError(codeInvalidArgs, "ISO 20022 message %s is not a baseline submission or amendment.", doc.XMLName.Space)
+}
+
+// renderISO20022 renders an event of a contract as an ISO 20022 message. The PO is checked first, fields the
+// message can not carry make it fail with VALIDATION_FAILED.
+func renderISO20022(event string, trade isoTrade, lc *LC, docs []isoPresentedDocument) (string, error) {
+	var doc isoDocument
+	var problems []string
+
+	buyerBankContacts := []isoContact{{Name: truncate(trade.BuyerBankContact, maxIsoContactLength)}}
+	sellerBankContacts := []isoContact{{Name: truncate(trade.SellerBankContact, maxIsoContactLength)}}
+
+	switch event {
+	case eventIssuance:
+		var baseline isoBaseline
+		baseline, problems = baselineFromLC(lc, trade.Version)
+		doc.XMLName = xml.Name{Space: nsBaselineSubmission, Local: "Document"}
+		doc.Submission = &isoBaselineSubmission{
+			MessageID:          trade.MessageID,
+			TxRef:              trade.ContractID,
+			Instruction:        isoInstructionMatch,
+			Baseline:           baseline,
+			BuyerBankContacts:  buyerBankContacts,
+			SellerBankContacts: sellerBankContacts,
+		}
+	case eventAmendment:
+		var baseline isoBaseline
+		baseline, problems = baselineFromLC(lc, trade.Version)
+		doc.XMLName = xml.Name{Space: nsBaselineAmendment, Local: "Document"}
+		doc.Amendment = &isoBaselineAmendment{
+			RequestID:          trade.MessageID,
+			TxID:               trade.ContractID,
+			TxRef:              trade.ContractID,
+			Baseline:           baseline,
+			BuyerBankContacts:  buyerBankContacts,
+			SellerBankContacts: sellerBankContacts,
+		}
+	case eventPresentation:
+		problems = isoReferences(lc)
+		doc.XMLName = xml.Name{Space: nsDataSetSubmission, Local: "Document"}
+		doc.DataSet = &isoDataSetSubmission{
+			MessageID:         trade.MessageID,
+			TxID:              trade.ContractID,
+			PurchaseOrderID:   lc.Tag20,
+			PurchaseOrderDate: isoDate(lc.Tag31C),
+			CommonRef:         trade.ContractID,
+			Instruction:       isoInstructionMatch,
+			BuyerBank:         lc.Sender,
+			SellerBank:        lc.Receiver,
+			Supplementary:     []isoSupplementaryData{{Envelope: isoEnvelope{Documents: &isoPresentedDocuments{Documents: docs}}}},
+		}
+	default:
+		return "", newError(codeInvalidArgs, "Event should be %s, %s or %s.", eventIssuance, eventAmendment, eventPresentation)
+	}
+	if len(problems) != 0 {
+		return "", newError(codeValidationFailed, "PO of contract %s can not be represented in ISO 20022: %s.", trade.ContractID, strings.Join(problems, "; "))
+	}
+
+	b, err := xml.MarshalIndent(&doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(b), nil
+}
+
+// getISO20022Message renders the issuance, the amendment or the presentation of the contract as an ISO 20022 message.
+// The issuance carries the PO as issued, the amendment the current PO as a new version of the baseline.
+func (t *SBI) getISO20022Message(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+	event := args[1]
+
+	bp, err := t.getContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	trade := isoTrade{
+		ContractID:        contractID,
+		MessageID:         isoMessageID{ID: stub.GetTxID(), Creation: now},
+		BuyerBankContact:  bp.ImporterBankName,
+		SellerBankContact: bp.ExporterBankName,
+		Version:           1,
+	}
+
+	amendments, err := amendmentStore.Get(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	var po []byte
+	var docs []isoPresentedDocument
+	switch {
+	case event == eventIssuance && amendments != nil:
+		po = amendments.Issued
+	case event == eventAmendment && amendments == nil:
+		return nil, newError(codeNotFound, "PO of contract %s has not been amended.", contractID)
+	case event == eventAmendment:
+		trade.Version = amendments.Version
+	case event == eventPresentation:
+		for _, dt := range documentTypes {
+			doc, err := dt.Store.Get(stub, contractID)
+			if err != nil {
+				return nil, err
+			}
+			if doc != nil {
+				docs = append(docs, isoPresentedDocument{Type: dt.Code, Content: base64.StdEncoding.EncodeToString(doc.Content)})
+			}
+		}
+		if len(docs) == 0 {
+			return nil, newError(codeNotFound, "Export documents of contract %s have not been submitted.", contractID)
+		}
+	}
+	if po == nil {
+		po, err = t.getPO(stub, []string{contractID})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lc LC
+	err = json.Unmarshal(po, &lc)
+	if err != nil {
+		return nil, newError(codeValidationFailed, "PO of contract %s is not valid JSON: %s", contractID, err.Error())
+	}
+
+	msg, err := renderISO20022(event, trade, &lc, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(msg), nil
+}
+
+// submitEDAsISO20022 submits the export documents presented in an ISO 20022 data set submission
+func (t *SBI) submitEDAsISO20022(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	var doc isoDocument
+	err := xml.Unmarshal([]byte(args[1]), &doc)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Invalid ISO 20022 message: %s", err.Error())
+	}
+	if doc.XMLName.Space != nsDataSetSubmission || doc.DataSet == nil {
+		return nil, newError(codeInvalidArgs, "ISO 20022 message %s is not a data set submission.", doc.XMLName.Space)
+	}
+	if doc.DataSet.TxID != "" && doc.DataSet.TxID != contractID {
+		return nil, newError(codeInvalidArgs, "Data set submission refers to contract %s, not %s.", doc.DataSet.TxID, contractID)
+	}
+
+	var documents []isoPresentedDocument
+	for _, data := range doc.DataSet.Supplementary {
+		if data.Envelope.Documents != nil {
+			documents = append(documents, data.Envelope.Documents.Documents...)
+		}
+	}
+	if len(documents) == 0 {
+		return nil, newError(codeInvalidArgs, "Data set submission presents no documents.")
+	}
+
+	// Positions of the documents in the arguments of submitED, the other types are submitted on their own
+	positions := map[string]int{blStore.DocType: 1, invoiceStore.DocType: 2, plStore.DocType: 3}
+	edArgs := []string{contractID, "", "", ""}
+	var others [][]string
+	presented := make(map[string]bool)
+	for _, document := range documents {
+		if _, ok := lookupDocumentType(document.Type); !ok {
+			return nil, documentTypeError(document.Type)
+		}
+		if presented[document.Type] {
+			return nil, newError(codeInvalidArgs, "Document %s is presented twice.", document.Type)
+		}
+		presented[document.Type] = true
+
+		// base64Binary allows whitespace between the characters
+		content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(document.Content), ""))
+		if err != nil {
+			return nil, newError(codeInvalidArgs, "Document %s is not valid base64: %s", document.Type, err.Error())
+		}
+		if len(content) == 0 {
+			return nil, newError(codeInvalidArgs, "Document %s is empty.", document.Type)
+		}
+
+		if i, ok := positions[document.Type]; ok {
+			edArgs[i] = string(content)
+		} else {
+			others = append(others, []string{contractID, document.Type, string(content)})
+		}
+	}
+
+	_, err = t.submitED(stub, edArgs)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range others {
+		_, err = t.submitDocument(stub, other)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// isoTestMT700 is testMT700 with the postal codes the ISO 20022 addresses require
+var isoTestMT700 = strings.NewReplacer("NEW YORK\r\n:59:", "NEW YORK NY 10017\r\n:59:", "FRANKFURT\r\n", "60311 FRANKFURT\r\n").Replace(testMT700)
+
+// xsdElement is an element of the tsmt XSDs as transcribed from the published schemas: its children in
+// sequence, or the choice between them, with their occurrences, or the pattern of its text
+type xsdElement struct {
+	Name     string
+	Min, Max int // Max 0 is unbounded
+	Choice   bool
+	Any      bool
+	Text     *regexp.Regexp
+	Children []xsdElement
+}
+
+func xsdSeq(name string, min, max int, children ...xsdElement) xsdElement {
+	return xsdElement{Name: name, Min: min, Max: max, Children: children}
+}
+
+func xsdChoice(name string, min, max int, children ...xsdElement) xsdElement {
+	return xsdElement{Name: name, Min: min, Max: max, Choice: true, Children: children}
+}
+
+func xsdText(name string, min, max int, text *regexp.Regexp) xsdElement {
+	return xsdElement{Name: name, Min: min, Max: max, Text: text}
+}
+
+func xsdAny(name string, min, max int) xsdElement {
+	return xsdElement{Name: name, Min: min, Max: max, Any: true}
+}
+
+// Simple types of the tsmt XSDs
+var (
+	xsdMax16Text   = regexp.MustCompile(`^(?s).{1,16}$`)
+	xsdMax35Text   = regexp.MustCompile(`^(?s).{1,35}$`)
+	xsdMax70Text   = regexp.MustCompile(`^(?s).{1,70}$`)
+	xsdMax140Text  = regexp.MustCompile(`^(?s).{1,140}$`)
+	xsdMax350Text  = regexp.MustCompile(`^(?s).{1,350}$`)
+	xsdISODate     = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+	xsdISODateTime = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	xsdBIC         = regexp.MustCompile(`^[A-Z]{6}[A-Z2-9][A-NP-Z0-9]([A-Z0-9]{3})?$`)
+	xsdCountryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+	xsdNumber      = regexp.MustCompile(`^[0-9]{1,18}$`)
+	xsdDecimal     = regexp.MustCompile(`^[0-9]{1,18}(\.[0-9]{1,17})?$`)
+	xsdAmount      = regexp.MustCompile(`^[0-9]{1,13}(\.[0-9]{1,5})?$`)
+	xsdBoolean     = regexp.MustCompile(`^(true|false)$`)
+	xsdBase64      = regexp.MustCompile(`^[A-Za-z0-9+/=\s]+$`)
+	xsdInstruction = regexp.MustCompile(`^(MTCH|PMTC)$`)
+	xsdServiceCode = regexp.MustCompile(`^(LEV1|LEV2|LEV3)$`)
+)
+
+var (
+	xsdMessageIdentification = []xsdElement{xsdText("Id", 1, 1, xsdMax35Text), xsdText("CreDtTm", 1, 1, xsdISODateTime)}
+	xsdBICIdentification     = []xsdElement{xsdText("BIC", 1, 1, xsdBIC)}
+	xsdPurchaseOrderRef      = []xsdElement{xsdText("Id", 1, 1, xsdMax35Text), xsdText("DtOfIsse", 1, 1, xsdISODate)}
+	xsdContact               = []xsdElement{
+		xsdText("NmPrfx", 0, 1, xsdMax35Text), xsdText("Nm", 1, 1, xsdMax35Text), xsdText("GvnNm", 0, 1, xsdMax35Text),
+		xsdText("Role", 0, 1, xsdMax35Text), xsdText("PhneNb", 0, 1, xsdMax35Text), xsdText("FaxNb", 0, 1, xsdMax35Text),
+		xsdText("EmailAdr", 0, 1, xsdMax350Text),
+	}
+	xsdParty = []xsdElement{
+		xsdText("Nm", 1, 1, xsdMax70Text),
+		xsdAny("PrtryId", 0, 1),
+		xsdSeq("PstlAdr", 1, 1,
+			xsdText("StrtNm", 0, 1, xsdMax70Text),
+			xsdText("PstCdId", 1, 1, xsdMax16Text),
+			xsdText("TwnNm", 1, 1, xsdMax35Text),
+			xsdText("CtrySubDvsn", 0, 1, xsdMax35Text),
+			xsdText("Ctry", 1, 1, xsdCountryCode),
+		),
+	}
+	xsdLineItem = []xsdElement{
+		xsdText("GoodsId", 0, 1, xsdMax70Text),
+		xsdSeq("PurchsOrdrRef", 0, 1, xsdPurchaseOrderRef...),
+		xsdText("FnlSubmissn", 0, 1, xsdBoolean),
+		xsdSeq("ComrclLineItms", 1, 0,
+			xsdText("LineItmId", 1, 1, xsdMax70Text),
+			xsdSeq("Qty", 1, 1,
+				xsdChoice("UnitOfMeasr", 1, 1, xsdText("UnitOfMeasrCd", 1, 1, xsdMax35Text), xsdText("OthrUnitOfMeasr", 1, 1, xsdMax35Text)),
+				xsdText("Val", 1, 1, xsdDecimal),
+				xsdText("Fctr", 0, 1, xsdDecimal),
+			),
+			xsdAny("QtyTlrnc", 0, 1), xsdAny("UnitPric", 0, 1), xsdAny("PricTlrnc", 0, 1),
+			xsdText("ProdNm", 0, 1, xsdMax70Text),
+			xsdAny("ProdIdr", 0, 0), xsdAny("ProdChrtcs", 0, 0), xsdAny("ProdCtgy", 0, 0), xsdAny("ProdOrgn", 0, 1),
+			xsdChoice("ShipmntSchdl", 0, 1,
+				xsdSeq("ShipmntDtRg", 1, 1, xsdText("EarlstShipmntDt", 0, 1, xsdISODate), xsdText("LatstShipmntDt", 0, 1, xsdISODate)),
+				xsdAny("ShipmntSubSchdl", 1, 0),
+			),
+			xsdAny("RtgSummry", 0, 1), xsdAny("Incotrms", 0, 1), xsdAny("Adjstmnt", 0, 0), xsdAny("FrghtChrgs", 0, 1), xsdAny("Tax", 0, 0),
+			xsdText("TtlAmt", 1, 1, xsdAmount),
+		),
+		xsdText("LineItmsTtlAmt", 1, 1, xsdAmount),
+		xsdAny("RtgSummry", 0, 1), xsdAny("Incotrms", 0, 1), xsdAny("Adjstmnt", 0, 0), xsdAny("FrghtChrgs", 0, 1), xsdAny("Tax", 0, 0),
+		xsdText("TtlNetAmt", 1, 1, xsdAmount),
+		xsdSeq("BuyrDfndInf", 0, 0, xsdText("Labl", 1, 1, xsdMax35Text), xsdText("Inf", 1, 1, xsdMax140Text)),
+		xsdSeq("SellrDfndInf", 0, 0, xsdText("Labl", 1, 1, xsdMax35Text), xsdText("Inf", 1, 1, xsdMax140Text)),
+	}
+	xsdBaseline = xsdSeq("Baseln", 1, 1,
+		xsdSeq("SubmitrBaselnId", 1, 1, xsdText("Id", 1, 1, xsdMax35Text), xsdText("Vrsn", 1, 1, xsdNumber), xsdSeq("Submitr", 1, 1, xsdBICIdentification...)),
+		xsdText("SvcCd", 1, 1, xsdServiceCode),
+		xsdSeq("PurchsOrdrRef", 1, 1, xsdPurchaseOrderRef...),
+		xsdSeq("Buyr", 1, 1, xsdParty...),
+		xsdSeq("Sellr", 1, 1, xsdParty...),
+		xsdSeq("BuyrBk", 1, 1, xsdBICIdentification...),
+		xsdSeq("SellrBk", 1, 1, xsdBICIdentification...),
+		xsdSeq("BuyrSdSubmitgBk", 0, 0, xsdBICIdentification...),
+		xsdSeq("SellrSdSubmitgBk", 0, 0, xsdBICIdentification...),
+		xsdSeq("BllTo", 0, 1, xsdParty...),
+		xsdSeq("ShipTo", 0, 1, xsdParty...),
+		xsdSeq("Consgn", 0, 1, xsdParty...),
+		xsdSeq("Goods", 1, 1, xsdLineItem...),
+		xsdSeq("PmtTerms", 1, 0,
+			xsdChoice("", 1, 1, xsdText("OthrPmtTerms", 1, 1, xsdMax140Text), xsdAny("PmtCd", 1, 1)),
+			xsdChoice("AmtOrPctg", 1, 1, xsdText("FxdAmt", 1, 1, xsdAmount), xsdText("Pctg", 1, 1, xsdDecimal)),
+		),
+		xsdSeq("SttlmTerms", 1, 1,
+			xsdChoice("CdtrAgt", 0, 1, xsdText("BIC", 1, 1, xsdBIC), xsdAny("NmAndAdr", 1, 1)),
+			xsdAny("CdtrAcct", 0, 1),
+		),
+		xsdAny("PmtOblgtn", 0, 0),
+		xsdText("LatstMtchDt", 0, 1, xsdISODate),
+		xsdSeq("ComrclDataSetReqrd", 0, 1, xsdSeq("Submitr", 1, 0, xsdBICIdentification...)),
+		xsdSeq("TrnsprtDataSetReqrd", 0, 1, xsdSeq("Submitr", 1, 0, xsdBICIdentification...)),
+		xsdAny("InsrncDataSetReqrd", 0, 1),
+		xsdAny("CertDataSetReqrd", 0, 0),
+		xsdAny("OthrCertDataSetReqrd", 0, 0),
+		xsdAny("IntntToPayXpctd", 0, 1),
+	)
+	xsdBankContacts = []xsdElement{
+		xsdSeq("BuyrCtctPrsn", 0, 0, xsdContact...),
+		xsdSeq("SellrCtctPrsn", 0, 0, xsdContact...),
+		xsdSeq("BuyrBkCtctPrsn", 1, 0, xsdContact...),
+		xsdSeq("SellrBkCtctPrsn", 1, 0, xsdContact...),
+		xsdAny("OthrBkCtctPrsn", 0, 0),
+	}
+
+	// isoSchemas are the messages by namespace
+	isoSchemas = map[string]xsdElement{
+		nsBaselineSubmission: xsdSeq("InitlBaselnSubmissn", 1, 1, append([]xsdElement{
+			xsdSeq("SubmissnId", 1, 1, xsdMessageIdentification...),
+			xsdSeq("SubmitrTxRef", 1, 1, xsdText("Id", 1, 1, xsdMax35Text)),
+			xsdSeq("Instr", 1, 1, xsdText("Tp", 1, 1, xsdInstruction)),
+			xsdBaseline,
+		}, xsdBankContacts...)...),
+		nsBaselineAmendment: xsdSeq("BaselnAmdmntReq", 1, 1, append([]xsdElement{
+			xsdSeq("ReqId", 1, 1, xsdMessageIdentification...),
+			xsdSeq("TxId", 1, 1, xsdText("Id", 1, 1, xsdMax35Text)),
+			xsdSeq("SubmitrTxRef", 0, 1, xsdText("Id", 1, 1, xsdMax35Text)),
+			xsdBaseline,
+		}, xsdBankContacts...)...),
+		nsDataSetSubmission: xsdSeq("DataSetSubmissn", 1, 1,
+			xsdSeq("SubmissnId", 1, 1, xsdMessageIdentification...),
+			xsdSeq("RltdTxRefs", 1, 0,
+				xsdText("TxId", 1, 1, xsdMax35Text),
+				xsdSeq("PurchsOrdrRef", 1, 1, xsdPurchaseOrderRef...),
+				xsdAny("UsrTxRef", 0, 2),
+				xsdText("ForcdMtch", 0, 1, xsdBoolean),
+			),
+			xsdSeq("CmonSubmissnRef", 1, 1, xsdText("Id", 1, 1, xsdMax35Text)),
+			xsdSeq("Instr", 1, 1, xsdText("Tp", 1, 1, xsdInstruction)),
+			xsdSeq("BuyrBk", 1, 1, xsdBICIdentification...),
+			xsdSeq("SellrBk", 1, 1, xsdBICIdentification...),
+			xsdAny("ComrclDataSet", 0, 1),
+			xsdAny("TrnsprtDataSet", 0, 1),
+			xsdAny("InsrncDataSet", 0, 1),
+			xsdAny("CertDataSet", 0, 0),
+			xsdAny("OthrCertDataSet", 0, 0),
+			xsdSeq("SplmtryData", 0, 0, xsdText("PlcAndNm", 0, 1, xsdMax350Text), xsdAny("Envlp", 1, 1)),
+		),
+		nsPresentedDocuments: xsdSeq("Docs", 1, 1,
+			xsdSeq("Doc", 1, 0,
+				xsdText("DocTp", 1, 1, xsdMax35Text),
+				xsdSeq("AttchdBinryFile", 1, 1, xsdText("InclBinryObj", 1, 1, xsdBase64)),
+			),
+		),
+	}
+)
+
+// xmlNode is an element of a parsed message
+type xmlNode struct {
+	XMLName xml.Name
+	Text    string    `xml:",chardata"`
+	Nodes   []xmlNode `xml:",any"`
+}
+
+// validateSequence checks the children of node against the sequence of elements and returns the problems found
+func validateSequence(path string, nodes []xmlNode, elements []xsdElement) []string {
+	var problems []string
+
+	i := 0
+	for _, element := range elements {
+		n := 0
+		for i < len(nodes) && element.matches(nodes[i]) && (element.Max == 0 || n < element.Max) {
+			problems = append(problems, element.validate(path, nodes[i])...)
+			i++
+			n++
+		}
+		if n < element.Min {
+			problems = append(problems, fmt.Sprintf("%s: %s occurs %d times, at least %d expected", path, element.label(), n, element.Min))
+		}
+	}
+	for ; i < len(nodes); i++ {
+		problems = append(problems, fmt.Sprintf("%s: unexpected element %s", path, nodes[i].XMLName.Local))
+	}
+
+	return problems
+}
+
+// label names an element or a choice group in problems
+func (e xsdElement) label() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	var names []string
+	for _, child := range e.Children {
+		names = append(names, child.Name)
+	}
+	return "(" + strings.Join(names, "|") + ")"
+}
+
+// matches reports whether node is an occurrence of the element
+func (e xsdElement) matches(node xmlNode) bool {
+	if e.Name != "" {
+		return node.XMLName.Local == e.Name
+	}
+	for _, child := range e.Children {
+		if child.matches(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks an occurrence of the element
+func (e xsdElement) validate(path string, node xmlNode) []string {
+	if e.Name == "" {
+		// A choice group without an element of its own
+		for _, child := range e.Children {
+			if child.matches(node) {
+				return child.validate(path, node)
+			}
+		}
+	}
+
+	path += "/" + e.Name
+	switch {
+	case e.Any:
+		return nil
+	case e.Text != nil:
+		if len(node.Nodes) != 0 || !e.Text.MatchString(node.Text) {
+			return []string{fmt.Sprintf("%s: invalid value %q", path, node.Text)}
+		}
+		return nil
+	case e.Choice:
+		if len(node.Nodes) != 1 {
+			return []string{fmt.Sprintf("%s: %d elements, one of %s expected", path, len(node.Nodes), xsdElement{Children: e.Children}.label())}
+		}
+		return validateSequence(path, node.Nodes, []xsdElement{{Choice: true, Min: 1, Max: 1, Children: e.Children}})
+	}
+
+	return validateSequence(path, node.Nodes, e.Children)
+}
+
+// validateISO20022 validates a message against the transcribed schema of its namespace, the supplementary data
+// against the schema of theirs. If the published XSDs are in testdata/iso20022, as tsmt.019.001.04.xsd and so on,
+// xmllint validates the message against them as well.
+func validateISO20022(t *testing.T, msg string) {
+	var doc xmlNode
+	if err := xml.Unmarshal([]byte(msg), &doc); err != nil {
+		t.Fatalf("%v:\n%s", err, msg)
+	}
+	schema, ok := isoSchemas[doc.XMLName.Space]
+	if !ok || doc.XMLName.Local != "Document" {
+		t.Fatalf("no schema for %s %s", doc.XMLName.Space, doc.XMLName.Local)
+	}
+
+	problems := validateSequence("Document", doc.Nodes, []xsdElement{schema})
+	if len(doc.Nodes) == 1 {
+		for _, data := range doc.Nodes[0].Nodes {
+			if data.XMLName.Local != "SplmtryData" {
+				continue
+			}
+			for _, envelope := range data.Nodes {
+				for _, content := range envelope.Nodes {
+					if schema, ok := isoSchemas[content.XMLName.Space]; ok {
+						problems = append(problems, validateSequence("Envlp", []xmlNode{content}, []xsdElement{schema})...)
+					}
+				}
+			}
+		}
+	}
+	if len(problems) != 0 {
+		t.Fatalf("invalid message:\n%s\n%s", strings.Join(problems, "\n"), msg)
+	}
+
+	xsd := filepath.Join("testdata", "iso20022", strings.TrimPrefix(doc.XMLName.Space, "urn:iso:std:iso:20022:tech:xsd:")+".xsd")
+	xmllint, err := exec.LookPath("xmllint")
+	if _, statErr := os.Stat(xsd); statErr != nil || err != nil {
+		return
+	}
+	f, err := ioutil.TempFile("", "iso20022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(msg); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if out, err := exec.Command(xmllint, "--noout", "--schema", xsd, f.Name()).CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", xsd, out)
+	}
+}
+
+func TestISO20022Baseline(t *testing.T) {
+	lc, err := parseMT700(isoTestMT700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trade := isoTrade{ContractID: "1000", MessageID: isoMessageID{ID: "tx1", Creation: "2012-07-12T10:00:00Z"}, BuyerBankContact: "IB", SellerBankContact: "EB", Version: 1}
+
+	msg, err := renderISO20022(eventIssuance, trade, lc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateISO20022(t, msg)
+	for _, want := range []string{
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:tsmt.019.001.04">`,
+		`<Instr>`,
+		`<SvcCd>LEV1</SvcCd>`,
+		`<DtOfIsse>2012-07-12</DtOfIsse>`,
+		`<PstCdId>10017</PstCdId>`,
+		`<Ctry>DE</Ctry>`,
+		`<LineItmsTtlAmt Ccy="USD">10000</LineItmsTtlAmt>`,
+		`<OthrPmtTerms>AT SIGHT</OthrPmtTerms>`,
+		`<Labl>MT700 46A</Labl>`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("issuance lacks %s:\n%s", want, msg)
+		}
+	}
+
+	// The PO survives the round trip
+	parsed, err := parseISO20022Baseline(msg)
+	if err != nil || !reflect.DeepEqual(parsed, lc) {
+		t.Fatalf("parseISO20022Baseline:\n%+v\n%+v\n%v", parsed, lc, err)
+	}
+
+	// Fields longer than the buyer defined information are split, drafts and shipment dates have elements
+	long := *lc
+	long.Tag47A = strings.Repeat("ALL DOCUMENTS MUST QUOTE THE CREDIT NUMBER\n", 8)
+	long.Tag42C = "90 DAYS AFTER SIGHT"
+	long.Tag44C = "120815"
+	msg, err = renderISO20022(eventIssuance, trade, &long, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateISO20022(t, msg)
+	if n := strings.Count(msg, "<Labl>MT700 47A</Labl>"); n != 3 || !strings.Contains(msg, "<LatstShipmntDt>2012-08-15</LatstShipmntDt>") || !strings.Contains(msg, "<OthrPmtTerms>90 DAYS AFTER SIGHT</OthrPmtTerms>") {
+		t.Errorf("issuance with 42C, 44C and 47A in %d pieces:\n%s", n, msg)
+	}
+	if parsed, err = parseISO20022Baseline(msg); err != nil || !reflect.DeepEqual(parsed, &long) {
+		t.Fatalf("parseISO20022Baseline:\n%+v\n%+v\n%v", parsed, long, err)
+	}
+
+	// Addresses without a postal code can not be represented
+	lc, err = parseMT700(testMT700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = renderISO20022(eventIssuance, trade, lc, nil); errorCode(err) != codeValidationFailed || !strings.Contains(err.Error(), "50 town line has no postal code") {
+		t.Fatalf("renderISO20022 without postal codes: %v", err)
+	}
+
+	for _, test := range []struct {
+		name string
+		msg  string
+	}{
+		{"other message", strings.Replace(msg, "tsmt.019.001.04", "tsmt.001.001.03", 1)},
+		{"unknown MT700 field", strings.Replace(msg, `<Labl>MT700 46A</Labl>`, `<Labl>MT700 78</Labl>`, 1)},
+		{"malformed XML", msg[:len(msg)-5]},
+	} {
+		if _, err := parseISO20022Baseline(test.msg); errorCode(err) != codeInvalidArgs {
+			t.Errorf("parseISO20022Baseline of %s: %v", test.name, err)
+		}
+	}
+}
+
+func TestISO20022Events(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	lc, err := parseMT700(isoTestMT700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trade := isoTrade{ContractID: "1000", MessageID: isoMessageID{ID: "GW-1", Creation: "2012-07-12T10:00:00Z"}, BuyerBankContact: "IB", SellerBankContact: "EB", Version: 1}
+	issuance, err := renderISO20022(eventIssuance, trade, lc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = initTrade(stub, adminCert, "1000", []byte(issuance), "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := stub.query(nil, "getPOAsMT700", "1000"); err != nil || string(b) != isoTestMT700 {
+		t.Fatalf("getPOAsMT700 of an ISO 20022 PO: %q %v", b, err)
+	}
+	if _, err = stub.query(nil, "getISO20022Message", "1000", "amendment"); errorCode(err) != codeNotFound {
+		t.Fatalf("amendment of a PO never amended: %v", err)
+	}
+
+	// The amendment carries the amended baseline as version 2
+	amended := *lc
+	amended.Tag32B = "USD12000,"
+	trade.MessageID.ID, trade.Version = "GW-2", 2
+	amendment, err := renderISO20022(eventAmendment, trade, &amended, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateISO20022(t, amendment)
+	if err = updatePO(stub, adminCert, "1000", []byte(amendment)); err != nil {
+		t.Fatal(err)
+	}
+	var po LC
+	b, err := stub.query(nil, "getPO", "1000")
+	if err = json.Unmarshal(b, &po); err != nil || po.Tag32B != "USD12000," {
+		t.Fatalf("getPO after the amendment: %s %v", b, err)
+	}
+
+	b, err = stub.query(nil, "getISO20022Message", "1000", "amendment")
+	if err != nil || !strings.Contains(string(b), "tsmt.009.001.04") || !strings.Contains(string(b), "<Vrsn>2</Vrsn>") || !strings.Contains(string(b), `<LineItmsTtlAmt Ccy="USD">12000</LineItmsTtlAmt>`) {
+		t.Fatalf("getISO20022Message amendment: %s %v", b, err)
+	}
+	validateISO20022(t, string(b))
+
+	// The issuance keeps the PO as issued
+	b, err = stub.query(nil, "getISO20022Message", "1000", "issuance")
+	if err != nil || !strings.Contains(string(b), "<Vrsn>1</Vrsn>") || !strings.Contains(string(b), `<LineItmsTtlAmt Ccy="USD">10000</LineItmsTtlAmt>`) {
+		t.Fatalf("getISO20022Message issuance: %s %v", b, err)
+	}
+	validateISO20022(t, string(b))
+
+	if _, err = stub.query(nil, "getISO20022Message", "1000", "presentation"); errorCode(err) != codeNotFound {
+		t.Fatalf("presentation before the documents: %v", err)
+	}
+
+	// Binary documents survive the presentation
+	blPDF := "%PDF-1.4\x00\xff\xfe BL"
+	docs := []isoPresentedDocument{
+		{Type: "BL", Content: base64.StdEncoding.EncodeToString([]byte(blPDF))},
+		{Type: "INVOICE", Content: base64.StdEncoding.EncodeToString([]byte("InvoicePDF"))},
+	}
+	trade.MessageID.ID = "GW-3"
+	presentation, err := renderISO20022(eventPresentation, trade, &amended, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateISO20022(t, presentation)
+	if _, err = stub.invoke(adminCert, "submitEDAsISO20022", "1000", presentation); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = stub.query(nil, "getED", "1000", "BL"); err != nil || string(b) != blPDF {
+		t.Fatalf("getED of a presented BL: %q %v", b, err)
+	}
+	if b, err = stub.query(nil, "getED", "1000", "INVOICE"); err != nil || string(b) != "InvoicePDF" {
+		t.Fatalf("getED of a presented invoice: %s %v", b, err)
+	}
+
+	b, err = stub.query(nil, "getISO20022Message", "1000", "presentation")
+	if err != nil || !strings.Contains(string(b), "<InclBinryObj>"+docs[0].Content+"</InclBinryObj>") {
+		t.Fatalf("getISO20022Message presentation: %s %v", b, err)
+	}
+	validateISO20022(t, string(b))
+
+	for _, test := range []struct {
+		name string
+		msg  string
+	}{
+		{"other contract", strings.Replace(presentation, "<TxId>1000</TxId>", "<TxId>1001</TxId>", 1)},
+		{"baseline", issuance},
+		{"unknown document", strings.Replace(presentation, "<DocTp>BL</DocTp>", "<DocTp>CERT</DocTp>", 1)},
+		{"no documents", strings.Replace(presentation, nsPresentedDocuments, "urn:example:other", 1)},
+		{"content not base64", strings.Replace(presentation, docs[0].Content, "%PDF", 1)},
+	} {
+		if _, err = stub.invoke(adminCert, "submitEDAsISO20022", "1000", test.msg); errorCode(err) != codeInvalidArgs {
+			t.Errorf("submitEDAsISO20022 of %s: %v", test.name, err)
+		}
+	}
+	if _, err = stub.query(nil, "getISO20022Message", "1000", "payment"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("getISO20022Message of an unknown event: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// edTransitions is the intended lifecycle of the export documents: the status each
+// operation moves the documents to from a given status. Missing entries are invalid.
+var edTransitions = map[string]map[string]string{
+	"submitED": {
+		"": "SUBMITTED_BY_EB",
+	},
+	"acceptED": {
+		"SUBMITTED_BY_EB":    "ACCEPTED_BY_IB",
+		"ACCEPTED_BY_IB":     "PAYMENT_INITIATED",
+		"PAYMENT_INITIATED":  "PAYMENT_INPROGRESS",
+		"PAYMENT_INPROGRESS": "PAYMENT_COMPLETED",
+	},
+	"rejectED": {
+		"SUBMITTED_BY_EB": "REJECTED_BY_IB",
+	},
+}
+
+// edTerminal are the statuses the export documents never leave
+var edTerminal = map[string]bool{
+	"REJECTED_BY_IB":    true,
+	"PAYMENT_COMPLETED": true,
+}
+
+// edOperations are the invokes driven by the generated sequences
+var edOperations = []string{"submitED", "acceptED", "rejectED", "updatePO"}
+
+// edStatuses returns the statuses of the BL, the invoice and the packing list
+func edStatuses(stub *mockStub, UID string) ([]string, error) {
+	var statuses []string
+	for _, store := range []DocumentStore{blStore, invoiceStore, plStore} {
+		doc, err := store.Get(stub, UID)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			statuses = append(statuses, "")
+		} else {
+			statuses = append(statuses, doc.Status)
+		}
+	}
+	return statuses, nil
+}
+
+// runEDSequence drives a random sequence of operations on one contract and checks
+// every step against the model
+func runEDSequence(seed int64, steps int) error {
+	r := rand.New(rand.NewSource(seed))
+	stub := newMockStub()
+	caller := []byte(`AdminCert`)
+
+	if err := initTrade(stub, caller, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		return err
+	}
+
+	var history []string
+	model := ""
+
+	for i := 0; i < steps; i++ {
+		op := edOperations[r.Intn(len(edOperations))]
+		history = append(history, op)
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("seed %d after %s: %s", seed, strings.Join(history, ", "), fmt.Sprintf(format, args...))
+		}
+
+		var err error
+		if op == "submitED" {
+			err = submitED(stub, caller, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`))
+		} else if op == "acceptED" {
+			err = acceptED(stub, caller, "1000")
+		} else if op == "rejectED" {
+			err = rejectED(stub, caller, "1000")
+		} else {
+			err = updatePO(stub, caller, "1000", testPOJSON)
+		}
+
+		next, valid := edTransitions[op][model]
+		if op == "updatePO" {
+			// Amending the PO leaves the export documents alone
+			next, valid = model, true
+		}
+		if valid && err != nil {
+			return fail("unexpected error %v", err)
+		}
+		if !valid {
+			// An invalid operation must fail and leave the documents untouched. Documents
+			// submitted again are duplicates.
+			code := errorCode(err)
+			if code != codeInvalidTransition && code != codeNotFound && !(op == "submitED" && code == codeDuplicate) {
+				return fail("expected %s or %s, got %v", codeInvalidTransition, codeNotFound, err)
+			}
+			next = model
+		}
+
+		statuses, err := edStatuses(stub, "1000")
+		if err != nil {
+			return fail("%v", err)
+		}
+
+		// All three documents share one status
+		if statuses[0] != statuses[1] || statuses[0] != statuses[2] {
+			return fail("documents diverged: BL %q, invoice %q, packing list %q", statuses[0], statuses[1], statuses[2])
+		}
+
+		// Terminal states stay terminal
+		if edTerminal[model] && statuses[0] != model {
+			return fail("left terminal status %s for %s", model, statuses[0])
+		}
+
+		// No skipped states
+		if statuses[0] != next {
+			return fail("status %q, expected %q", statuses[0], next)
+		}
+
+		model = next
+	}
+
+	return nil
+}
+
+func TestEDLifecycleProperties(t *testing.T) {
+	for seed := int64(1); seed <= 300; seed++ {
+		if err := runEDSequence(seed, 12); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
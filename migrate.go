@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Column positions of the legacy BPTable
+const (
+	bpColType = iota
+	bpColUID
+	bpColStatus
+	bpColImporterName
+	bpColExporterName
+	bpColImporterBankName
+	bpColExporterBankName
+	bpColImporterCert
+	bpColExporterCert
+	bpColImporterBankCert
+	bpColExporterBankCert
+	bpColLastUpdated
+)
+
+// Column positions of the legacy document tables
+const (
+	docColType = iota
+	docColUID
+	docColContent
+	docColStatus
+)
+
+// Column positions of the legacy ConsentTable
+const (
+	consentColAction = iota
+	consentColUID
+	consentColRole
+	consentColConsentedAt
+)
+
+// legacyStatusInProgress is the only contract status written in the table layout, whatever the documents
+const legacyStatusInProgress = "IN_PROGRESS"
+
+// legacyDocTables maps the legacy document tables to the stores replacing them
+var legacyDocTables = []struct {
+	TableName string
+	Store     DocumentStore
+}{
+	{"POTable", poStore},
+	{"BLTable", blStore},
+	{"invoiceTable", invoiceStore},
+	{"PLTable", plStore},
+}
+
+// MigrationResult counts the rows copied from the legacy tables
+type MigrationResult struct {
+	Contracts int `json:"contracts"`
+	Documents int `json:"documents"`
+	Consents  int `json:"consents"`
+}
+
+// legacyRows returns the rows of a legacy table with the given key prefix, or none if the table does not exist
+func legacyRows(stub shim.ChaincodeStubInterface, tableName string, key string) ([]shim.Row, error) {
+	_, err := stub.GetTable(tableName)
+	if err != nil {
+		// Table never created; nothing to migrate
+		return nil, nil
+	}
+
+	var columns []shim.Column
+	columns = append(columns, shim.Column{Value: &shim.Column_String_{String_: key}})
+
+	rowChannel, err := stub.GetRows(tableName, columns)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve rows of %s", tableName)
+	}
+
+	var rows []shim.Row
+	for row := range rowChannel {
+		if len(row.Columns) != 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// migrateTables copies the rows of the legacy tables into the key-value layout.
+// Rows already present in the new layout are left untouched, so it can be run again safely.
+func (t *SBI) migrateTables(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var result MigrationResult
+
+	rows, err := legacyRows(stub, "BPTable", "BP")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		bp := &BusinessProcess{
+			UID:              row.Columns[bpColUID].GetString_(),
+			Status:           row.Columns[bpColStatus].GetString_(),
+			ImporterName:     row.Columns[bpColImporterName].GetString_(),
+			ExporterName:     row.Columns[bpColExporterName].GetString_(),
+			ImporterBankName: row.Columns[bpColImporterBankName].GetString_(),
+			ExporterBankName: row.Columns[bpColExporterBankName].GetString_(),
+			ImporterCert:     row.Columns[bpColImporterCert].GetBytes(),
+			ExporterCert:     row.Columns[bpColExporterCert].GetBytes(),
+			ImporterBankCert: row.Columns[bpColImporterBankCert].GetBytes(),
+			ExporterBankCert: row.Columns[bpColExporterBankCert].GetBytes(),
+		}
+		// Rows written before the LastUpdated column existed only have 11 columns
+		if len(row.Columns) > bpColLastUpdated {
+			bp.LastUpdated = row.Columns[bpColLastUpdated].GetString_()
+		}
+
+		ok, err := bpStore.Insert(stub, bp)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.Contracts++
+		}
+	}
+
+	for _, table := range legacyDocTables {
+		rows, err := legacyRows(stub, table.TableName, "DOC")
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			ok, err := table.Store.Insert(stub, &Document{
+				UID:     row.Columns[docColUID].GetString_(),
+				Content: row.Columns[docColContent].GetBytes(),
+				Status:  row.Columns[docColStatus].GetString_(),
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result.Documents++
+			}
+		}
+	}
+
+	rows, err = legacyRows(stub, "ConsentTable", "CANCEL")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		ok, err := consentStore.Insert(stub, &Consent{
+			Action:      row.Columns[consentColAction].GetString_(),
+			UID:         row.Columns[consentColUID].GetString_(),
+			Role:        row.Columns[consentColRole].GetString_(),
+			ConsentedAt: row.Columns[consentColConsentedAt].GetString_(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.Consents++
+		}
+	}
+
+	// The copied contracts get their status once their documents are copied too
+	err = deriveLegacyStatuses(t, stub)
+	if err != nil {
+		return nil, err
+	}
+
+	myLogger.Debugf("Migrated %d contracts, %d documents and %d consents", result.Contracts, result.Documents, result.Consents)
+
+	return json.Marshal(result)
+}
+
+// deriveLegacyStatuses derives the status of the contracts still in the legacy IN_PROGRESS status from their PO
+// and export documents. Their last update time is kept.
+func deriveLegacyStatuses(t *SBI, stub shim.ChaincodeStubInterface) error {
+	bps, err := bpStore.List(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, bp := range bps {
+		if bp.Status != legacyStatusInProgress {
+			continue
+		}
+
+		bp.Status, err = t.derivedStatus(stub, bp.UID)
+		if err != nil {
+			return err
+		}
+		err = bpStore.Replace(stub, bp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
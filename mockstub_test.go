@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// mockStub is an in-memory shim.ChaincodeStubInterface covering what the chaincode uses:
+// the world state, tables, caller metadata, signatures and transaction timestamps.
+// Any other method panics through the nil embedded interface.
+type mockStub struct {
+	shim.ChaincodeStubInterface
+
+	cc     *SBI
+	state  map[string][]byte
+	tables map[string]*mockTable
+
+	// Transaction in progress
+	txID     int
+	function string
+	args     []string
+	caller   []byte
+
+	// Now is the timestamp of the next transactions
+	Now time.Time
+}
+
+// mockTable is a table of the legacy table API
+type mockTable struct {
+	columnDefinitions []*shim.ColumnDefinition
+	rows              []shim.Row
+}
+
+// newMockStub returns a stub with the chaincode initialized
+func newMockStub() *mockStub {
+	s := &mockStub{
+		cc:     new(SBI),
+		state:  make(map[string][]byte),
+		tables: make(map[string]*mockTable),
+		Now:    time.Date(2012, time.July, 12, 10, 0, 0, 0, time.UTC),
+	}
+
+	_, err := s.init()
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// mockSign is the signature of message by the holder of certificate
+func mockSign(certificate []byte, message []byte) []byte {
+	h := sha256.New()
+	h.Write(certificate)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// begin starts a new transaction submitted by caller
+func (s *mockStub) begin(caller []byte, function string, args []string) {
+	s.txID++
+	s.caller = caller
+	s.function = function
+	s.args = args
+}
+
+// snapshot copies the world state and the tables
+func (s *mockStub) snapshot() (map[string][]byte, map[string]*mockTable) {
+	state := make(map[string][]byte, len(s.state))
+	for key, value := range s.state {
+		state[key] = value
+	}
+
+	tables := make(map[string]*mockTable, len(s.tables))
+	for name, table := range s.tables {
+		tables[name] = &mockTable{
+			columnDefinitions: table.columnDefinitions,
+			rows:              append([]shim.Row(nil), table.rows...),
+		}
+	}
+
+	return state, tables
+}
+
+// init runs the Init transaction of a deployment or an upgrade without deployer. Its writes are discarded if it fails.
+func (s *mockStub) init(args ...string) ([]byte, error) {
+	return s.deploy(nil, args...)
+}
+
+// deploy runs the Init transaction of a deployment or an upgrade by deployer. Its writes are discarded if it fails.
+func (s *mockStub) deploy(deployer []byte, args ...string) ([]byte, error) {
+	s.begin(deployer, "init", args)
+
+	state, tables := s.snapshot()
+	b, err := s.cc.Init(s, "init", args)
+	if err != nil {
+		s.state, s.tables = state, tables
+	}
+
+	return b, err
+}
+
+// invoke runs an invoke transaction signed by caller. Its writes are discarded if it fails.
+func (s *mockStub) invoke(caller []byte, function string, args ...string) ([]byte, error) {
+	s.begin(caller, function, args)
+
+	state, tables := s.snapshot()
+	b, err := s.cc.Invoke(s, function, args)
+	if err != nil {
+		s.state, s.tables = state, tables
+	}
+
+	return b, err
+}
+
+// query runs a query signed by caller. Its writes are always discarded.
+func (s *mockStub) query(caller []byte, function string, args ...string) ([]byte, error) {
+	s.begin(caller, function, args)
+
+	state, tables := s.snapshot()
+	defer func() {
+		s.state, s.tables = state, tables
+	}()
+
+	return s.cc.Query(s, function, args)
+}
+
+func (s *mockStub) GetTxID() string {
+	return fmt.Sprintf("tx%d", s.txID)
+}
+
+func (s *mockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.Now.Unix(), Nanos: int32(s.Now.Nanosecond())}, nil
+}
+
+func (s *mockStub) GetPayload() ([]byte, error) {
+	return json.Marshal(append([]string{s.function}, s.args...))
+}
+
+func (s *mockStub) GetBinding() ([]byte, error) {
+	return []byte(s.GetTxID()), nil
+}
+
+// GetCallerMetadata returns the caller's signature over payload || binding, or the deployer's certificate in Init
+func (s *mockStub) GetCallerMetadata() ([]byte, error) {
+	if s.caller == nil {
+		return nil, nil
+	}
+	if s.function == "init" {
+		return s.caller, nil
+	}
+
+	payload, _ := s.GetPayload()
+	binding, _ := s.GetBinding()
+
+	return mockSign(s.caller, append(payload, binding...)), nil
+}
+
+func (s *mockStub) VerifySignature(certificate, signature, message []byte) (bool, error) {
+	if len(signature) == 0 {
+		return false, errors.New("Empty signature")
+	}
+
+	return bytes.Equal(mockSign(certificate, message), signature), nil
+}
+
+func (s *mockStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *mockStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return errors.New("Empty key")
+	}
+	s.state[key] = value
+	return nil
+}
+
+func (s *mockStub) DelState(key string) error {
+	delete(s.state, key)
+	return nil
+}
+
+func (s *mockStub) RangeQueryState(startKey, endKey string) (shim.StateRangeQueryIteratorInterface, error) {
+	var keys []string
+	for key := range s.state {
+		if key >= startKey && key < endKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return &mockIterator{stub: s, keys: keys}, nil
+}
+
+// mockIterator walks the keys of a range query in order
+type mockIterator struct {
+	stub *mockStub
+	keys []string
+}
+
+func (it *mockIterator) HasNext() bool {
+	return len(it.keys) != 0
+}
+
+func (it *mockIterator) Next() (string, []byte, error) {
+	if len(it.keys) == 0 {
+		return "", nil, errors.New("No more keys")
+	}
+	key := it.keys[0]
+	it.keys = it.keys[1:]
+	return key, it.stub.state[key], nil
+}
+
+func (it *mockIterator) Close() error {
+	return nil
+}
+
+func (s *mockStub) CreateTable(name string, columnDefinitions []*shim.ColumnDefinition) error {
+	if _, ok := s.tables[name]; ok {
+		return fmt.Errorf("Table %s already exists", name)
+	}
+	s.tables[name] = &mockTable{columnDefinitions: columnDefinitions}
+	return nil
+}
+
+func (s *mockStub) GetTable(tableName string) (*shim.Table, error) {
+	table, ok := s.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	return &shim.Table{Name: tableName, ColumnDefinitions: table.columnDefinitions}, nil
+}
+
+// matchesKey reports whether the leading columns of row equal key
+func matchesKey(row shim.Row, key []shim.Column) bool {
+	if len(row.Columns) < len(key) {
+		return false
+	}
+	for i := range key {
+		if row.Columns[i].GetString_() != key[i].GetString_() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *mockStub) InsertRow(tableName string, row shim.Row) (bool, error) {
+	table, ok := s.tables[tableName]
+	if !ok {
+		return false, fmt.Errorf("Table %s does not exist", tableName)
+	}
+
+	var key []shim.Column
+	for i, def := range table.columnDefinitions {
+		if def.Key {
+			key = append(key, *row.Columns[i])
+		}
+	}
+	for _, existing := range table.rows {
+		if matchesKey(existing, key) {
+			return false, nil
+		}
+	}
+
+	table.rows = append(table.rows, row)
+	return true, nil
+}
+
+func (s *mockStub) GetRows(tableName string, key []shim.Column) (<-chan shim.Row, error) {
+	table, ok := s.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("Table %s does not exist", tableName)
+	}
+
+	rows := make(chan shim.Row, len(table.rows))
+	for _, row := range table.rows {
+		if matchesKey(row, key) {
+			rows <- row
+		}
+	}
+	close(rows)
+
+	return rows, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// finMessage is a SWIFT FIN message as sent to the SWIFT gateway
+type finMessage string
+
+// mt700Field is a field of block 4 of an MT700, the LC field holding it and the lines it may span
+type mt700Field struct {
+	Tag       string
+	Field     string
+	Mandatory bool
+	Lines     int // maximum number of lines
+	Width     int // maximum number of characters of a line
+}
+
+// mt700Fields are the supported MT700 fields in message order
+var mt700Fields = []mt700Field{
+	{"27", "Tag27", true, 1, 3},
+	{"40A", "Tag40A", true, 1, 24},
+	{"20", "Tag20", true, 1, 16},
+	{"31C", "Tag31C", true, 1, 6},
+	{"40E", "Tag40E", true, 1, 66},
+	{"31D", "Tag31D", true, 1, 35},
+	{"50", "Tag50", true, 4, 35},
+	{"59", "Tag59", true, 5, 35},
+	{"32B", "Tag32B", true, 1, 18},
+	{"39A", "Tag39A", false, 1, 5},
+	{"41A", "Tag41A", true, 5, 35},
+	{"42C", "Tag42C", false, 3, 35},
+	{"42D", "Tag42D", false, 5, 35},
+	{"43P", "Tag43P", false, 1, 35},
+	{"43T", "Tag43T", false, 1, 35},
+	{"44A", "Tag44A", false, 1, 65},
+	{"44E", "Tag44E", false, 1, 65},
+	{"44F", "Tag44F", false, 1, 65},
+	{"44B", "Tag44B", false, 1, 65},
+	{"44C", "Tag44C", false, 1, 6},
+	{"45A", "Tag45A", false, 100, 65},
+	{"46A", "Tag46A", false, 100, 65},
+	{"47A", "Tag47A", false, 100, 65},
+	{"71B", "Tag71B", false, 6, 35},
+	{"48", "Tag48", false, 4, 35},
+	{"49", "Tag49", true, 1, 7},
+	{"53A", "Tag53A", false, 2, 35},
+	{"57D", "Tag57D", false, 5, 35},
+}
+
+var (
+	finFieldPattern = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):(.*)$`)
+	bicPattern      = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+
+	// finXPattern matches a line of the SWIFT X character set
+	finXPattern = regexp.MustCompile(`^[A-Za-z0-9/?:().,'+ -]*$`)
+)
+
+// checkFINField returns the problem of a field value that can not be sent in a FIN message, or "" if there is none
+func checkFINField(field mt700Field, value string) string {
+	lines := strings.Split(value, "\n")
+	if len(lines) > field.Lines {
+		return fmt.Sprintf("field %s has %d lines, at most %d are allowed", field.Tag, len(lines), field.Lines)
+	}
+	for i, line := range lines {
+		switch {
+		case !finXPattern.MatchString(line):
+			return fmt.Sprintf("line %d of field %s has characters outside the SWIFT X character set", i+1, field.Tag)
+		case len(line) > field.Width:
+			return fmt.Sprintf("line %d of field %s has %d characters, at most %d are allowed", i+1, field.Tag, len(line), field.Width)
+		case i > 0 && (strings.HasPrefix(line, ":") || strings.HasPrefix(line, "-")):
+			// Such a line would be read as the next field or the end of the block
+			return fmt.Sprintf("line %d of field %s starts with %s", i+1, field.Tag, line[:1])
+		}
+	}
+
+	return ""
+}
+
+// isFINMessage reports whether a PO argument is a FIN message rather than JSON
+func isFINMessage(s string) bool {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, ":") {
+		return true
+	}
+	return len(s) > 3 && s[0] == '{' && s[1] >= '1' && s[1] <= '5' && s[2] == ':'
+}
+
+// poFromArg returns the JSON of the PO given as JSON, as an MT700 FIN message or as an
+// ISO 20022 baseline submission or amendment
+func poFromArg(po string) (string, error) {
+	var lc *LC
+	var err error
+	switch {
+	case isFINMessage(po):
+		lc, err = parseMT700(po)
+	case isISO20022Message(po):
+		lc, err = parseISO20022Baseline(po)
+	default:
+		return po, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(lc)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// finBlock returns the content of block n of a FIN message, or "" if it has none
+func finBlock(msg string, n string) string {
+	start := strings.Index(msg, "{"+n+":")
+	if start < 0 {
+		return ""
+	}
+	rest := msg[start+len(n)+2:]
+
+	// Block 4 spans lines and ends with -}, the header blocks have no nested braces
+	end := "}"
+	if n == "4" {
+		end = "-}"
+	}
+	stop := strings.Index(rest, end)
+	if stop < 0 {
+		return rest
+	}
+	return rest[:stop]
+}
+
+// bicFromLT returns the BIC of a 12 character logical terminal address, dropping its terminal code
+func bicFromLT(lt string) string {
+	if len(lt) != 12 {
+		return ""
+	}
+	return lt[:8] + lt[9:]
+}
+
+// ltFromBIC returns the logical terminal address of a BIC
+func ltFromBIC(bic string) string {
+	branch := "XXX"
+	if len(bic) == 11 {
+		branch = bic[8:]
+	}
+	return bic[:8] + "A" + branch
+}
+
+// parseMT700 converts an MT700 FIN message to an LC. The sender and receiver are taken from blocks 1 and 2 if present.
+// Without block 4 the whole message is taken as the text of block 4.
+func parseMT700(msg string) (*LC, error) {
+	msg = strings.Replace(msg, "\r\n", "\n", -1)
+
+	var lc LC
+	if block1 := finBlock(msg, "1"); block1 != "" {
+		if len(block1) != 25 || !strings.HasPrefix(block1, "F01") {
+			return nil, newError(codeInvalidArgs, "Invalid MT700: malformed basic header block.")
+		}
+		lc.Sender = bicFromLT(block1[3:15])
+	}
+	if block2 := finBlock(msg, "2"); block2 != "" {
+		switch {
+		case strings.HasPrefix(block2, "I700") && len(block2) >= 16:
+			lc.Receiver = bicFromLT(block2[4:16])
+		case strings.HasPrefix(block2, "O700") && len(block2) >= 46:
+			// The sender of an output message is in the message input reference
+			lc.Receiver = lc.Sender
+			lc.Sender = bicFromLT(block2[14:26])
+		default:
+			return nil, newError(codeInvalidArgs, "Invalid MT700: application header is not an MT700.")
+		}
+	}
+
+	text := msg
+	if strings.Contains(msg, "{4:") {
+		text = finBlock(msg, "4")
+	}
+
+	values := make(map[string]string)
+	var order []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimRight(line, " ")
+		if m := finFieldPattern.FindStringSubmatch(line); m != nil {
+			if _, ok := values[m[1]]; ok {
+				return nil, newError(codeInvalidArgs, "Invalid MT700: field %s is repeated.", m[1])
+			}
+			values[m[1]] = m[2]
+			order = append(order, m[1])
+			continue
+		}
+		if len(order) == 0 {
+			return nil, newError(codeInvalidArgs, "Invalid MT700: text before the first field.")
+		}
+		// Continuation line of a multi-line field
+		last := order[len(order)-1]
+		values[last] += "\n" + line
+	}
+
+	v := reflect.ValueOf(&lc).Elem()
+	known := make(map[string]bool)
+	var missing []string
+	for _, field := range mt700Fields {
+		known[field.Tag] = true
+		value, ok := values[field.Tag]
+		if !ok || value == "" {
+			if field.Mandatory {
+				missing = append(missing, field.Tag)
+			}
+			continue
+		}
+		v.FieldByName(field.Field).SetString(value)
+	}
+	for _, tag := range order {
+		if !known[tag] {
+			return nil, newError(codeInvalidArgs, "Invalid MT700: field %s is not supported.", tag)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, newError(codeValidationFailed, "Invalid MT700: missing mandatory fields %s.", strings.Join(missing, ", "))
+	}
+
+	return &lc, nil
+}
+
+// renderMT700 renders an LC as an MT700 FIN message with CRLF line ends. Every field must fit the
+// SWIFT X character set and the lines of its format. Blocks 1 and 2 are only written when the sender
+// and the receiver are known.
+func renderMT700(lc *LC) (string, error) {
+	v := reflect.ValueOf(lc).Elem()
+
+	var missing []string
+	for _, field := range mt700Fields {
+		if field.Mandatory && v.FieldByName(field.Field).String() == "" {
+			missing = append(missing, field.Tag)
+		}
+	}
+	if len(missing) != 0 {
+		return "", newError(codeValidationFailed, "PO can not be rendered as an MT700, missing mandatory fields %s.", strings.Join(missing, ", "))
+	}
+
+	var problems []string
+	for _, field := range mt700Fields {
+		if problem := checkFINField(field, v.FieldByName(field.Field).String()); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) != 0 {
+		return "", newError(codeValidationFailed, "PO can not be rendered as an MT700: %s.", strings.Join(problems, "; "))
+	}
+
+	var msg []string
+	if lc.Sender != "" || lc.Receiver != "" {
+		if !bicPattern.MatchString(lc.Sender) || !bicPattern.MatchString(lc.Receiver) {
+			return "", newError(codeValidationFailed, "PO can not be rendered as an MT700, sender and receiver must be BICs.")
+		}
+		msg = append(msg, "{1:F01"+ltFromBIC(lc.Sender)+"0000000000}{2:I700"+ltFromBIC(lc.Receiver)+"N}{4:")
+	} else {
+		msg = append(msg, "{4:")
+	}
+
+	for _, field := range mt700Fields {
+		value := v.FieldByName(field.Field).String()
+		if value == "" {
+			continue
+		}
+		msg = append(msg, ":"+field.Tag+":"+strings.Replace(value, "\n", "\r\n", -1))
+	}
+	msg = append(msg, "-}")
+
+	return strings.Join(msg, "\r\n"), nil
+}
+
+// getPOAsMT700 renders the PO of the contract as an MT700 FIN message
+func (t *SBI) getPOAsMT700(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	b, err := t.getPO(stub, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var lc LC
+	err = json.Unmarshal(b, &lc)
+	if err != nil {
+		return nil, newError(codeValidationFailed, "PO of contract %s is not valid JSON: %s", args[0], err.Error())
+	}
+
+	msg, err := renderMT700(&lc)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(msg), nil
+}
+
+// lcField returns the value of the LC field with the given name
+func lcField(lc *LC, name string) string {
+	return reflect.ValueOf(lc).Elem().FieldByName(name).String()
+}
+
+// setLCField sets the LC field holding the MT700 tag. It returns false if the tag is not supported.
+func setLCField(lc *LC, tag string, value string) bool {
+	for _, field := range mt700Fields {
+		if field.Tag == tag {
+			reflect.ValueOf(lc).Elem().FieldByName(field.Field).SetString(value)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testMT700 = strings.Replace(`{1:F01BANKUS33AXXX0000000000}{2:I700BANKDEFFAXXXN}{4:
+:27:1/1
+:40A:IRREVOCABLE
+:20:L960477
+:31C:120712
+:40E:UCP LATEST VERSION
+:31D:120831USA
+:50:IMPORTER INC
+NEW YORK
+:59:EXPORTER GMBH
+FRANKFURT
+:32B:USD10000,
+:41A:BANKUS33
+:43P:NOT ALLOWED
+:45A:+500 WIDGETS
++CIF NEW YORK
+:46A:+SIGNED COMMERCIAL INVOICE IN 3 COPIES
+:49:WITHOUT
+-}`, "\n", "\r\n", -1)
+
+func TestParseMT700(t *testing.T) {
+	lc, err := parseMT700(testMT700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lc.Sender != "BANKUS33XXX" || lc.Receiver != "BANKDEFFXXX" || lc.Tag20 != "L960477" || lc.Tag50 != "IMPORTER INC\nNEW YORK" || lc.Tag45A != "+500 WIDGETS\n+CIF NEW YORK" {
+		t.Fatalf("parseMT700: %+v", lc)
+	}
+
+	// Rendering gives the message back
+	msg, err := renderMT700(lc)
+	if err != nil || msg != testMT700 {
+		t.Fatalf("renderMT700:\n%q\n%v", msg, err)
+	}
+
+	// Output messages name the sender in the message input reference
+	output := strings.Replace(testMT700, "{2:I700BANKDEFFAXXXN}", "{2:O7001200120712BANKDEFFAXXX00001234561207121201N}", 1)
+	output = strings.Replace(output, "{1:F01BANKUS33AXXX", "{1:F01BANKGB2LAXXX", 1)
+	if lc, err = parseMT700(output); err != nil || lc.Sender != "BANKDEFFXXX" || lc.Receiver != "BANKGB2LXXX" {
+		t.Fatalf("parseMT700 of an output message: %+v %v", lc, err)
+	}
+
+	// Block 4 alone is enough
+	if lc, err = parseMT700(":27:1/1\n:40A:IRREVOCABLE\n:20:L1\n:31C:120712\n:40E:UCP LATEST VERSION\n:31D:120831USA\n:50:I\n:59:E\n:32B:USD1,\n:41A:BANKUS33\n:49:WITHOUT"); err != nil || lc.Tag20 != "L1" {
+		t.Fatalf("parseMT700 of block 4: %+v %v", lc, err)
+	}
+
+	for _, test := range []struct {
+		name string
+		msg  string
+		code string
+	}{
+		{"missing field", strings.Replace(testMT700, ":49:WITHOUT\r\n", "", 1), codeValidationFailed},
+		{"unsupported field", strings.Replace(testMT700, ":49:", ":78:PAY\r\n:49:", 1), codeInvalidArgs},
+		{"repeated field", strings.Replace(testMT700, ":49:", ":20:L2\r\n:49:", 1), codeInvalidArgs},
+		{"other message type", strings.Replace(testMT700, "I700", "I710", 1), codeInvalidArgs},
+		{"text before the fields", "{4:\nHELLO\n:20:L1\n-}", codeInvalidArgs},
+	} {
+		if _, err := parseMT700(test.msg); errorCode(err) != test.code {
+			t.Errorf("parseMT700 with %s: %v", test.name, err)
+		}
+	}
+}
+
+func TestPOAsMT700(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	if err := initTrade(stub, adminCert, "1000", []byte(testMT700), "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var lc LC
+	b, err := stub.query(nil, "getPO", "1000")
+	if err = json.Unmarshal(b, &lc); err != nil || lc.Tag32B != "USD10000," || lc.Tag40E != "UCP LATEST VERSION" {
+		t.Fatalf("getPO of an MT700 PO: %s %v", b, err)
+	}
+
+	b, err = stub.query(nil, "getPOAsMT700", "1000")
+	if err != nil || string(b) != testMT700 {
+		t.Fatalf("getPOAsMT700: %q %v", b, err)
+	}
+
+	// Amendments may be sent as MT700 too
+	amended := strings.Replace(testMT700, ":32B:USD10000,", ":32B:USD12000,", 1)
+	if err = updatePO(stub, adminCert, "1000", []byte(amended)); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = stub.query(nil, "getPOAsMT700", "1000"); err != nil || string(b) != amended {
+		t.Fatalf("getPOAsMT700 after the amendment: %q %v", b, err)
+	}
+
+	// A JSON PO lacking mandatory fields can not be rendered
+	if err = initTrade(stub, adminCert, "1001", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stub.query(nil, "getPOAsMT700", "1001"); errorCode(err) != codeValidationFailed {
+		t.Fatalf("getPOAsMT700 of an incomplete PO: %v", err)
+	}
+
+	if _, err = stub.invoke(adminCert, "updatePO", "1001", ":20:L1"); errorCode(err) != codeValidationFailed {
+		t.Fatalf("updatePO with an incomplete MT700: %v", err)
+	}
+
+	// Fields must fit the character set and the lines of their format
+	for _, test := range []struct {
+		name  string
+		tag   string
+		value string
+	}{
+		{"a character outside the X set", "45A", "+500 WIDGETS & SPARES"},
+		{"a non ASCII character", "59", "EXPORTER GMBH\nMÜNCHEN"},
+		{"a line too long", "20", "L960477-2012-AMENDED"},
+		{"too many lines", "50", "IMPORTER INC\n5TH AVENUE\nSUITE 100\nNEW YORK\nUSA"},
+		{"a line starting a field", "46A", "+SIGNED COMMERCIAL INVOICE\n:20:L2"},
+		{"a line read as the end of the block", "47A", "+ALL DOCUMENTS IN ENGLISH\n-"},
+	} {
+		lc, err := parseMT700(testMT700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setLCField(lc, test.tag, test.value)
+		if _, err = renderMT700(lc); errorCode(err) != codeValidationFailed {
+			t.Errorf("renderMT700 with %s: %v", test.name, err)
+		}
+	}
+}
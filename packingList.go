@@ -2,8 +2,6 @@ package main
 
 import (
 	//"encoding/json"
-	"errors"
-	"fmt"
 	//"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
@@ -44,30 +42,7 @@ packingListPDF []byte
 
 //Init initializes the document smart contract
 func (t *PL) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	// Check if table already exists
-	_, err := stub.GetTable("PLTable")
-	if err == nil {
-		// Table already exists; do not recreate
-		return nil, nil
-	}
-
-	// Create PL Table
-	err = stub.CreateTable("PLTable", []*shim.ColumnDefinition{
-		&shim.ColumnDefinition{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "UID", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "DocPDF", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "Status", Type: shim.ColumnDefinition_STRING, Key: false},
-	})
-	if err != nil {
-		return nil, errors.New("Failed creating PLTable.")
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
 	return nil, nil
-
 }
 
 // isEarlierDate returns true if date1 is earlier than date2, false otherwise
@@ -93,24 +68,17 @@ func (t *PL) Init(stub shim.ChaincodeStubInterface, function string, args []stri
 func (t *PL) SubmitDoc(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 2.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
 	}
 
 	UID := args[0]
 	docPDF := []byte(args[1])
 
-	
 	// Insert a row
-	ok, err := stub.InsertRow("PLTable", shim.Row{
-		Columns: []*shim.Column{
-			&shim.Column{Value: &shim.Column_String_{String_: "DOC"}},
-			&shim.Column{Value: &shim.Column_String_{String_: UID}},
-			&shim.Column{Value: &shim.Column_Bytes{Bytes: docPDF}},
-			&shim.Column{Value: &shim.Column_String_{String_: "SUBMITTED_BY_EB"}}},
-	})
+	ok, err := plStore.Insert(stub, &Document{UID: UID, Content: docPDF, Status: "SUBMITTED_BY_EB"})
 
 	if !ok && err == nil {
-		return nil, errors.New("Document already exists.")
+		return nil, newError(codeDuplicate, "Document already exists.")
 	}
 
 	return nil, err
@@ -120,30 +88,24 @@ func (t *PL) SubmitDoc(stub shim.ChaincodeStubInterface, args []string) ([]byte,
 func (t *PL) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 3.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
 	}
 
 	UID := args[0]
 	newStatus := args[1]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("PLTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := plStore.Get(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Packing list of contract %s has not been submitted.", UID)
 	}
-	docPDF := row.Columns[2].GetBytes()
-	currStatus := row.Columns[3].GetString_()
+
+	currStatus := doc.Status
 
 	//Start- Check that the currentStatus to newStatus transition is accurate
 
@@ -164,36 +126,20 @@ func (t *PL) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]by
 		stateTransitionAllowed = true
 	}
 
-
 	if stateTransitionAllowed == false {
-		return nil, errors.New("This state transition is not allowed.")
+		return nil, newError(codeInvalidTransition, "This state transition is not allowed.")
 	}
 
 	//End- Check that the currentStatus to newStatus transition is accurate
 
-	err = stub.DeleteRow(
-		"PLTable",
-		columns,
-	)
-	if err != nil {
-		return nil, errors.New("Failed deleting row.")
-	}
+	doc.Status = newStatus
 
-	_, err = stub.InsertRow(
-		"PLTable",
-		shim.Row{
-			Columns: []*shim.Column{
-				&shim.Column{Value: &shim.Column_String_{String_: "DOC"}},
-				&shim.Column{Value: &shim.Column_String_{String_: UID}},
-				&shim.Column{Value: &shim.Column_Bytes{Bytes: docPDF}},
-				&shim.Column{Value: &shim.Column_String_{String_: newStatus}}},
-		})
+	err = plStore.Replace(stub, doc)
 	if err != nil {
-		return nil, errors.New("Failed inserting row.")
+		return nil, err
 	}
 
 	return nil, nil
-
 }
 
 
@@ -201,31 +147,23 @@ func (t *PL) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]by
 func (t *PL) GetPDF(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
 	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("PLTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := plStore.Get(stub, UID)
 	if err != nil {
-		jsonResp := "{\"Error\":\"Failed retrieveing document with UID " + UID + ". Error " + err.Error() + ". \"}"
-		return nil, errors.New(jsonResp)
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Packing list of contract %s has not been submitted.", UID)
 	}
 
-	//return row.Columns[3].GetBytes(), nil
-	return row.Columns[2].GetBytes(), nil
+	return doc.Content, nil
 }
 
 
@@ -233,28 +171,21 @@ func (t *PL) GetPDF(stub shim.ChaincodeStubInterface, args []string) ([]byte, er
 func (t *PL) GetStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
 	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("PLTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := plStore.Get(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Packing list of contract %s has not been submitted.", UID)
 	}
 
-	//return []byte(row.Columns[4].GetString_()), nil
-	return []byte(row.Columns[3].GetString_()), nil
+	return []byte(doc.Status), nil
 }
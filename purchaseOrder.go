@@ -2,24 +2,22 @@ package main
 
 import (
 	//"encoding/json"
-	"errors"
-	"fmt"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
 type PO struct {
 
 }
-/*
-//LC struct
-type PO struct {
+
+//LC struct holds the MT700 fields of the purchase order document
+type LC struct {
 	Sender   string
 	Receiver string
 	Tag27    string //Sequence of Total
 	Tag40A   string //Form of documentary credit
 	Tag20    string //Documentary Credit Number
 	Tag31C   string //Date of Issue
-	//Tag40E   string //Applicable Rules
+	Tag40E   string //Applicable Rules
 	Tag31D string //Date and Place of Expiry
 	Tag50  string //Applicant
 	Tag59  string //Beneficiary - Name & Address
@@ -43,35 +41,14 @@ type PO struct {
 	Tag71B string //Charges
 	Tag48  string //Period for Presentation
 	Tag49  string //Confirmation Instructions
-	//Tag53A string //Reimbursing Bank – BIC
+	Tag53A string //Reimbursing Bank – BIC
 	//Tag78  string //Instruction to Paying/Accepting/Negotiating Bank
 	Tag57D string //`Advise Through` Bank -Name&Addr
 }
 
-*/
 //Init initializes the document smart contract
 func (t *PO) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	// Check if table already exists
-	_, err := stub.GetTable("POTable")
-	if err == nil {
-		// Table already exists; do not recreate
-		return nil, nil
-	}
-
-	// Create L/C Table
-	err = stub.CreateTable("POTable", []*shim.ColumnDefinition{
-		&shim.ColumnDefinition{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "UID", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "DocJSON", Type: shim.ColumnDefinition_BYTES, Key: false},
-		//&shim.ColumnDefinition{Name: "DocPDF", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "Status", Type: shim.ColumnDefinition_STRING, Key: false},
-	})
-	if err != nil {
-		return nil, errors.New("Failed creating LCTable.")
-	}
-
 	return nil, nil
-
 }
 
 //TODO: Make sure that args[0] is a JSON object that maps to appropriate struct
@@ -155,7 +132,7 @@ func (t *PO) Init(stub shim.ChaincodeStubInterface, function string, args []stri
 func (t *PO) SubmitDoc(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 2{
-		return nil, errors.New("Incorrect number of arguments. Expecting 2.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
 	}
 
 	UID := args[0]
@@ -172,17 +149,10 @@ func (t *PO) SubmitDoc(stub shim.ChaincodeStubInterface, args []string) ([]byte,
 	*/
 
 	// Insert a row
-	ok, err := stub.InsertRow("POTable", shim.Row{
-		Columns: []*shim.Column{
-			&shim.Column{Value: &shim.Column_String_{String_: "DOC"}},
-			&shim.Column{Value: &shim.Column_String_{String_: UID}},
-			&shim.Column{Value: &shim.Column_Bytes{Bytes: docJSON}},
-			//&shim.Column{Value: &shim.Column_Bytes{Bytes: docPDF}},
-			&shim.Column{Value: &shim.Column_String_{String_: "SUBMITTED_BY_IB"}}},
-	})
+	ok, err := poStore.Insert(stub, &Document{UID: UID, Content: docJSON, Status: "SUBMITTED_BY_IB"})
 
 	if !ok && err == nil {
-		return nil, errors.New("Document already exists.")
+		return nil, newError(codeDuplicate, "Document already exists.")
 	}
 
 	return nil, err
@@ -193,49 +163,27 @@ func (t *PO) SubmitDoc(stub shim.ChaincodeStubInterface, args []string) ([]byte,
 
 func (t *PO) UpdatePO(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
-	if len(args) != 2{
-		return nil, errors.New("Incorrect number of arguments. Expecting 2.")
+	if len(args) != 2 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
 	}
 
 	UID := args[0]
 	docJSON := []byte(args[1])
-	
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-	
 
-	row, err := stub.GetRow("POTable", columns)
+	doc, err := poStore.Get(stub, UID)
 	if err != nil {
-		fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
-	}
-
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		fmt.Errorf("Eror in getting column")
+		return nil, err
 	}
 
-
-	ok, err := stub.ReplaceRow("POTable", shim.Row{
-		Columns: []*shim.Column{
-			&shim.Column{Value: &shim.Column_String_{String_: "DOC"}},
-			&shim.Column{Value: &shim.Column_String_{String_: UID}},
-			&shim.Column{Value: &shim.Column_Bytes{Bytes: docJSON}},
-			&shim.Column{Value: &shim.Column_String_{String_: "PAYMENT_INITIATED"}},
-			
-	}})
-
-	if !ok && err == nil {
-
-		return nil, errors.New("Document unable to Update.")
+	// Get returns nil if key does not exist
+	if doc == nil {
+		return nil, newError(codeNotFound, "Error: Failed retrieving document with UID %s.", UID)
 	}
 
+	doc.Content = docJSON
+	doc.Status = "PAYMENT_INITIATED"
 
-	return nil, err
-
-	
+	return nil, poStore.Replace(stub, doc)
 }
 /*
 //UpdateStatus () – Updates current document Status. Enforces Status transition logic.
@@ -324,30 +272,23 @@ func (t *PO) UpdateStatus(stub shim.ChaincodeStubInterface, args []string) ([]by
 func (t *PO) GetJSON(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
 	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("POTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := poStore.Get(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
+	// Get returns nil if key does not exist
+	if doc == nil {
 		return nil, nil
 	}
 
-	return row.Columns[2].GetBytes(), nil
-
+	return doc.Content, nil
 }
 
 /*
@@ -382,33 +323,25 @@ func (t *PO) GetPDF(stub shim.ChaincodeStubInterface, args []string) ([]byte, er
 }
 */
 
-/*
 // GetStatus () – returns as JSON the Status w.r.t. the UID
 func (t *PO) GetStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
 	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "DOC"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("POTable", columns)
+	// Get the document pertaining to this UID
+	doc, err := poStore.Get(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
+	// Get returns nil if key does not exist
+	if doc == nil {
 		return nil, nil
 	}
 
-	return []byte(row.Columns[3].GetString_()), nil
+	return []byte(doc.Status), nil
 }
-*/
\ No newline at end of file
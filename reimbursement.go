@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Types of the settlement entries between the banks
+const (
+	settlementPayment       = "PAYMENT"       // the importer bank pays the exporter bank
+	settlementReimbursement = "REIMBURSEMENT" // the reimbursing bank pays the claim of the exporter bank
+	settlementCover         = "COVER"         // the importer bank covers the reimbursing bank
+)
+
+// Statuses of the settlement entries, following the payment status of the export documents
+var settlementStatuses = map[string]string{
+	"PAYMENT_INITIATED":  "INITIATED",
+	"PAYMENT_INPROGRESS": "IN_PROGRESS",
+	"PAYMENT_COMPLETED":  "SETTLED",
+}
+
+// ReimbursementAuthorisation authorises the reimbursing bank to honour the claims of the exporter bank,
+// with the semantics of an MT740
+type ReimbursementAuthorisation struct {
+	UID                string `json:"contractID"`
+	LCNumber           string `json:"lcNumber"`           // field 20
+	ReimbursingBank    string `json:"reimbursingBank"`    // receiver, as named in the contract and the settlement entries
+	ReimbursingBankBIC string `json:"reimbursingBankBIC"` // receiver, as identified by Tag53A of the PO
+	ClaimingBank       string `json:"claimingBank"`       // field 58a
+	Amount             string `json:"amount"`             // field 32B
+	Expiry             string `json:"expiry"`             // field 31D
+	ChargesFor         string `json:"chargesFor"`         // field 71A, CLM or OUR
+	AuthorisedAt       string `json:"authorisedAt"`
+	TxID               string `json:"txID"`
+}
+
+// ReimbursementClaim is a claim of the exporter bank on the reimbursing bank, with the semantics of an MT742
+type ReimbursementClaim struct {
+	UID          string `json:"contractID"`
+	Sequence     int    `json:"sequence"`
+	ClaimRef     string `json:"claimRef"`     // field 20
+	LCNumber     string `json:"lcNumber"`     // field 21
+	ClaimingBank string `json:"claimingBank"` // sender
+	Amount       string `json:"amount"`       // field 34a
+	ClaimedAt    string `json:"claimedAt"`
+	TxID         string `json:"txID"`
+}
+
+// Reimbursement is the result of getReimbursement
+type Reimbursement struct {
+	ContractID    string                      `json:"contractID"`
+	Authorisation *ReimbursementAuthorisation `json:"authorisation"`
+	Claims        []ReimbursementClaim        `json:"claims"`
+	Claimed       string                      `json:"claimed,omitempty"`
+	Available     string                      `json:"available,omitempty"`
+}
+
+// SettlementEntry is a transfer between two banks of the contract. Its status follows the payment status of the export documents.
+type SettlementEntry struct {
+	UID          string `json:"contractID"`
+	Sequence     int    `json:"sequence"`
+	Type         string `json:"type"`
+	Debtor       string `json:"debtor"`
+	DebtorRole   string `json:"debtorRole"`
+	Creditor     string `json:"creditor"`
+	CreditorRole string `json:"creditorRole"`
+	Amount       string `json:"amount"`
+	Status       string `json:"status"`
+	EDStatus     string `json:"edStatus"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+}
+
+// SettlementList is the result of getSettlementEntries
+type SettlementList struct {
+	ContractID string            `json:"contractID"`
+	Entries    []SettlementEntry `json:"entries"`
+}
+
+// parseMTAmount splits an amount in the MT format, such as USD10000,50, into its currency and value
+func parseMTAmount(s string) (string, *big.Rat, bool) {
+	m := mtAmountPattern.FindStringSubmatch(s)
+	if m == nil {
+		return "", nil, false
+	}
+
+	value, ok := new(big.Rat).SetString(strings.TrimSuffix(strings.Replace(m[2], ",", ".", 1), "."))
+	if !ok {
+		return "", nil, false
+	}
+
+	return m[1], value, true
+}
+
+// formatMTAmount formats an amount in the MT format
+func formatMTAmount(currency string, value *big.Rat) string {
+	return currency + strings.Replace(value.FloatString(2), ".", ",", 1)
+}
+
+// claimedAmount returns the total of the claims, in the currency of the authorisation
+func claimedAmount(claims []ReimbursementClaim) *big.Rat {
+	total := new(big.Rat)
+	for _, claim := range claims {
+		if _, value, ok := parseMTAmount(claim.Amount); ok {
+			total.Add(total, value)
+		}
+	}
+
+	return total
+}
+
+// authoriseReimbursement authorises the reimbursing bank of the contract, identified by Tag53A, to honour claims up to the LC amount
+func (t *SBI) authoriseReimbursement(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	chargesFor := "CLM"
+	if len(args) > 1 && args[1] != "" {
+		chargesFor = args[1]
+	}
+	if chargesFor != "CLM" && chargesFor != "OUR" {
+		return nil, newError(codeInvalidArgs, "Reimbursing charges are for CLM, the claimant, or OUR, the importer bank. Got: %s.", chargesFor)
+	}
+
+	bp, err := t.getContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if bp.ReimbursingBankName == "" {
+		return nil, newError(codeInvalidTransition, "Contract %s has no reimbursing bank.", contractID)
+	}
+
+	lc, err := t.getLC(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if lc.Tag53A == "" {
+		return nil, newError(codeInvalidTransition, "The PO of contract %s names no reimbursing bank in Tag53A.", contractID)
+	}
+	if _, _, ok := parseMTAmount(lc.Tag32B); !ok {
+		return nil, newError(codeValidationFailed, "The amount of the PO of contract %s is not a currency and an amount: %s.", contractID, lc.Tag32B)
+	}
+
+	// Claims are paid through the reimbursing bank only if it is authorised before the payment
+	edStatus, err := t.currentEDStatus(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if paymentStatus(edStatus) != "NOT_INITIATED" || edStatus == "REJECTED_BY_IB" {
+		return nil, newError(codeInvalidTransition, "Export documents of contract %s are %s, reimbursement can no longer be authorised.", contractID, edStatus)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	authorisation := &ReimbursementAuthorisation{
+		UID:                contractID,
+		LCNumber:           lc.Tag20,
+		ReimbursingBank:    bp.ReimbursingBankName,
+		ReimbursingBankBIC: lc.Tag53A,
+		ClaimingBank:       bp.ExporterBankName,
+		Amount:             lc.Tag32B,
+		Expiry:             lc.Tag31D,
+		ChargesFor:         chargesFor,
+		AuthorisedAt:       now,
+		TxID:               stub.GetTxID(),
+	}
+	ok, err := reimbursementStore.InsertAuthorisation(stub, authorisation)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(codeDuplicate, "Reimbursement of contract %s is already authorised.", contractID)
+	}
+
+	return json.Marshal(authorisation)
+}
+
+// claimReimbursement claims the payment of accepted documents from the reimbursing bank
+func (t *SBI) claimReimbursement(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	currency, amount, ok := parseMTAmount(args[1])
+	if !ok || amount.Sign() <= 0 {
+		return nil, newError(codeInvalidArgs, "Amount should be a currency code followed by a positive amount, such as USD150,00. Got: %s.", args[1])
+	}
+
+	authorisation, err := reimbursementStore.GetAuthorisation(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if authorisation == nil {
+		return nil, newError(codeInvalidTransition, "Reimbursement of contract %s is not authorised.", contractID)
+	}
+
+	edStatus, err := t.currentEDStatus(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if edStatus != "ACCEPTED_BY_IB" {
+		return nil, newError(codeInvalidTransition, "Export documents of contract %s are %s, reimbursement is claimed once they are accepted and before the payment.", contractID, edStatus)
+	}
+
+	claims, err := reimbursementStore.ListClaims(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	authorisedCurrency, authorised, _ := parseMTAmount(authorisation.Amount)
+	if currency != authorisedCurrency {
+		return nil, newError(codeValidationFailed, "Claims of contract %s are in %s. Got: %s.", contractID, authorisedCurrency, currency)
+	}
+	available := new(big.Rat).Sub(authorised, claimedAmount(claims))
+	if amount.Cmp(available) > 0 {
+		return nil, newError(codeValidationFailed, "Claim of %s exceeds the %s left on the authorisation of contract %s.", args[1], formatMTAmount(currency, available), contractID)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	bp, err := t.getContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	sequence := len(claims) + 1
+	claim := &ReimbursementClaim{
+		UID:          contractID,
+		Sequence:     sequence,
+		ClaimRef:     contractID + "-C" + strconv.Itoa(sequence),
+		LCNumber:     authorisation.LCNumber,
+		ClaimingBank: bp.ExporterBankName,
+		Amount:       formatMTAmount(currency, amount),
+		ClaimedAt:    now,
+		TxID:         stub.GetTxID(),
+	}
+	err = reimbursementStore.PutClaim(stub, claim)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(claim)
+}
+
+// settlementLegs returns the transfers paying the export documents: through the reimbursing bank if reimbursement
+// was authorised, directly from the importer bank otherwise
+func (t *SBI) settlementLegs(stub shim.ChaincodeStubInterface, bp *BusinessProcess) ([]SettlementEntry, error) {
+	authorisation, err := reimbursementStore.GetAuthorisation(stub, bp.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	if authorisation == nil {
+		lc, err := t.getLC(stub, bp.UID)
+		if err != nil {
+			return nil, err
+		}
+
+		return []SettlementEntry{
+			{Type: settlementPayment, Debtor: bp.ImporterBankName, DebtorRole: "ImporterBank", Creditor: bp.ExporterBankName, CreditorRole: "ExporterBank", Amount: lc.Tag32B},
+		}, nil
+	}
+
+	claims, err := reimbursementStore.ListClaims(stub, bp.UID)
+	if err != nil {
+		return nil, err
+	}
+	if len(claims) == 0 {
+		return nil, newError(codeInvalidTransition, "Payment of contract %s waits for the reimbursement claim of the exporter bank.", bp.UID)
+	}
+
+	currency, _, _ := parseMTAmount(authorisation.Amount)
+	amount := formatMTAmount(currency, claimedAmount(claims))
+
+	return []SettlementEntry{
+		{Type: settlementReimbursement, Debtor: bp.ReimbursingBankName, DebtorRole: "ReimbursingBank", Creditor: bp.ExporterBankName, CreditorRole: "ExporterBank", Amount: amount},
+		{Type: settlementCover, Debtor: bp.ImporterBankName, DebtorRole: "ImporterBank", Creditor: bp.ReimbursingBankName, CreditorRole: "ReimbursingBank", Amount: amount},
+	}, nil
+}
+
+// trackSettlement records the settlement entries when the payment of the export documents starts
+// and moves them forward with the payment status
+func (t *SBI) trackSettlement(stub shim.ChaincodeStubInterface, UID string, edStatus string) error {
+	status, ok := settlementStatuses[edStatus]
+	if !ok {
+		return nil
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	entries, err := settlementStore.List(stub, UID)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		bp, err := t.getContract(stub, UID)
+		if err != nil {
+			return err
+		}
+		entries, err = t.settlementLegs(stub, bp)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			entries[i].UID = UID
+			entries[i].Sequence = i + 1
+			entries[i].CreatedAt = now
+		}
+	}
+
+	for i := range entries {
+		entries[i].Status = status
+		entries[i].EDStatus = edStatus
+		entries[i].UpdatedAt = now
+
+		err = settlementStore.Put(stub, &entries[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getReimbursement returns the reimbursement authorisation of the contract and the claims made on it
+func (t *SBI) getReimbursement(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	authorisation, err := reimbursementStore.GetAuthorisation(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := reimbursementStore.ListClaims(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Reimbursement{ContractID: contractID, Authorisation: authorisation, Claims: claims}
+	if authorisation != nil {
+		currency, authorised, _ := parseMTAmount(authorisation.Amount)
+		claimed := claimedAmount(claims)
+		result.Claimed = formatMTAmount(currency, claimed)
+		result.Available = formatMTAmount(currency, new(big.Rat).Sub(authorised, claimed))
+	}
+
+	return json.Marshal(result)
+}
+
+// getSettlementEntries returns the settlement entries between the banks of the contract
+func (t *SBI) getSettlementEntries(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	entries, err := settlementStore.List(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(SettlementList{ContractID: args[0], Entries: entries})
+}
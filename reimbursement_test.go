@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReimbursement(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
+
+	stub := newMockStub()
+	importerBankCert, exporterBankCert := []byte(`IBCert`), []byte(`EBCert`)
+	reimbursingBankCert := []byte(`RBCert`)
+
+	var po map[string]string
+	if err := json.Unmarshal(testPOJSON, &po); err != nil {
+		t.Fatal(err)
+	}
+	po["Tag53A"] = "RBNKUS33"
+	poJSON, _ := json.Marshal(po)
+
+	if _, err := stub.invoke(importerBankCert, "initTrade", "1000", string(poJSON), "I", "E", "IB", "EB", "ICert", "ECert", "IBCert", "EBCert", "", "", "RB", "RBCert"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claims need an authorisation
+	if _, err := stub.invoke(exporterBankCert, "claimReimbursement", "1000", "USD4000"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("claimReimbursement without authorisation: %v", err)
+	}
+	if _, err := stub.invoke(importerBankCert, "authoriseReimbursement", "1000", "THEIRS"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("authoriseReimbursement with unknown charges: %v", err)
+	}
+	var authorisation ReimbursementAuthorisation
+	b, err := stub.invoke(importerBankCert, "authoriseReimbursement", "1000")
+	if err = json.Unmarshal(b, &authorisation); err != nil || authorisation.ReimbursingBank != "RB" || authorisation.ReimbursingBankBIC != "RBNKUS33" || authorisation.Amount != "USD10000" || authorisation.ClaimingBank != "EB" || authorisation.ChargesFor != "CLM" {
+		t.Fatalf("authoriseReimbursement: %s %v", b, err)
+	}
+	if _, err = stub.invoke(importerBankCert, "authoriseReimbursement", "1000"); errorCode(err) != codeDuplicate {
+		t.Fatalf("second authoriseReimbursement: %v", err)
+	}
+
+	if err = submitED(stub, exporterBankCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stub.invoke(exporterBankCert, "claimReimbursement", "1000", "USD4000"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("claimReimbursement before the acceptance: %v", err)
+	}
+	if err = acceptED(stub, importerBankCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The payment waits for the claims, which stay within the authorisation
+	if err = acceptED(stub, importerBankCert, "1000"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("payment before the claim: %v", err)
+	}
+	for _, test := range []struct {
+		amount string
+		code   string
+	}{
+		{"USD4000", ""},
+		{"EUR1000", codeValidationFailed},
+		{"USD6000,01", codeValidationFailed},
+		{"USD0", codeInvalidArgs},
+		{"USD6000", ""},
+	} {
+		if _, err = stub.invoke(exporterBankCert, "claimReimbursement", "1000", test.amount); errorCode(err) != test.code {
+			t.Errorf("claimReimbursement of %s: %v, want %q", test.amount, err, test.code)
+		}
+	}
+
+	var reimbursement Reimbursement
+	b, err = stub.query(reimbursingBankCert, "getReimbursement", "1000")
+	if err = json.Unmarshal(b, &reimbursement); err != nil || len(reimbursement.Claims) != 2 || reimbursement.Claims[1].ClaimRef != "1000-C2" || reimbursement.Claimed != "USD10000,00" || reimbursement.Available != "USD0,00" {
+		t.Fatalf("getReimbursement: %s %v", b, err)
+	}
+
+	settlement := func() []SettlementEntry {
+		var list SettlementList
+		b, err := stub.query(reimbursingBankCert, "getSettlementEntries", "1000")
+		if err = json.Unmarshal(b, &list); err != nil {
+			t.Fatal(err)
+		}
+		return list.Entries
+	}
+	if entries := settlement(); len(entries) != 0 {
+		t.Fatalf("settlement entries before the payment: %+v", entries)
+	}
+
+	if err = acceptED(stub, importerBankCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
+	entries := settlement()
+	if len(entries) != 2 ||
+		entries[0].Type != settlementReimbursement || entries[0].Debtor != "RB" || entries[0].Creditor != "EB" || entries[0].Amount != "USD10000,00" || entries[0].Status != "INITIATED" ||
+		entries[1].Type != settlementCover || entries[1].Debtor != "IB" || entries[1].Creditor != "RB" {
+		t.Fatalf("settlement entries once the payment is initiated: %+v", entries)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = acceptED(stub, importerBankCert, "1000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, entry := range settlement() {
+		if entry.Status != "SETTLED" || entry.EDStatus != "PAYMENT_COMPLETED" {
+			t.Fatalf("settlement entry after the payment: %+v", entry)
+		}
+	}
+
+	var participants []Participant
+	b, err = stub.query(reimbursingBankCert, "getContractParticipants", "1000")
+	if err = json.Unmarshal(b, &participants); err != nil || len(participants) != 5 || participants[4] != (Participant{ID: "RB", Role: "ReimbursingBank"}) {
+		t.Fatalf("getContractParticipants: %s %v", b, err)
+	}
+}
+
+func TestSettlementWithoutReimbursement(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	if err := initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(adminCert, "authoriseReimbursement", "1000"); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("authoriseReimbursement without reimbursing bank: %v", err)
+	}
+	if err := submitED(stub, adminCert, "1000", []byte(`BLPDF`), []byte(`InvoicePDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := acceptED(stub, adminCert, "1000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var list SettlementList
+	b, err := stub.query(nil, "getSettlementEntries", "1000")
+	if err = json.Unmarshal(b, &list); err != nil || len(list.Entries) != 1 {
+		t.Fatalf("getSettlementEntries: %s %v", b, err)
+	}
+	if entry := list.Entries[0]; entry.Type != settlementPayment || entry.Debtor != "IB" || entry.Creditor != "EB" || entry.Amount != "USD10000" || entry.Status != "INITIATED" {
+		t.Fatalf("direct payment entry: %+v", entry)
+	}
+}
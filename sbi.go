@@ -4,18 +4,49 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
-	"github.com/hyperledger/fabric/core/crypto/primitives"
 
 	logging "github.com/op/go-logging"
 )
 
 // Access control flag - perform access control if flag is true
-const accessControlFlag bool = false 
+var accessControlFlag = false
 
 var myLogger = logging.MustGetLogger("access_control_helper")
 
+// Business process statuses kept in the Status of the business process
+const (
+	statusPOPending        = "PO_PENDING"
+	statusDocsPending      = "DOCS_PENDING"
+	statusUnderExamination = "UNDER_EXAMINATION"
+	statusPaymentPending   = "PAYMENT_PENDING"
+	statusSettled          = "SETTLED"
+	statusRejected         = "REJECTED"
+	statusExpired          = "EXPIRED"
+	statusCancelled        = "CANCELLED"
+	statusClosed           = "CLOSED"
+	statusComplianceHold   = "COMPLIANCE_HOLD" // a watch list hit stops the trade until it is released
+)
+
+// ContractCount is the result of getNumContracts
+type ContractCount struct {
+	NumContracts int
+}
+
+// TradeInitiated is the result of initTrade
+type TradeInitiated struct {
+	ContractID string `json:"contractID"`
+}
+
+// DocumentStatus is the result of getEDStatus
+type DocumentStatus struct {
+	Status string
+}
+
 // Contract struct
 type Contract struct {
 	ContractID string `json:"contractID"`
@@ -35,8 +66,9 @@ type ContractsList struct {
 
 // Participant struct
 type Participant struct {
-	ID   string `json:"id"`
-	Role string `json:"role"`
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	Confirmed bool   `json:"confirmed,omitempty"` // the confirming bank added its undertaking to the LC
 }
 
 // ParticipantList struct
@@ -44,6 +76,33 @@ type ParticipantList struct {
 	Participants []Participant `json:"participants"`
 }
 
+// DocumentSummary struct
+type DocumentSummary struct {
+	DocType   string `json:"docType"`
+	Submitted bool   `json:"submitted"`
+	Status    string `json:"status"`
+}
+
+// ContractSummary struct
+type ContractSummary struct {
+	ContractID     string              `json:"contractID"`
+	ContractStatus string              `json:"contractStatus"`
+	Participants   []Participant       `json:"participants"`
+	LCNumber       string              `json:"lcNumber"`
+	Amount         string              `json:"amount"`
+	Expiry         string              `json:"expiry"`
+	POStatus       string              `json:"poStatus"`
+	Documents      []DocumentSummary   `json:"documents"`
+	PaymentStatus  string              `json:"paymentStatus"`
+	Obligations    []PaymentObligation `json:"obligations"`
+	LastUpdated    string              `json:"lastUpdated"`
+}
+
+// ContractSummaryList struct
+type ContractSummaryList struct {
+	Contracts []ContractSummary `json:"contracts"`
+}
+
 // SBI is a high level smart contract 
 type SBI struct {
 	po 		PO
@@ -55,37 +114,26 @@ type SBI struct {
 // Init initializes the smart contracts
 func (t *SBI) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 
-	// Check if table already exists
-	_, err := stub.GetTable("BPTable")
-	if err == nil {
-		// Table already exists; do not recreate
-		return nil, nil
-	}
-
-	// Create Business Process Table
-	err = stub.CreateTable("BPTable", []*shim.ColumnDefinition{
-		&shim.ColumnDefinition{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "UID", Type: shim.ColumnDefinition_STRING, Key: true},
-		&shim.ColumnDefinition{Name: "Status", Type: shim.ColumnDefinition_STRING, Key: false},
-		&shim.ColumnDefinition{Name: "ImporterName", Type: shim.ColumnDefinition_STRING, Key: false},
-		&shim.ColumnDefinition{Name: "ExporterName", Type: shim.ColumnDefinition_STRING, Key: false},
-		&shim.ColumnDefinition{Name: "ImporterBankName", Type: shim.ColumnDefinition_STRING, Key: false},
-		&shim.ColumnDefinition{Name: "ExporterBankName", Type: shim.ColumnDefinition_STRING, Key: false},
-		&shim.ColumnDefinition{Name: "ImporterCert", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "ExporterCert", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "ImporterBankCert", Type: shim.ColumnDefinition_BYTES, Key: false},
-		&shim.ColumnDefinition{Name: "ExporterBankCert", Type: shim.ColumnDefinition_BYTES, Key: false},
-	})
-	if err != nil {
-		return nil, errors.New("Failed creating BPTable.")
-	}
-
+	// Contracts, documents and consents live in the world state under composite keys,
+	// there is no table to create. Ledgers written by earlier versions are upgraded in place.
 	t.po.Init(stub, function, args)
 	t.bl.Init(stub, function, args)
 	t.invoice.Init(stub, function, args)
 	t.pl.Init(stub, function, args)
 
-	return nil, nil
+	// The deployer administers the chaincode
+	err := t.setAdmin(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificate of the compliance admin may be given at deployment or upgrade
+	err = setComplianceAdmin(stub, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, t.migrateSchema(stub)
 }
 
 // isCaller is a helper function that verifies the signature of the caller given the certificate to match with
@@ -137,35 +185,52 @@ func (t *SBI) isCaller(stub shim.ChaincodeStubInterface, certificate []byte) (bo
 	return ok, err
 }
 
-// isCallerImporter accepts UID as input and checks if the caller is importer Bank
-func (t *SBI) isCallerImporter(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
-	if len(args) != 1 {
-		return false, errors.New("Incorrect number of arguments. Expecting 1.")
+// setAdmin records the certificate of the deployer, the caller metadata of the deploy transaction, as the admin
+func (t *SBI) setAdmin(stub shim.ChaincodeStubInterface) error {
+	adminCert, err := stub.GetCallerMetadata()
+	if err != nil {
+		return errors.New("Failed getting metadata")
+	}
+	if len(adminCert) == 0 {
+		myLogger.Debugf("No deployer certificate, the admin is kept")
+		return nil
 	}
 
-	UID := args[0]
+	return adminStore.Put(stub, &Admin{Cert: adminCert})
+}
 
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
+// isCallerAdmin checks if the caller is the deployer of the chaincode
+func (t *SBI) isCallerAdmin(stub shim.ChaincodeStubInterface) (bool, error) {
+	admin, err := adminStore.Get(stub)
+	if err != nil {
+		return false, err
+	}
+	if admin == nil {
+		return false, nil
+	}
 
-	row, err := stub.GetRow("BPTable", columns)
+	ok, err := t.isCaller(stub, admin.Cert)
 	if err != nil {
-		return false, errors.New("Failed retrieving row with contract ID " + UID + ". Error " + err.Error())
+		return false, newError(codeAccessDenied, "Failed checking admin's identity %s", err.Error())
 	}
-	if len(row.Columns) == 0 {
-		return false, errors.New("Failed retrieving row with contract ID " + UID)
+
+	return ok, nil
+}
+
+// isCallerImporter accepts UID as input and checks if the caller is importer Bank
+func (t *SBI) isCallerImporter(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+	if len(args) != 1 {
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
-	// Get the importer bank's certificate for this contract - 5th column in the table
-	certificate := row.Columns[7].GetBytes()
-	
+	bp, err := t.getContract(stub, args[0])
+	if err != nil {
+		return false, err
+	}
 
-	ok, err := t.isCaller(stub, certificate)
+	ok, err := t.isCaller(stub, bp.ImporterCert)
 	if err != nil {
-		return false, errors.New("Failed checking importer's identity")
+		return false, newError(codeAccessDenied, "Failed checking importer's identity")
 	}
 	if !ok {
 		return false, nil
@@ -177,32 +242,17 @@ func (t *SBI) isCallerImporter(stub shim.ChaincodeStubInterface, args []string)
 // ExporterBank accepts UID as input and checks if the caller is Exporter Bank
 func (t *SBI) isCallerExporter(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
 	if len(args) != 1 {
-		return false, errors.New("Incorrect number of arguments. Expecting 1.")
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
-	UID := args[0]
-
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("BPTable", columns)
+	bp, err := t.getContract(stub, args[0])
 	if err != nil {
-		return false, errors.New("Failed retrieving row with contract ID " + UID + ". Error " + err.Error())
+		return false, err
 	}
-	if len(row.Columns) == 0 {
-		return false, errors.New("Failed retrieving row with contract ID " + UID)
-	}
-
-	// Get the exporter bank's certificate for this contract - 6th column in the table
-	certificate := row.Columns[8].GetBytes()
-	
 
-	ok, err := t.isCaller(stub, certificate)
+	ok, err := t.isCaller(stub, bp.ExporterCert)
 	if err != nil {
-		return false, errors.New("Failed checking exporter identity " + err.Error())
+		return false, newError(codeAccessDenied, "Failed checking exporter identity %s", err.Error())
 	}
 	if !ok {
 		return false, nil
@@ -214,31 +264,17 @@ func (t *SBI) isCallerExporter(stub shim.ChaincodeStubInterface, args []string)
 // isCallerImporterBank accepts UID as input and checks if the caller is importer Bank
 func (t *SBI) isCallerImporterBank(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
 	if len(args) != 1 {
-		return false, errors.New("Incorrect number of arguments. Expecting 1.")
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
-	UID := args[0]
-
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("BPTable", columns)
+	bp, err := t.getContract(stub, args[0])
 	if err != nil {
-		return false, errors.New("Failed retrieving row with contract ID " + UID + ". Error " + err.Error())
+		return false, err
 	}
-	if len(row.Columns) == 0 {
-		return false, errors.New("Failed retrieving row with contract ID " + UID)
-	}
-
-	// Get the importer bank's certificate for this contract - 5th column in the table
-	certificate := row.Columns[9].GetBytes()
 
-	ok, err := t.isCaller(stub, certificate)
+	ok, err := t.isCaller(stub, bp.ImporterBankCert)
 	if err != nil {
-		return false, errors.New("Failed checking importer bank's identity")
+		return false, newError(codeAccessDenied, "Failed checking importer bank's identity")
 	}
 	if !ok {
 		return false, nil
@@ -250,31 +286,17 @@ func (t *SBI) isCallerImporterBank(stub shim.ChaincodeStubInterface, args []stri
 // ExporterBank accepts UID as input and checks if the caller is Exporter Bank
 func (t *SBI) isCallerExporterBank(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
 	if len(args) != 1 {
-		return false, errors.New("Incorrect number of arguments. Expecting 1.")
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
-	UID := args[0]
-
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
-
-	row, err := stub.GetRow("BPTable", columns)
+	bp, err := t.getContract(stub, args[0])
 	if err != nil {
-		return false, errors.New("Failed retrieving row with contract ID " + UID + ". Error " + err.Error())
+		return false, err
 	}
-	if len(row.Columns) == 0 {
-		return false, errors.New("Failed retrieving row with contract ID " + UID)
-	}
-
-	// Get the exporter bank's certificate for this contract - 6th column in the table
-	certificate := row.Columns[10].GetBytes()
 
-	ok, err := t.isCaller(stub, certificate)
+	ok, err := t.isCaller(stub, bp.ExporterBankCert)
 	if err != nil {
-		return false, errors.New("Failed checking exporter bank's identity " + err.Error())
+		return false, newError(codeAccessDenied, "Failed checking exporter bank's identity %s", err.Error())
 	}
 	if !ok {
 		return false, nil
@@ -283,246 +305,573 @@ func (t *SBI) isCallerExporterBank(stub shim.ChaincodeStubInterface, args []stri
 	return true, nil
 }
 
-// isCallerParticipant accepts UID as input and checks if the caller is Exporter Bank
-func (t *SBI) isCallerParticipant(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+// isCallerConfirmingBank accepts UID as input and checks if the caller is the confirming bank
+func (t *SBI) isCallerConfirmingBank(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
 	if len(args) != 1 {
-		return false, errors.New("Incorrect number of arguments. Expecting 1.")
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
-	UID := args[0]
-
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
+	bp, err := t.getContract(stub, args[0])
+	if err != nil {
+		return false, err
+	}
+	if len(bp.ConfirmingBankCert) == 0 {
+		return false, nil
+	}
 
-	row, err := stub.GetRow("BPTable", columns)
+	ok, err := t.isCaller(stub, bp.ConfirmingBankCert)
 	if err != nil {
-		return false, errors.New("Failed retrieving row with contract ID " + UID + ". Error " + err.Error())
+		return false, newError(codeAccessDenied, "Failed checking confirming bank's identity %s", err.Error())
 	}
-	if len(row.Columns) == 0 {
-		return false, errors.New("Failed retrieving row with contract ID " + UID)
+	if !ok {
+		return false, nil
 	}
 
-	// Get certificates
-	certificate1 := row.Columns[7].GetBytes()
-	certificate2 := row.Columns[8].GetBytes()
-	certificate3 := row.Columns[9].GetBytes()
-	certificate4 := row.Columns[10].GetBytes()
+	return true, nil
+}
 
-	ok1, err1 := t.isCaller(stub, certificate1)
-	ok2, err2 := t.isCaller(stub, certificate2)
-	ok3, err3 := t.isCaller(stub, certificate3)
-	ok4, err4 := t.isCaller(stub, certificate4)
+// isCallerReimbursingBank accepts UID as input and checks if the caller is the reimbursing bank
+func (t *SBI) isCallerReimbursingBank(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+	if len(args) != 1 {
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
 
-	if err1 != nil && err2 != nil && err3 != nil && err4 != nil {
-		return false, errors.New(err1.Error() + " " + err2.Error() + " " + err3.Error() + " " + err4.Error())
+	bp, err := t.getContract(stub, args[0])
+	if err != nil {
+		return false, err
+	}
+	if len(bp.ReimbursingBankCert) == 0 {
+		return false, nil
 	}
 
-	if !ok1 && !ok2 && !ok3 && !ok4 {
+	ok, err := t.isCaller(stub, bp.ReimbursingBankCert)
+	if err != nil {
+		return false, newError(codeAccessDenied, "Failed checking reimbursing bank's identity %s", err.Error())
+	}
+	if !ok {
 		return false, nil
 	}
 
 	return true, nil
 }
 
-// getNumContracts get total number of LC applications. Helper function to generate next contract ID.
-func (t *SBI) getNumContracts(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	if len(args) != 0 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 0.")
+// isCallerParticipant accepts UID as input and checks if the caller is Exporter Bank
+func (t *SBI) isCallerParticipant(stub shim.ChaincodeStubInterface, args []string) (bool, error) {
+	if len(args) != 1 {
+		return false, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
 
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
+	bp, err := t.getContract(stub, args[0])
+	if err != nil {
+		return false, err
+	}
 
-	contractCounter := 0
+	ok1, err1 := t.isCaller(stub, bp.ImporterCert)
+	ok2, err2 := t.isCaller(stub, bp.ExporterCert)
+	ok3, err3 := t.isCaller(stub, bp.ImporterBankCert)
+	ok4, err4 := t.isCaller(stub, bp.ExporterBankCert)
 
-	rows, err := stub.GetRows("BPTable", columns)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve row")
+	if err1 != nil && err2 != nil && err3 != nil && err4 != nil {
+		return false, newError(codeAccessDenied, "%s %s %s %s", err1.Error(), err2.Error(), err3.Error(), err4.Error())
 	}
 
-	for row := range rows {
-		if len(row.Columns) != 0 {
-			contractCounter++
+	if !ok1 && !ok2 && !ok3 && !ok4 {
+		// The optional participants
+		ok, err := t.isCallerConfirmingBank(stub, args)
+		if err != nil || ok {
+			return ok, err
 		}
+		return t.isCallerReimbursingBank(stub, args)
 	}
 
-	type count struct {
-		NumContracts int
-	}
+	return true, nil
+}
 
-	var c count
-	c.NumContracts = contractCounter
+// txTimestamp returns the transaction timestamp so that every peer computes the same time
+func txTimestamp(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("Failed getting transaction timestamp. Error " + err.Error())
+	}
 
-	return json.Marshal(c)
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
 }
 
-// listContracts  lists all the contracts
-func (t *SBI) listContracts(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	if len(args) != 0 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 0.")
+// txTime returns the transaction timestamp in RFC 3339 format
+func txTime(stub shim.ChaincodeStubInterface) (string, error) {
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return "", err
 	}
 
-	var allContractsList ContractsList
+	return now.Format(time.RFC3339), nil
+}
 
-	// Get the row pertaining to this contractID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
+// isExpired returns true if the expiry date of Tag31D (YYMMDD followed by the place of expiry) is before now
+func isExpired(tag31D string, now time.Time) bool {
+	if len(tag31D) < 6 {
+		return false
+	}
 
-	rows, err := stub.GetRows("BPTable", columns)
+	expiry, err := time.Parse("060102", tag31D[:6])
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve row")
+		return false
 	}
 
-	allContractsList.Contracts = make([]Contract, 0)
-
-	for row := range rows {
-		if len(row.Columns) == 0 {
-			res := make(map[string]string, 0)
-			resjson, err := json.Marshal(res)
-			return resjson, err
-		}
+	// The credit is available until the end of the expiry date
+	return now.After(expiry.AddDate(0, 0, 1))
+}
 
-		var nextContract Contract
-		nextContract.ContractID = row.Columns[1].GetString_()
-		nextContract.ContractStatus = row.Columns[2].GetString_()
+// deriveContractStatus computes the overall business process status from the PO and export documents statuses
+func deriveContractStatus(poStatus string, edStatus string, tag31D string, now time.Time) string {
+	if poStatus == "" {
+		return statusPOPending
+	}
 
-		//api change to send contract status start
-		b, err := t.bl.GetStatus(stub,[]string{nextContract.ContractID})
-		if err != nil {
-			return nil, err
+	switch edStatus {
+	case "":
+		if isExpired(tag31D, now) {
+			return statusExpired
 		}
+		return statusDocsPending
+	case "SUBMITTED_BY_EB":
+		return statusUnderExamination
+	case "REJECTED_BY_IB":
+		return statusRejected
+	case "PAYMENT_COMPLETED":
+		return statusSettled
+	}
 
-		if string(b) != "" {
-		nextContract.ContractStatus = string(b)
-	     }
-		//end of change 
-		if accessControlFlag == true {
-			res, err := t.isCallerParticipant(stub, []string{nextContract.ContractID})
-			if err != nil {
-				return nil, err
-			}
-			if res == true {
-				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-			}
-		} else {
-			allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-		}
+	//ACCEPTED_BY_IB, PAYMENT_INITIATED and PAYMENT_INPROGRESS
+	return statusPaymentPending
+}
 
+// getContract returns the business process of the contract
+func (t *SBI) getContract(stub shim.ChaincodeStubInterface, UID string) (*BusinessProcess, error) {
+	bp, err := bpStore.Get(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+	if bp == nil {
+		return nil, newError(codeNotFound, "Failed retrieving row with contract ID %s", UID)
 	}
 
-	return json.Marshal(allContractsList)
+	return bp, nil
 }
 
-// listContractsByRole  lists all the contracts where the user belongs to the provided role.
-func (t *SBI) listContractsByRole(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+// setContractStatus stores the business process status of the contract and records the transaction time as its last update
+func (t *SBI) setContractStatus(stub shim.ChaincodeStubInterface, bp *BusinessProcess, status string) error {
+	now, err := txTime(stub)
+	if err != nil {
+		return err
 	}
 
-	var allContractsList ContractsList
+	bp.Status = status
+	bp.LastUpdated = now
 
-	role := args[0]
+	return bpStore.Replace(stub, bp)
+}
 
-	if role != "Importer" && role != "Exporter" && role != "ImporterBank" && role != "ExporterBank" {
-		return nil, errors.New("Role should be Importer, Exporter, ImporterBank or ExporterBank.")
+// refreshContract recomputes the business process status of the contract and records the transaction time as its last update
+func (t *SBI) refreshContract(stub shim.ChaincodeStubInterface, UID string) error {
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return err
 	}
 
-	// Get the row pertaining to this contractID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
+	// Cancelled, closed and held contracts keep their status
+	if isTerminalStatus(bp.Status) || bp.Status == statusComplianceHold {
+		return t.setContractStatus(stub, bp, bp.Status)
+	}
 
-	rows, err := stub.GetRows("BPTable", columns)
+	status, err := t.derivedStatus(stub, UID)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve row")
+		return err
 	}
 
-	allContractsList.Contracts = make([]Contract, 0)
-
-	for row := range rows {
-		if len(row.Columns) == 0 {
-			res := make(map[string]string, 0)
-			resjson, err := json.Marshal(res)
-			return resjson, err
-		}
+	return t.setContractStatus(stub, bp, status)
+}
 
-		var nextContract Contract
-		nextContract.ContractID = row.Columns[1].GetString_()
-		nextContract.ContractStatus = row.Columns[2].GetString_()
+// derivedStatus derives the business process status of the contract from its PO and export documents at the transaction time
+func (t *SBI) derivedStatus(stub shim.ChaincodeStubInterface, UID string) (string, error) {
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return "", err
+	}
 
-		//api change to send contract status start
-		b, err := t.bl.GetStatus(stub,[]string{nextContract.ContractID})
-		if err != nil {
-			return nil, err
-		}
+	poStatus, err := t.po.GetStatus(stub, []string{UID})
+	if err != nil {
+		return "", err
+	}
 
-		if string(b) != "" {
-		nextContract.ContractStatus = string(b)
-	     }
-		//end of change
+	lc, err := t.getLC(stub, UID)
+	if err != nil {
+		return "", err
+	}
 
+	edStatus, err := t.currentEDStatus(stub, UID)
+	if err != nil {
+		return "", err
+	}
 
-		if role == "Importer" && accessControlFlag == true {
-			res, err := t.isCallerImporter(stub, []string{nextContract.ContractID})
-			if err != nil {
-				return nil, err
-			}
-			if res == true {
-				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-			}
+	return deriveContractStatus(string(poStatus), edStatus, lc.Tag31D, now), nil
+}
 
-		} else if role == "Exporter" && accessControlFlag == true {
-			res, err := t.isCallerExporter(stub, []string{nextContract.ContractID})
-			if err != nil {
-				return nil, err
-			}
-			if res == true {
-				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-			}
+// currentEDStatus returns the status of the export documents of the contract, or "" if none was submitted
+func (t *SBI) currentEDStatus(stub shim.ChaincodeStubInterface, UID string) (string, error) {
+	//since all export documents are always kept in the same state, it is enough to check against one.
+	doc, err := blStore.Get(stub, UID)
+	if err != nil || doc == nil {
+		return "", err
+	}
 
-		} else if role == "ImporterBank" && accessControlFlag == true {
-			res, err := t.isCallerImporterBank(stub, []string{nextContract.ContractID})
-			if err != nil {
-				return nil, err
-			}
-			if res == true {
-				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-			}
+	return doc.Status, nil
+}
 
-		} else if role == "ExporterBank" && accessControlFlag == true {
-			res, err := t.isCallerExporterBank(stub, []string{nextContract.ContractID})
-			if err != nil {
-				return nil, err
-			}
-			if res == true {
-				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-			}
+// currentStatus returns the business process status of the contract at the transaction time. A credit lapses
+// without any transaction, so a contract still waiting for documents is EXPIRED once Tag31D has passed even
+// if no invoke stored that status yet.
+func (t *SBI) currentStatus(stub shim.ChaincodeStubInterface, bp *BusinessProcess) (string, error) {
+	if bp.Status != statusDocsPending {
+		return bp.Status, nil
+	}
 
-		} else {
-			allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
-		}
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return "", err
+	}
 
+	lc, err := t.getLC(stub, bp.UID)
+	if err != nil {
+		return "", err
+	}
+	if isExpired(lc.Tag31D, now) {
+		return statusExpired, nil
 	}
 
-	return json.Marshal(allContractsList)
+	return bp.Status, nil
 }
 
-/*
-//listLCsByStatus  lists all the contracts
-func (t *SBI) listPOsByStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+// listCurrentContracts returns the business processes of all the contracts with their status at the transaction time
+func (t *SBI) listCurrentContracts(stub shim.ChaincodeStubInterface) ([]*BusinessProcess, error) {
+	bps, err := bpStore.List(stub)
+	if err != nil {
+		return nil, err
 	}
 
-	status := args[0]
-	var allContractsList ContractsList
+	for _, bp := range bps {
+		bp.Status, err = t.currentStatus(stub, bp)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Get the row pertaining to this contractID
+	return bps, nil
+}
+
+// getLC returns the PO of the contract, or an empty LC if the PO is missing or is not valid JSON
+func (t *SBI) getLC(stub shim.ChaincodeStubInterface, UID string) (LC, error) {
+	var lc LC
+
+	poJSON, err := t.po.GetJSON(stub, []string{UID})
+	if err != nil {
+		return lc, err
+	}
+	if len(poJSON) != 0 {
+		if err := json.Unmarshal(poJSON, &lc); err != nil {
+			myLogger.Debugf("PO of contract %s is not valid JSON: %s", UID, err.Error())
+		}
+	}
+
+	return lc, nil
+}
+
+// isTerminalStatus returns true if no further invoke is allowed on a contract in this status
+func isTerminalStatus(status string) bool {
+	return status == statusCancelled || status == statusClosed
+}
+
+// checkContractOpen returns an error if the contract is cancelled, closed or on compliance hold
+func (t *SBI) checkContractOpen(stub shim.ChaincodeStubInterface, UID string) error {
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return err
+	}
+
+	if isTerminalStatus(bp.Status) {
+		return newError(codeInvalidTransition, "Contract %s is %s. No further changes are allowed.", UID, bp.Status)
+	}
+	if bp.Status == statusComplianceHold {
+		return newError(codeInvalidTransition, "Contract %s is on compliance hold until it is released.", UID)
+	}
+
+	return nil
+}
+
+// includeClosedArg parses the optional flag at position i, false if missing or empty, that includes cancelled and closed contracts in list queries
+func includeClosedArg(args []string, i int) (bool, error) {
+	return boolArg(args, i, "includeClosed")
+}
+
+// isIrrevocable returns true if the form of documentary credit (Tag40A) is irrevocable
+func isIrrevocable(lc LC) bool {
+	return strings.Contains(strings.ToUpper(lc.Tag40A), "IRREVOCABLE")
+}
+
+// cancelTrade records the consent of a party to cancel the contract and cancels it once all required parties consented.
+// The importer bank must always consent; for an irrevocable credit the exporter or the exporter bank must consent too.
+func (t *SBI) cancelTrade(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 2 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2.")
+	}
+
+	UID := args[0]
+	role := args[1]
+
+	if role != "ImporterBank" && role != "Exporter" && role != "ExporterBank" {
+		return nil, newError(codeInvalidArgs, "Role should be ImporterBank, Exporter or ExporterBank.")
+	}
+
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	if bp.Status != statusPOPending && bp.Status != statusDocsPending && bp.Status != statusUnderExamination && bp.Status != statusRejected && bp.Status != statusExpired {
+		return nil, newError(codeInvalidTransition, "Contract %s can not be cancelled in status %s.", UID, bp.Status)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := consentStore.Insert(stub, &Consent{Action: "CANCEL", UID: UID, Role: role, ConsentedAt: now})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(codeDuplicate, "Cancellation already consented by %s.", role)
+	}
+
+	consents, err := consentStore.List(stub, "CANCEL", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	consented := make(map[string]bool)
+	for _, consent := range consents {
+		consented[consent.Role] = true
+	}
+
+	lc, err := t.getLC(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !consented["ImporterBank"] {
+		return nil, nil
+	}
+	if isIrrevocable(lc) && !consented["Exporter"] && !consented["ExporterBank"] {
+		return nil, nil
+	}
+
+	return nil, t.setContractStatus(stub, bp, statusCancelled)
+}
+
+// closeTrade closes a contract once the payment is completed
+func (t *SBI) closeTrade(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
+
+	UID := args[0]
+
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	if bp.Status != statusSettled {
+		return nil, newError(codeInvalidTransition, "Contract %s can only be closed once the payment is completed.", UID)
+	}
+
+	return nil, t.setContractStatus(stub, bp, statusClosed)
+}
+
+// getNumContracts get total number of LC applications. Contract IDs are allocated by initTrade.
+func (t *SBI) getNumContracts(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 0 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 0.")
+	}
+
+	bps, err := bpStore.List(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	var c ContractCount
+	c.NumContracts = len(bps)
+
+	return json.Marshal(c)
+}
+
+// listContracts  lists all the contracts. Cancelled and closed contracts are only listed if the optional includeClosed flag is true.
+func (t *SBI) listContracts(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) > 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 0 or 1.")
+	}
+
+	includeClosed, err := includeClosedArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var allContractsList ContractsList
+
+	bps, err := t.listCurrentContracts(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	allContractsList.Contracts = make([]Contract, 0)
+
+	for _, bp := range bps {
+		var nextContract Contract
+		nextContract.ContractID = bp.UID
+		nextContract.ContractStatus = bp.Status
+
+		if !includeClosed && isTerminalStatus(nextContract.ContractStatus) {
+			continue
+		}
+
+		if accessControlFlag == true {
+			res, err := t.isCallerParticipant(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+		} else {
+			allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+		}
+
+	}
+
+	return json.Marshal(allContractsList)
+}
+
+// listContractsByRole  lists all the contracts where the user belongs to the provided role.
+// Cancelled and closed contracts are only listed if the optional includeClosed flag is true.
+func (t *SBI) listContractsByRole(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1 or 2.")
+	}
+
+	includeClosed, err := includeClosedArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var allContractsList ContractsList
+
+	role := args[0]
+
+	if role != "Importer" && role != "Exporter" && role != "ImporterBank" && role != "ExporterBank" && role != "ConfirmingBank" && role != "ReimbursingBank" {
+		return nil, newError(codeInvalidArgs, "Role should be Importer, Exporter, ImporterBank, ExporterBank, ConfirmingBank or ReimbursingBank.")
+	}
+
+	bps, err := t.listCurrentContracts(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	allContractsList.Contracts = make([]Contract, 0)
+
+	for _, bp := range bps {
+		var nextContract Contract
+		nextContract.ContractID = bp.UID
+		nextContract.ContractStatus = bp.Status
+
+		if !includeClosed && isTerminalStatus(nextContract.ContractStatus) {
+			continue
+		}
+
+		if role == "Importer" && accessControlFlag == true {
+			res, err := t.isCallerImporter(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+
+		} else if role == "Exporter" && accessControlFlag == true {
+			res, err := t.isCallerExporter(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+
+		} else if role == "ImporterBank" && accessControlFlag == true {
+			res, err := t.isCallerImporterBank(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+
+		} else if role == "ExporterBank" && accessControlFlag == true {
+			res, err := t.isCallerExporterBank(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+
+		} else if role == "ConfirmingBank" && accessControlFlag == true {
+			res, err := t.isCallerConfirmingBank(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+
+		} else if role == "ReimbursingBank" && accessControlFlag == true {
+			res, err := t.isCallerReimbursingBank(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+
+		} else {
+			allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+		}
+
+	}
+
+	return json.Marshal(allContractsList)
+}
+
+/*
+//listLCsByStatus  lists all the contracts
+func (t *SBI) listPOsByStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+	}
+
+	status := args[0]
+	var allContractsList ContractsList
+
+	// Get the row pertaining to this contractID
 	var columns []shim.Column
 	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
 	columns = append(columns, col1)
@@ -572,10 +921,57 @@ func (t *SBI) listPOsByStatus(stub shim.ChaincodeStubInterface, args []string) (
 }
 */
 
-//listEDsByStatus  lists all the contracts
-func (t *SBI) listEDsByStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+//listContractsByStatus  lists all the contracts in the given business process status
+func (t *SBI) listContractsByStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
+
+	status := args[0]
+	var allContractsList ContractsList
+
+	bps, err := t.listCurrentContracts(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	allContractsList.Contracts = make([]Contract, 0)
+
+	for _, bp := range bps {
+		if bp.Status != status {
+			continue
+		}
+
+		var nextContract Contract
+		nextContract.ContractID = bp.UID
+		nextContract.ContractStatus = status
+
+		if accessControlFlag == true {
+			res, err := t.isCallerParticipant(stub, []string{nextContract.ContractID})
+			if err != nil {
+				return nil, err
+			}
+			if res == true {
+				allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+			}
+		} else {
+			allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
+		}
+	}
+
+	return json.Marshal(allContractsList)
+}
+
+//listEDsByStatus  lists all the contracts whose export documents are in the given status.
+//Cancelled and closed contracts are only listed if the optional includeClosed flag is true.
+func (t *SBI) listEDsByStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1 or 2.")
+	}
+
+	includeClosed, err := includeClosedArg(args, 1)
+	if err != nil {
+		return nil, err
 	}
 
 	status := args[0]
@@ -587,36 +983,27 @@ func (t *SBI) listEDsByStatus(stub shim.ChaincodeStubInterface, args []string) (
 
 	var allContractsList ContractsList
 
-	// Get the row pertaining to this contractID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-
-	rows, err := stub.GetRows("BPTable", columns)
+	bps, err := t.listCurrentContracts(stub)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve row")
+		return nil, err
 	}
 
 	allContractsList.Contracts = make([]Contract, 0)
 
-	for row := range rows {
-		// GetRows returns empty message if key does not exist
-		if len(row.Columns) == 0 {
-			res := make(map[string]string, 0)
-			resjson, err := json.Marshal(res)
-			return resjson, err
-		}
-
+	for _, bp := range bps {
 		var nextContract Contract
 
-		//since all export documents are always kept in the same state, it is enough to check against one.
-		b, err := t.bl.GetStatus(stub, []string{row.Columns[1].GetString_()})
+		if !includeClosed && isTerminalStatus(bp.Status) {
+			continue
+		}
+
+		edStatus, err := t.currentEDStatus(stub, bp.UID)
 		if err != nil {
 			return nil, err
 		}
-		if status == string(b) {
-			nextContract.ContractID = row.Columns[1].GetString_()
-			nextContract.ContractStatus = string(b)
+		if status == edStatus {
+			nextContract.ContractID = bp.UID
+			nextContract.ContractStatus = bp.Status
 
 			//allContractsList.Contracts = append(allContractsList.Contracts, nextContract)
 			if accessControlFlag == true {
@@ -638,467 +1025,687 @@ func (t *SBI) listEDsByStatus(stub shim.ChaincodeStubInterface, args []string) (
 	return json.Marshal(allContractsList)
 }
 
+// contractParticipants lists the participants of the contract with their roles
+func contractParticipants(bp *BusinessProcess, confirmation *Confirmation) []Participant {
+	participants := []Participant{
+		{ID: bp.ImporterName, Role: "Importer"},
+		{ID: bp.ExporterName, Role: "Exporter"},
+		{ID: bp.ImporterBankName, Role: "ImporterBank"},
+		{ID: bp.ExporterBankName, Role: "ExporterBank"},
+	}
+	if bp.ConfirmingBankName != "" {
+		participants = append(participants, Participant{ID: bp.ConfirmingBankName, Role: "ConfirmingBank", Confirmed: confirmation != nil})
+	}
+	if bp.ReimbursingBankName != "" {
+		participants = append(participants, Participant{ID: bp.ReimbursingBankName, Role: "ReimbursingBank"})
+	}
+
+	return participants
+}
+
 // getContractParticipants () – returns as JSON the Status w.r.t. the UID
 func (t *SBI) getContractParticipants(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
 	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1.")
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
+
+	UID := args[0]
+
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation, err := confirmationStore.Get(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(contractParticipants(bp, confirmation))
+}
+
+// paymentStatus maps the export documents status onto the payment lifecycle
+func paymentStatus(edStatus string) string {
+	if edStatus == "PAYMENT_INITIATED" || edStatus == "PAYMENT_INPROGRESS" || edStatus == "PAYMENT_COMPLETED" {
+		return edStatus
+	}
+	return "NOT_INITIATED"
+}
+
+// buildContractSummary aggregates the business process row, the PO and the export documents of a contract
+func (t *SBI) buildContractSummary(stub shim.ChaincodeStubInterface, bp *BusinessProcess) (ContractSummary, error) {
+	var summary ContractSummary
+
+	summary.ContractID = bp.UID
+	summary.ContractStatus = bp.Status
+	summary.LastUpdated = bp.LastUpdated
+
+	confirmation, err := confirmationStore.Get(stub, bp.UID)
+	if err != nil {
+		return summary, err
+	}
+	summary.Participants = contractParticipants(bp, confirmation)
+
+	// Key terms of the letter of credit
+	lc, err := t.getLC(stub, summary.ContractID)
+	if err != nil {
+		return summary, err
+	}
+	summary.LCNumber = lc.Tag20
+	summary.Amount = lc.Tag32B
+	summary.Expiry = lc.Tag31D
+
+	b, err := t.po.GetStatus(stub, []string{summary.ContractID})
+	if err != nil {
+		return summary, err
+	}
+	summary.POStatus = string(b)
+
+	// Export documents, the optional types only once submitted
+	summary.Documents = make([]DocumentSummary, 0, len(documentTypes))
+	for _, dt := range documentTypes {
+		doc, err := dt.Store.Get(stub, summary.ContractID)
+		if err != nil {
+			return summary, err
+		}
+		if doc == nil && dt.Optional {
+			continue
+		}
+		ds := DocumentSummary{DocType: dt.Code}
+		if doc != nil {
+			ds.Submitted = true
+			ds.Status = doc.Status
+		}
+		summary.Documents = append(summary.Documents, ds)
+	}
+
+	//since all export documents are always kept in the same state, the BL status drives the payment status
+	summary.PaymentStatus = paymentStatus(summary.Documents[0].Status)
+	summary.Obligations = paymentObligations(bp, confirmation, summary.Documents[0].Status)
+
+	return summary, nil
+}
+
+// getContractSummary () – returns as JSON the aggregated view of a contract w.r.t. the UID
+func (t *SBI) getContractSummary(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	if len(args) != 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
+
+	UID := args[0]
+
+	bp, err := t.getContract(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+	bp.Status, err = t.currentStatus(stub, bp)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := t.buildContractSummary(stub, bp)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(summary)
+}
+
+// listContractSummaries lists the aggregated view of all the contracts.
+// Cancelled and closed contracts are only listed if the optional includeClosed flag is true.
+func (t *SBI) listContractSummaries(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) > 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 0 or 1.")
+	}
+
+	includeClosed, err := includeClosedArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaryList ContractSummaryList
+	summaryList.Contracts = make([]ContractSummary, 0)
+
+	bps, err := t.listCurrentContracts(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bp := range bps {
+		if !includeClosed && isTerminalStatus(bp.Status) {
+			continue
+		}
+
+		if accessControlFlag == true {
+			res, err := t.isCallerParticipant(stub, []string{bp.UID})
+			if err != nil {
+				return nil, err
+			}
+			if res == false {
+				continue
+			}
+		}
+
+		summary, err := t.buildContractSummary(stub, bp)
+		if err != nil {
+			return nil, err
+		}
+		summaryList.Contracts = append(summaryList.Contracts, summary)
 	}
 
-	var participantList ParticipantList
-	participantList.Participants = make([]Participant, 0)
+	return json.Marshal(summaryList)
+}
+
 
-	UID := args[0]
 
-	// Get the row pertaining to this UID
-	var columns []shim.Column
-	col1 := shim.Column{Value: &shim.Column_String_{String_: "BP"}}
-	columns = append(columns, col1)
-	col2 := shim.Column{Value: &shim.Column_String_{String_: UID}}
-	columns = append(columns, col2)
 
-	row, err := stub.GetRow("BPTable", columns)
+// initTrade starts a trade: it stores the business process with its participants and the PO
+func (t *SBI) initTrade(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	UID, err := t.createTrade(stub, args)
 	if err != nil {
-		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+		return nil, err
 	}
 
-	// GetRows returns empty message if key does not exist
-	if len(row.Columns) == 0 {
-		return nil, nil
+	// The trade is created, but held if a party or a port is on the watch list
+	err = t.screenContract(stub, UID)
+	if err != nil {
+		return nil, err
 	}
 
-	var participant Participant
-	participant.ID = row.Columns[3].GetString_()
-	participant.Role = "Importer"
-	participantList.Participants = append(participantList.Participants, participant)
-
-	participant.ID = row.Columns[4].GetString_()
-	participant.Role = "Exporter"
-	participantList.Participants = append(participantList.Participants, participant)
-
-	participant.ID = row.Columns[5].GetString_()
-	participant.Role = "ImporterBank"
-	participantList.Participants = append(participantList.Participants, participant)
-
-	participant.ID = row.Columns[6].GetString_()
-	participant.Role = "ExporterBank"
-	participantList.Participants = append(participantList.Participants, participant)
-
-	return json.Marshal(participantList.Participants)
+	return json.Marshal(TradeInitiated{ContractID: UID})
 }
 
+// createTrade stores the business process with its participants and the PO, and returns its contract ID.
+// The trade is not screened.
+func (t *SBI) createTrade(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) < 10 || len(args) > 14 {
+		return "", newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 10 to 14. Got: %d.", len(args))
+	}
 
+	UID := args[0]
+	POJSON := args[1]
+	importerName := args[2]
+	exporterName := args[3]
+	importerBankName := args[4]
+	exporterBankName := args[5]
+	importerCert := []byte(args[6])
+	exporterCert := []byte(args[7])
+	importerBankCert := []byte(args[8])
+	exporterBankCert := []byte(args[9])
+
+	var confirmingBankName, confirmingBankCert string
+	if len(args) > 10 {
+		confirmingBankName = args[10]
+	}
+	if len(args) > 11 {
+		confirmingBankCert = args[11]
+	}
+	if (confirmingBankName == "") != (confirmingBankCert == "") {
+		return "", newError(codeInvalidArgs, "A confirming bank needs both its name and its certificate.")
+	}
 
+	var reimbursingBankName, reimbursingBankCert string
+	if len(args) > 12 {
+		reimbursingBankName = args[12]
+	}
+	if len(args) > 13 {
+		reimbursingBankCert = args[13]
+	}
+	if (reimbursingBankName == "") != (reimbursingBankCert == "") {
+		return "", newError(codeInvalidArgs, "A reimbursing bank needs both its name and its certificate.")
+	}
 
-// Invoke invokes the chaincode
-func (t *SBI) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-
-	if function == "initTrade" {
-		if len(args) != 10 {
-			return nil, fmt.Errorf("Incorrect number of arguments. Expecting 10. Got: %d.", len(args))
-		}
+	POJSON, err := poFromArg(POJSON)
+	if err != nil {
+		return "", err
+	}
 
-		UID := args[0]
-		POJSON := args[1]
-		importerName := args[2]
-		exporterName := args[3]
-		importerBankName := args[4]
-		exporterBankName := args[5]
-		importerCert := []byte(args[6])
-		exporterCert := []byte(args[7])
-		importerBankCert := []byte(args[8])
-		exporterBankCert := []byte(args[9])
-
-		// Insert a row
-		ok, err := stub.InsertRow("BPTable", shim.Row{
-			Columns: []*shim.Column{
-				&shim.Column{Value: &shim.Column_String_{String_: "BP"}},
-				&shim.Column{Value: &shim.Column_String_{String_: UID}},
-				//&shim.Column{Value: &shim.Column_String_{String_: "Started"}},
-				&shim.Column{Value: &shim.Column_String_{String_: "IN_PROGRESS"}},
-				&shim.Column{Value: &shim.Column_String_{String_: importerName}},
-				&shim.Column{Value: &shim.Column_String_{String_: exporterName}},
-				&shim.Column{Value: &shim.Column_String_{String_: importerBankName}},
-				&shim.Column{Value: &shim.Column_String_{String_: exporterBankName}},
-				&shim.Column{Value: &shim.Column_Bytes{Bytes: importerCert}},
-				&shim.Column{Value: &shim.Column_Bytes{Bytes: exporterCert}},
-				&shim.Column{Value: &shim.Column_Bytes{Bytes: importerBankCert}},
-				&shim.Column{Value: &shim.Column_Bytes{Bytes: exporterBankCert}}},
-		})
+	now, err := txTime(stub)
+	if err != nil {
+		return "", err
+	}
 
+	if UID == "" {
+		UID, err = t.allocateContractID(stub, importerBankName)
 		if err != nil {
-			return nil, err
-		}
-		if !ok && err == nil {
-			return nil, errors.New("Row already exists.")
+			return "", err
 		}
+	}
 
-		return t.po.SubmitDoc(stub, []string{UID, POJSON})
-	
-	
+	// Insert a row, the status is computed once the PO is stored
+	ok, err := bpStore.Insert(stub, &BusinessProcess{
+		UID:              UID,
+		Status:           statusPOPending,
+		ImporterName:     importerName,
+		ExporterName:     exporterName,
+		ImporterBankName: importerBankName,
+		ExporterBankName: exporterBankName,
+		ImporterCert:     importerCert,
+		ExporterCert:     exporterCert,
+		ImporterBankCert: importerBankCert,
+		ExporterBankCert: exporterBankCert,
+		LastUpdated:      now,
+
+		ConfirmingBankName:  confirmingBankName,
+		ConfirmingBankCert:  []byte(confirmingBankCert),
+		ReimbursingBankName: reimbursingBankName,
+		ReimbursingBankCert: []byte(reimbursingBankCert),
+	})
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", newError(codeDuplicate, "Row already exists.")
+	}
 
-	/*else if function == "acceptPO" {
-		if accessControlFlag == true {
-			res, err := t.isCallerExporterBank(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
-		args = append(args, "ACCEPTED_BY_EB")
-		return t.po.UpdateStatus(stub, args)
-	}else if function == "rejectPO" {
-		if accessControlFlag == true {
-			res, err := t.isCallerExporterBank(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
-		args = append(args, "REJECTED_BY_EB")
-		return t.po.UpdateStatus(stub, args)
-	}  */
-	} else if function == "updatePO" {
-		if len(args) != 2 {
-			return nil, fmt.Errorf("Incorrect number of arguments. Expecting 2. Got: %d.", len(args))
-		}
+	_, err = t.po.SubmitDoc(stub, []string{UID, POJSON})
+	if err != nil {
+		return "", err
+	}
 
+	err = t.captureChecklist(stub, UID)
+	if err != nil {
+		return "", err
+	}
 
-		UID := args[0]
-		POJSON := args[1]
-		
-		if accessControlFlag == true {
-			res, err := t.isCallerImporterBank(stub, []string{args[0]})
+	return UID, t.refreshContract(stub, UID)
+}
 
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
+// contractIDPrefix derives the prefix of the contract IDs of an importer bank from its name
+func contractIDPrefix(importerBankName string) string {
+	var prefix []rune
+	for _, r := range strings.ToUpper(importerBankName) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			prefix = append(prefix, r)
 		}
-		
-		return t.po.UpdatePO(stub, []string{UID, POJSON})
-
-	 } else if function == "submitED" {
-		if accessControlFlag == true {
-			res, err := t.isCallerExporterBank(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
+		if len(prefix) == 8 {
+			break
 		}
+	}
 
-		contractID := args[0]
-		BLPDF := args[1]
-		invoicePDF := args[2]
-		packingListPDF := args[3]
-		
-
+	if len(prefix) == 0 {
+		return "LC"
+	}
+	return string(prefix)
+}
 
+// allocateContractID returns the next free contract ID of the importer bank, such as IB-2012-000001.
+// Every importer bank has its own sequence per year of the transaction timestamp, so every peer allocates the same ID.
+func (t *SBI) allocateContractID(stub shim.ChaincodeStubInterface, importerBankName string) (string, error) {
+	now, err := txTimestamp(stub)
+	if err != nil {
+		return "", err
+	}
 
-		//Submit the BL to the ledger
-		if  BLPDF != "" {
-			_, err := t.bl.SubmitDoc(stub, []string{contractID, BLPDF})
-			if err != nil {
-				return nil, err
-			}
+	prefix := contractIDPrefix(importerBankName)
+	year := strconv.Itoa(now.Year())
+	for {
+		seq, err := counterStore.Next(stub, "CONTRACT", prefix, year)
+		if err != nil {
+			return "", err
 		}
 
-		//Submit the invoice to the ledger
-		if invoicePDF != "" {
-			_, err := t.invoice.SubmitDoc(stub, []string{contractID, invoicePDF})
-			if err != nil {
-				return nil, err
-			}
+		// Skip IDs already chosen by callers
+		UID := fmt.Sprintf("%s-%s-%06d", prefix, year, seq)
+		bp, err := bpStore.Get(stub, UID)
+		if err != nil {
+			return "", err
 		}
-
-		//Submit the packing list to the ledger
-		if  packingListPDF != "" {
-			_, err := t.pl.SubmitDoc(stub, []string{contractID,packingListPDF})
-			if err != nil {
-				return nil, err
-			}
+		if bp == nil {
+			return UID, nil
 		}
+	}
+}
 
-		
-		//If pay on sight is true in letter of credit, do state transition LC:ACCEPTED -> PAYMENT_RECEIVED
-		//var lc LC
-		//err = json.Unmarshal(lcJSON, &lc)
-		//if err != nil {
-		//	return nil, err
-		//}
+// updatePO replaces the PO of the contract
+func (t *SBI) updatePO(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 2 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2. Got: %d.", len(args))
+	}
 
-		//if lc.Tag42C == "Sight" {
-		//	return t.lc.UpdateStatus(stub, []string{contractID, "PAYMENT_RECEIVED"})
-		//}
+	UID := args[0]
+	POJSON, err := poFromArg(args[1])
+	if err != nil {
+		return nil, err
+	}
 
-		return nil, nil
-	} else if function == "acceptED" {
+	err = t.recordAmendment(stub, UID)
+	if err != nil {
+		return nil, err
+	}
 
-		if accessControlFlag == true {
-			res, err := t.isCallerImporterBank(stub, []string{args[0]})
+	_, err = t.po.UpdatePO(stub, []string{UID, POJSON})
+	if err != nil {
+		return nil, err
+	}
 
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
+	err = t.captureChecklist(stub, UID)
+	if err != nil {
+		return nil, err
+	}
 
+	err = t.refreshContract(stub, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, t.screenContract(stub, UID)
+}
 
-		//api change to send contract status start
-		b, err := t.bl.GetStatus(stub,[]string{args[0]})
+// recordAmendment counts an amendment of the PO of the contract. The PO as issued is kept on the first one.
+func (t *SBI) recordAmendment(stub shim.ChaincodeStubInterface, UID string) error {
+	amendments, err := amendmentStore.Get(stub, UID)
+	if err != nil {
+		return err
+	}
+	if amendments == nil {
+		issued, err := t.po.GetJSON(stub, []string{UID})
 		if err != nil {
-			return nil, err
+			return err
 		}
+		amendments = &Amendments{UID: UID, Issued: issued, Version: 1}
+	}
 
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
 
-		if string(b) == "SUBMITTED_BY_EB" {
-			args = append(args, "ACCEPTED_BY_IB")
-	     } else if  string(b) == "ACCEPTED_BY_IB" {
-			args = append(args, "PAYMENT_INITIATED")
-	     }else if string(b) == "PAYMENT_INITIATED" {
-			args = append(args, "PAYMENT_INPROGRESS")
-	     }else if string(b) == "PAYMENT_INPROGRESS" {
-			args = append(args, "PAYMENT_COMPLETED")
-	     }
+	amendments.Version++
+	amendments.AmendedAt = now
 
-	     
+	return amendmentStore.Put(stub, amendments)
+}
 
+// submitED stores the export documents given. Empty documents are skipped. The invoice may also be
+// given as UBL, alone or with its PDF rendition.
+func (t *SBI) submitED(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 4 || len(args) > 6 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 4 to 6.")
+	}
 
-		//args = append(args, "ACCEPTED_BY_IB")
+	contractID := args[0]
+	BLPDF := args[1]
+	invoicePDF := args[2]
+	packingListPDF := args[3]
 
-		_, err = t.bl.UpdateStatus(stub, args)
+	if len(args) >= 5 && args[4] != "" {
+		invoiceUBL := args[4]
+		invoice, err := parseUBLInvoice(invoiceUBL)
 		if err != nil {
 			return nil, err
 		}
-		_, err = t.invoice.UpdateStatus(stub, args)
+
+		ok, err := invoiceDetailsStore.Insert(stub, &InvoiceDetails{UID: contractID, UBL: []byte(invoiceUBL), Invoice: *invoice})
 		if err != nil {
 			return nil, err
 		}
-		_, err = t.pl.UpdateStatus(stub, args)
-		if err != nil {
-			return nil, err
+		if !ok {
+			return nil, newError(codeDuplicate, "Document already exists.")
 		}
 
-		return nil, nil
-	} else if function == "rejectED" {
-
-		if accessControlFlag == true {
-
-			res, err := t.isCallerImporterBank(stub, []string{args[0]})
-
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
+		// Without a PDF rendition the UBL is the invoice
+		if invoicePDF == "" {
+			invoicePDF = invoiceUBL
 		}
+	}
 
-		args = append(args, "REJECTED_BY_IB")
-
-		_, err := t.bl.UpdateStatus(stub, args)
+	//Submit the BL to the ledger
+	if BLPDF != "" {
+		_, err := t.bl.SubmitDoc(stub, []string{contractID, BLPDF})
 		if err != nil {
 			return nil, err
 		}
-		_, err = t.invoice.UpdateStatus(stub, args)
+	}
+
+	//Submit the invoice to the ledger
+	if invoicePDF != "" {
+		_, err := t.invoice.SubmitDoc(stub, []string{contractID, invoicePDF})
 		if err != nil {
 			return nil, err
 		}
-		_, err = t.pl.UpdateStatus(stub, args)
+	}
+
+	//Submit the packing list to the ledger
+	if packingListPDF != "" {
+		_, err := t.pl.SubmitDoc(stub, []string{contractID, packingListPDF})
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		return nil, nil
-	} 
+	// The signatures are checked against the documents as submitted
+	if len(args) == 6 && args[5] != "" {
+		err := signDocuments(stub, contractID, args[5])
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	/*else if function == "acceptToPay" {
+	err := t.refreshContract(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
 
-		if accessControlFlag == true {
-			//res, err := t.isCallerImporterBank(stub, []string{args[0], string(sigma), string(payload), string(binding)})
-			res, err := t.isCallerImporterBank(stub, []string{args[0]})
+	// The vessel is screened once the BL names it
+	return nil, t.screenContract(stub, contractID)
+}
 
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
+// updateEDStatus moves the export documents of every type following the status to newStatus
+func (t *SBI) updateEDStatus(stub shim.ChaincodeStubInterface, UID string, newStatus string) error {
+	// Optional types without a document are skipped, but some document must move
+	updated := 0
+	for _, dt := range documentTypes {
+		if !dt.FollowsStatus {
+			continue
 		}
+		ok, err := dt.updateStatus(stub, UID, newStatus)
+		if err != nil {
+			return err
+		}
+		if ok {
+			updated++
+		}
+	}
+	if updated == 0 {
+		return newError(codeNotFound, "Export documents of contract %s have not been submitted.", UID)
+	}
 
-		args = append(args, "PAYMENT_DUE_FROM_IB_TO_EB")
-
-		
-		return nil, nil
-	} */
+	// The settlement between the banks follows the payment
+	err := t.trackSettlement(stub, UID, newStatus)
+	if err != nil {
+		return err
+	}
 
-	return nil, errors.New("Invalid invoke function name.")
+	return t.refreshContract(stub, UID)
 }
 
-// Query callback representing the query of a chaincode
-func (t *SBI) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	type Status struct {
-		Status string
+// acceptED accepts the export documents, then moves the payment forward one step at each call
+func (t *SBI) acceptED(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
 	}
-	status := Status{}
 
-	/*type Result struct {
-		Result string `json:"result"`
+	edStatus, err := t.currentEDStatus(stub, args[0])
+	if err != nil {
+		return nil, err
 	}
-	result := Result{}
-	*/
-	/*
-		sigma, err := stub.GetCallerMetadata()
+
+	var newStatus string
+	if edStatus == "" {
+		return nil, newError(codeNotFound, "Export documents of contract %s have not been submitted.", args[0])
+	} else if edStatus == "SUBMITTED_BY_EB" {
+		// Every document required by the LC must be presented first
+		err = t.checkPresentationComplete(stub, args[0])
 		if err != nil {
-			return nil, errors.New("Failed getting metadata")
+			return nil, err
 		}
-		payload, err := stub.GetPayload()
+		newStatus = "ACCEPTED_BY_IB"
+	} else if edStatus == "ACCEPTED_BY_IB" {
+		newStatus = "PAYMENT_INITIATED"
+	} else if edStatus == "PAYMENT_INITIATED" {
+		newStatus = "PAYMENT_INPROGRESS"
+	} else if edStatus == "PAYMENT_INPROGRESS" {
+		newStatus = "PAYMENT_COMPLETED"
+	} else {
+		return nil, newError(codeInvalidTransition, "This state transition is not allowed.")
+	}
+
+	return nil, t.updateEDStatus(stub, args[0], newStatus)
+}
+
+// rejectED rejects the export documents
+func (t *SBI) rejectED(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 1.")
+	}
+
+	return nil, t.updateEDStatus(stub, args[0], "REJECTED_BY_IB")
+}
+
+// getED returns an export document of the contract
+func (t *SBI) getED(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(codeInvalidArgs, "Incorrect number of arguments. Expecting 2 or 3.")
+	}
+
+	contractID := args[0]
+	docType := args[1]
+
+	format := formatPDF
+	if len(args) == 3 && args[2] != "" {
+		format = args[2]
+	}
+	if format == formatUBL && docType == "INVOICE" {
+		details, err := invoiceDetailsStore.Get(stub, contractID)
 		if err != nil {
-			return nil, errors.New("Failed getting payload")
+			return nil, err
 		}
-		binding, err := stub.GetBinding()
-		if err != nil {
-			return nil, errors.New("Failed getting binding")
+		if details == nil {
+			return nil, newError(codeNotFound, "Invoice of contract %s has not been submitted as UBL.", contractID)
 		}
-	*/
+		return details.UBL, nil
+	}
+	if format != formatPDF {
+		return nil, newError(codeInvalidArgs, "Format should be PDF, or UBL for the invoice.")
+	}
 
-	if   function == "getED" {
-		if accessControlFlag == true {
-			res, err := t.isCallerParticipant(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
+	dt, ok := lookupDocumentType(docType)
+	if !ok {
+		return nil, documentTypeError(docType)
+	}
 
-		if len(args) != 2 {
-			return nil, errors.New("Incorrect number of arguments. Expecting 2.")
-		}
+	doc, err := dt.Store.Get(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, newError(codeNotFound, "%s of contract %s has not been submitted.", docType, contractID)
+	}
 
-		contractID := args[0]
-		docType := args[1]
-		
+	return doc.Content, nil
+}
 
-		if docType != "BL" && docType != "INVOICE" && docType != "PACKINGLIST" {
-			return nil, errors.New("Document type should be BL or INVOICE or PACKINGLIST")
-		}
+// getPO returns the PO of the contract
+func (t *SBI) getPO(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	b, err := t.po.GetJSON(stub, args)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, newError(codeNotFound, "PO of contract %s has not been submitted.", args[0])
+	}
 
-		
-		
-		
-		if docType == "BL" {
-				return t.bl.GetPDF(stub, []string{contractID})
-			} else if docType == "INVOICE" {
-				return t.invoice.GetPDF(stub, []string{contractID})
-			} else if docType == "PACKINGLIST" {
-				return t.pl.GetPDF(stub, []string{contractID})
-			}
+	return b, nil
+}
 
-		
+// getEDStatus returns the status of the export documents of the contract
+func (t *SBI) getEDStatus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//since all export documents are always kept in the same state, it is enough to check against one.
+	b, err := t.bl.GetStatus(stub, args)
+	if err != nil {
+		return nil, err
+	}
 
-		return nil, nil
-	}else if function == "getPO" {
+	return json.Marshal(DocumentStatus{Status: string(b)})
+}
 
-		if accessControlFlag == true {
-			res, err := t.isCallerParticipant(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
+// Invoke invokes the chaincode. The arguments are either positional or a single JSON object
+// with named fields. Errors are returned as a ChaincodeError.
+func (t *SBI) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	b, err := t.dispatch(stub, kindInvoke, function, args)
+	return b, toChaincodeError(err)
+}
 
-		return t.po.GetJSON(stub, args)
-	
-	/*else if function == "getPOStatus" {
+// Query callback representing the query of a chaincode. The arguments are either positional
+// or a single JSON object with named fields. Errors are returned as a ChaincodeError.
+func (t *SBI) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	b, err := t.dispatch(stub, kindQuery, function, args)
+	return b, toChaincodeError(err)
+}
 
-		if accessControlFlag == true {
-			res, err := t.isCallerParticipant(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
+// dispatch validates the arguments of a registered function, checks its contract and access policy,
+// then calls its handler. Invokes already processed under the same request ID are not applied again.
+func (t *SBI) dispatch(stub shim.ChaincodeStubInterface, kind string, function string, args []string) ([]byte, error) {
+	fn, ok := lookupAPI(kind, function)
+	if !ok {
+		return nil, newError(codeInvalidArgs, "Invalid %s function name.", kind)
+	}
+
+	request := args
+	args, err := positionalArgs(function, fn.requestSchema(), args)
+	if err != nil {
+		return nil, err
+	}
+	requestID, args := splitRequestID(fn, request, args)
+	err = fn.Args.checkArity(args)
+	if err != nil {
+		return nil, err
+	}
 
-		b, err := t.po.GetStatus(stub, args)
+	if fn.Contract != contractNone {
+		_, err = t.getContract(stub, args[0])
 		if err != nil {
 			return nil, err
 		}
-		status.Status = string(b)
-		return json.Marshal(status)
-	}else if function == "validatePO" {
+	}
 
-		b, err := t.po.ValidateDoc(stub, args)
+	if accessControlFlag == true && fn.Policy.check != nil {
+		res, err := fn.Policy.check(t, stub, args)
 		if err != nil {
 			return nil, err
 		}
-		result.Result = string(b)
-		return json.Marshal(result)
-	} 
+		if res == false {
+			return nil, newError(codeAccessDenied, "Access denied.")
+		}
+	}
 
-	*/ }else if function == "getEDStatus" {
-		if accessControlFlag == true {
-			res, err := t.isCallerParticipant(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
+	if requestID != "" {
+		result, ok, err := replayRequest(stub, requestContract(fn, args), requestID, function, args)
+		if err != nil || ok {
+			return result, err
 		}
+	}
 
-		b, err := t.bl.GetStatus(stub, args)
+	if fn.Contract == contractOpen {
+		// Cancelled and closed contracts do not accept any further invoke
+		err = t.checkContractOpen(stub, args[0])
 		if err != nil {
 			return nil, err
 		}
-		status.Status = string(b)
-		return json.Marshal(status)
-	} else if function == "getNumContracts" {
-
-		return t.getNumContracts(stub, args)
-	} else if function == "listContracts" {
-
-		return t.listContracts(stub, args)
-	} else if function == "listContractsByRole" {
-
-		return t.listContractsByRole(stub, args)
-	}  else if function == "listEDsByStatus" {
-
-		return t.listEDsByStatus(stub, args)
-	} else if function == "getContractParticipants" {
-		if accessControlFlag == true {
-			res, err := t.isCallerParticipant(stub, []string{args[0]})
-			if err != nil {
-				return nil, err
-			}
-			if res == false {
-				return nil, errors.New("Access denied.")
-			}
-		}
+	}
 
-		return t.getContractParticipants(stub, args)
+	result, err := fn.Handler(t, stub, args)
+	if err != nil || requestID == "" {
+		return result, err
 	}
 
-	return nil, errors.New("Invalid query function name.")
+	return result, recordRequest(stub, requestContract(fn, args), requestID, function, args, result)
 }
 
 func main() {
-	primitives.SetSecurityLevel("SHA3", 256)
 	err := shim.Start(new(SBI))
 	if err != nil {
 		fmt.Printf("Error starting TF: %s", err)
@@ -17,329 +17,15 @@ limitations under the License.
 package main
 
 import (
-	//"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"net"
+	"strings"
 	"testing"
 	"time"
 
-	"os"
-	"path/filepath"
-
-	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
-	//"github.com/hyperledger/fabric/core/chaincode/shim/crypto/attr"
-	"github.com/hyperledger/fabric/core/container"
-	"github.com/hyperledger/fabric/core/crypto"
-	"github.com/hyperledger/fabric/core/db"
-	"github.com/hyperledger/fabric/core/ledger"
-	"github.com/hyperledger/fabric/core/util"
-	"github.com/hyperledger/fabric/membersrvc/ca"
-	pb "github.com/hyperledger/fabric/protos"
-	"github.com/op/go-logging"
-	"github.com/spf13/viper"
-	"golang.org/x/net/context"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/grpclog"
-)
-
-const (
-	chaincodeStartupTimeoutDefault int = 5000
-)
-
-var (
-	testLogger = logging.MustGetLogger("test")
-
-	lis net.Listener
-
-	administrator crypto.Client
-	alice         crypto.Client
-	bob           crypto.Client
-
-	server *grpc.Server
-	aca    *ca.ACA
-	eca    *ca.ECA
-	tca    *ca.TCA
-	tlsca  *ca.TLSCA
 )
 
-func TestMain(m *testing.M) {
-	removeFolders()
-	setup()
-	go initMembershipSrvc()
-
-	fmt.Println("Wait for some secs for OBCCA")
-	time.Sleep(2 * time.Second)
-
-	go initVP()
-
-	fmt.Println("Wait for some secs for VP")
-	time.Sleep(2 * time.Second)
-
-	go initSbiTrade()
-
-	fmt.Println("Wait for some secs for Chaincode")
-	time.Sleep(2 * time.Second)
-
-	if err := initClients(); err != nil {
-		panic(err)
-	}
-
-	fmt.Println("Wait for 5 secs for chaincode to be started")
-	time.Sleep(5 * time.Second)
-
-	ret := m.Run()
-
-	closeListenerAndSleep(lis)
-
-	defer removeFolders()
-	os.Exit(ret)
-}
-
-
-func deploy(admCert crypto.CertificateHandler) error {
-	// Prepare the spec. The metadata includes the role of the users allowed to assign assets
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              &pb.ChaincodeInput{Args: util.ToChaincodeArgs("init")},
-		Metadata:             []byte("issuer"),
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-	}
-
-	// First build and get the deployment spec
-	var ctx = context.Background()
-	chaincodeDeploymentSpec, err := getDeploymentSpec(ctx, spec)
-	if err != nil {
-		return err
-	}
-
-	tid := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
-
-	// Now create the Transactions message and send to Peer.
-	transaction, err := administrator.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, tid)
-	if err != nil {
-		return fmt.Errorf("Error deploying chaincode: %s ", err)
-	}
-
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	_, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return fmt.Errorf("Error deploying chaincode: %s", err)
-	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
-
-	return err
-}
-
-
-func setup() {
-	// Conf
-	viper.SetConfigName("sbi") // name of config file (without extension)
-	viper.AddConfigPath(".")     // path to look for the config file in
-	err := viper.ReadInConfig()  // Find and read the config file
-	if err != nil {              // Handle errors reading the config file
-		panic(fmt.Errorf("Fatal error config file [%s] \n", err))
-	}
-
-	// Logging
-	var formatter = logging.MustStringFormatter(
-		`%{color}[%{module}] %{shortfunc} [%{shortfile}] -> %{level:.4s} %{id:03x}%{color:reset} %{message}`,
-	)
-	logging.SetFormatter(formatter)
-
-	logging.SetLevel(logging.DEBUG, "peer")
-	logging.SetLevel(logging.DEBUG, "chaincode")
-	logging.SetLevel(logging.DEBUG, "cryptochain")
-
-	// Init the crypto layer
-	if err := crypto.Init(); err != nil {
-		panic(fmt.Errorf("Failed initializing the crypto layer [%s]", err))
-	}
-
-	removeFolders()
-
-	// Start db
-	db.Start()
-}
-
-func initMembershipSrvc() {
-	// ca.LogInit seems to have been removed
-	//ca.LogInit(ioutil.Discard, os.Stdout, os.Stdout, os.Stderr, os.Stdout)
-	ca.CacheConfiguration() // Cache configuration
-	aca = ca.NewACA()
-	eca = ca.NewECA(aca)
-	tca = ca.NewTCA(eca)
-	tlsca = ca.NewTLSCA(eca)
-
-	var opts []grpc.ServerOption
-	if viper.GetBool("peer.pki.tls.enabled") {
-		// TLS configuration
-		creds, err := credentials.NewServerTLSFromFile(
-			filepath.Join(viper.GetString("server.rootpath"), "tlsca.cert"),
-			filepath.Join(viper.GetString("server.rootpath"), "tlsca.priv"),
-		)
-		if err != nil {
-			panic("Failed creating credentials for Membersrvc: " + err.Error())
-		}
-		opts = []grpc.ServerOption{grpc.Creds(creds)}
-	}
-
-	fmt.Printf("open socket...\n")
-	sockp, err := net.Listen("tcp", viper.GetString("server.port"))
-	if err != nil {
-		panic("Cannot open port: " + err.Error())
-	}
-	fmt.Printf("open socket...done\n")
-
-	server = grpc.NewServer(opts...)
-
-	aca.Start(server)
-	eca.Start(server)
-	tca.Start(server)
-	tlsca.Start(server)
-
-	fmt.Printf("start serving...\n")
-	server.Serve(sockp)
-}
-
-func initVP() {
-	var opts []grpc.ServerOption
-	if viper.GetBool("peer.tls.enabled") {
-		creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
-		if err != nil {
-			grpclog.Fatalf("Failed to generate credentials %v", err)
-		}
-		opts = []grpc.ServerOption{grpc.Creds(creds)}
-	}
-	grpcServer := grpc.NewServer(opts...)
-
-	//lis, err := net.Listen("tcp", viper.GetString("peer.address"))
-
-	//use a different address than what we usually use for "peer"
-	//we override the peerAddress set in chaincode_support.go
-	peerAddress := "0.0.0.0:40404"
-	var err error
-	lis, err = net.Listen("tcp", peerAddress)
-	if err != nil {
-		return
-	}
-
-	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
-		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: "testpeer"}, Address: peerAddress}, nil
-	}
-
-	ccStartupTimeout := time.Duration(chaincodeStartupTimeoutDefault) * time.Millisecond
-	userRunsCC := true
-
-	// Install security object for peer
-	var secHelper crypto.Peer
-	if viper.GetBool("security.enabled") {
-		enrollID := viper.GetString("security.enrollID")
-		enrollSecret := viper.GetString("security.enrollSecret")
-		var err error
-
-		if viper.GetBool("peer.validator.enabled") {
-			testLogger.Debugf("Registering validator with enroll ID: %s", enrollID)
-			if err = crypto.RegisterValidator(enrollID, nil, enrollID, enrollSecret); nil != err {
-				panic(err)
-			}
-			testLogger.Debugf("Initializing validator with enroll ID: %s", enrollID)
-			secHelper, err = crypto.InitValidator(enrollID, nil)
-			if nil != err {
-				panic(err)
-			}
-		} else {
-			testLogger.Debugf("Registering non-validator with enroll ID: %s", enrollID)
-			if err = crypto.RegisterPeer(enrollID, nil, enrollID, enrollSecret); nil != err {
-				panic(err)
-			}
-			testLogger.Debugf("Initializing non-validator with enroll ID: %s", enrollID)
-			secHelper, err = crypto.InitPeer(enrollID, nil)
-			if nil != err {
-				panic(err)
-			}
-		}
-	}
-
-	pb.RegisterChaincodeSupportServer(grpcServer,
-		chaincode.NewChaincodeSupport(chaincode.DefaultChain, getPeerEndpoint, userRunsCC,
-			ccStartupTimeout, secHelper))
-
-	grpcServer.Serve(lis)
-}
-
-func initSbiTrade() {
-	err := shim.Start(new(SBI))
-	if err != nil {
-		panic(err)
-	}
-}
-
-func initClients() error {
-	// Administrator
-	if err := crypto.RegisterClient("admin", nil, "admin", "6avZQLwcUe9b"); err != nil {
-		return err
-	}
-	var err error
-	administrator, err = crypto.InitClient("admin", nil)
-	if err != nil {
-		return err
-	}
-
-	// Alice
-	if err := crypto.RegisterClient("alice", nil, "alice", "NPKYL39uKbkj"); err != nil {
-		return err
-	}
-	alice, err = crypto.InitClient("alice", nil)
-	if err != nil {
-		return err
-	}
-
-	// Bob
-	if err := crypto.RegisterClient("bob", nil, "bob", "DRJ23pEQl16a"); err != nil {
-		return err
-	}
-	bob, err = crypto.InitClient("bob", nil)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func closeListenerAndSleep(l net.Listener) {
-	l.Close()
-	time.Sleep(2 * time.Second)
-}
-
-func getDeploymentSpec(context context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
-	fmt.Printf("getting deployment spec for chaincode spec: %v\n", spec)
-	var codePackageBytes []byte
-	//if we have a name, we don't need to deploy (we are in userRunsCC mode)
-	if spec.ChaincodeID.Name == "" {
-		var err error
-		codePackageBytes, err = container.GetChaincodePackageBytes(spec)
-		if err != nil {
-			return nil, err
-		}
-	}
-	chaincodeDeploymentSpec := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: codePackageBytes}
-	return chaincodeDeploymentSpec, nil
-}
-
-func removeFolders() {
-	fmt.Println("-------------------------")
-	if err := os.RemoveAll(viper.GetString("peer.fileSystemPath")); err != nil {
-		fmt.Printf("Failed removing [%s] [%s]\n", "hyperledger", err)
-	}
-}
-
-
 func TestCC(t *testing.T) {
 
 	poJSON := []byte(`{
@@ -526,15 +212,9 @@ func TestCC(t *testing.T) {
         contractsList := ContractsList{}
 
 
-        // Administrator deploy the chaicode
-        adminCert, err := administrator.GetTCertificateHandlerNext("role")
-        if err != nil {
-                t.Fatal(err)
-        }
-
-        if err := deploy(adminCert); err != nil {
-                t.Fatal(err)
-        }
+        stub := newMockStub()
+        adminCert := []byte(`AdminCert`)
+        var err error
 
        /*b, err := validatePO(poJSON)
         err = json.Unmarshal(b, &result)
@@ -548,7 +228,7 @@ func TestCC(t *testing.T) {
 
 
      //This must succeed
-		if err = initTrade(adminCert, "1000",poJSON,"I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err!=nil{
+		if err = initTrade(stub, adminCert, "1000",poJSON,"I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err!=nil{
         //panic(err)
 			t.Fatal(err)
 		}
@@ -560,7 +240,7 @@ func TestCC(t *testing.T) {
         }
 
         // This must succeed
-        if err = acceptPO(adminCert, "1000"); err != nil {
+        if err = acceptPO(stub, adminCert, "1000"); err != nil {
                 t.Fatal(err)
         }
 
@@ -573,43 +253,43 @@ func TestCC(t *testing.T) {
 		*/
 		
         // This must succeed
-        if err = submitED(adminCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+        if err = submitED(stub, adminCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
                 t.Fatal(err)
         } 
 
       //This must succeed
-		b, err := getEDStatus("1000"); 
+		b, err := getEDStatus(stub, "1000"); 
         err = json.Unmarshal(b, &status)
         if err !=nil || status.Status != "SUBMITTED_BY_EB" {
         	t.Fatal(err)
         }
      
        // This must succeed
-        if err = acceptED(adminCert, "1000"); err != nil {
+        if err = acceptED(stub, adminCert, "1000"); err != nil {
                 t.Fatal(err)
         }
 
         //This must succeed
-	    b, err = getEDStatus("1000"); 
+	    b, err = getEDStatus(stub, "1000"); 
 	    err = json.Unmarshal(b, &status)
 
 	    if err !=nil || status.Status != "ACCEPTED_BY_IB"{
         	t.Fatal(err)
         }
 	
-		 if err = acceptED(adminCert, "1000"); err != nil {
+		 if err = acceptED(stub, adminCert, "1000"); err != nil {
                 t.Fatal(err)
         }
 
         //This must succeed
-	    b, err = getEDStatus("1000"); 
+	    b, err = getEDStatus(stub, "1000"); 
 	    err = json.Unmarshal(b, &status)
 
 	    if err !=nil || status.Status != "PAYMENT_INITIATED"{
         	t.Fatal(err)
         }
         
-       if err = updatePO(adminCert, "1000",poNewJSON); err != nil {
+       if err = updatePO(stub, adminCert, "1000",poNewJSON); err != nil {
                 t.Fatal(err)
         }
         
@@ -623,32 +303,32 @@ func TestCC(t *testing.T) {
 	
 
         //This must succeed
-		if err = initTrade(adminCert, "1001",poJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err!=nil{
+		if err = initTrade(stub, adminCert, "1001",poJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err!=nil{
         t.Fatal(err)
 		}
 
 		
 
 	  // This must succeed
-        if err = submitED(adminCert, "1001", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+        if err = submitED(stub, adminCert, "1001", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
                 t.Fatal(err)
         }
        
        //This must succeed
-        b, err = getEDStatus("1001"); 
+        b, err = getEDStatus(stub, "1001"); 
         err = json.Unmarshal(b, &status)
         if err != nil || status.Status != "SUBMITTED_BY_EB" {
         	t.Fatal(err)
         }
         
         // This must succeed
-        if err = rejectED(adminCert, "1001"); err != nil {
+        if err = rejectED(stub, adminCert, "1001"); err != nil {
                 t.Fatal(err)
         }
 
         
 	       //This must succeed
-	    b, err =getEDStatus("1001"); 
+	    b, err =getEDStatus(stub, "1001"); 
 	    err = json.Unmarshal(b, &status)
 
 	    if err !=nil || status.Status != "REJECTED_BY_IB"{
@@ -668,7 +348,7 @@ func TestCC(t *testing.T) {
 	// This must succeed
 
         
-	b, err = getNumContracts()
+	b, err = getNumContracts(stub)
 	err = json.Unmarshal(b, &count)
 	if err != nil {
 		t.Fatal(err)
@@ -677,41 +357,49 @@ func TestCC(t *testing.T) {
 	
 
 	//getContractParticipants
-	contractParticipants, err := getContractParticipants("1000")
+	contractParticipants, err := getContractParticipants(stub, "1000")
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	//getContractSummary
+	var summary ContractSummary
+	cs, err := getContractSummary(stub, "1000")
+	err = json.Unmarshal(cs, &summary)
+	if err != nil || summary.LCNumber != "L960477" || summary.Amount != "USD40000" || summary.PaymentStatus != "PAYMENT_INITIATED" || summary.ContractStatus != "PAYMENT_PENDING" {
+		t.Fatal(err)
+	}
+
 	//listContracts
-	cl, err := listContracts()
+	cl, err := listContracts(stub)
 	err = json.Unmarshal(cl, &contractsList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	//listContractsByRole
-	clri, err := listContractsByRole("Importer")
+	clri, err := listContractsByRole(stub, "Importer")
 	err = json.Unmarshal(clri, &contractsList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	//listContractsByRole
-	clre, err := listContractsByRole("Exporter")
+	clre, err := listContractsByRole(stub, "Exporter")
 	err = json.Unmarshal(clre, &contractsList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	//listContractsByRole
-	clrib, err := listContractsByRole("ImporterBank")
+	clrib, err := listContractsByRole(stub, "ImporterBank")
 	err = json.Unmarshal(clrib, &contractsList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	//listContractsByRole
-	clreb, err := listContractsByRole("ExporterBank")
+	clreb, err := listContractsByRole(stub, "ExporterBank")
 	err = json.Unmarshal(clreb, &contractsList)
 	if err != nil {
 		t.Fatal(err)
@@ -720,22 +408,29 @@ func TestCC(t *testing.T) {
 	
 
 	
+	//listContractsByStatus
+	lcr, err := listContractsByStatus(stub, "REJECTED")
+	err = json.Unmarshal(lcr, &contractsList)
+	if err != nil || len(contractsList.Contracts) != 1 || contractsList.Contracts[0].ContractID != "1001" {
+		t.Fatal(err)
+	}
+
 	//listEDsByStatus
-	eda, err := listEDsByStatus("ACCEPTED_BY_IB")
+	eda, err := listEDsByStatus(stub, "ACCEPTED_BY_IB")
 	err = json.Unmarshal(eda, &contractsList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	//listEDsByStatus
-	edr, err := listEDsByStatus("REJECTED_BY_IB")
+	edr, err := listEDsByStatus(stub, "REJECTED_BY_IB")
 	err = json.Unmarshal(edr, &contractsList)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	//listEDsByStatus
-	eds, err := listEDsByStatus("SUBMITTED_BY_EB")
+	eds, err := listEDsByStatus(stub, "SUBMITTED_BY_EB")
 	err = json.Unmarshal(eds, &contractsList)
 	if err != nil {
 		t.Fatal(err)
@@ -745,6 +440,7 @@ func TestCC(t *testing.T) {
 
 	fmt.Println("NumContracts = ", count.NumContracts)
 	fmt.Println("Contract Participants = ", string(contractParticipants))
+	fmt.Println("Contract Summary = ", string(cs))
 	fmt.Println("List of contracts = ", string(cl))
 	fmt.Println("List of contracts by role (exporter) = ", string(clre))
 	fmt.Println("List of contracts by role (importer) = ", string(clri))
@@ -752,887 +448,826 @@ func TestCC(t *testing.T) {
 	fmt.Println("List of contracts by role (exporter bank) = ", string(clreb))
 	//fmt.Println("List of LCs by status (PAYMENT_RECEIVED) = ", string(llcr))
 	//fmt.Println("List of LCs by status (PAYMENT_DEFAULTED) = ", string(llcd))
+	fmt.Println("List of contracts by status (REJECTED) = ", string(lcr))
 	fmt.Println("List of EDs by status (ACCEPTED_BY_IB) = ", string(eda))
 	fmt.Println("List of EDs by status (REJECTED_BY_IB) = ", string(edr))
 	fmt.Println("List of EDs by status (SUBMITTED_BY_EB) = ", string(eds))
 	//fmt.Println("List of EDs by status (PAYMENT_DUE_FROM_IB_TO_EB) = ", string(edp))
 
 	/* WORKFLOW Last: End */
-}
-
-//initTrade
 
-func initTrade(admCert crypto.CertificateHandler, contractID string, POJSON []byte, importerName string, exporterName string, importerBankName string, exporterBankName string, importerCert []byte, exporterCert []byte, importerBankCert []byte, exporterBankCert []byte) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
+	/* WORKFLOW 3: Start */
 
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
+	// cancel an irrevocable credit, close a settled one
 
-        //chaincodeInput := &pb.ChaincodeInput{Function: "submitLC", Args: []string{contractID, string(LCJSON), importerName, exporterName, importerBankName, exporterBankName, string(importerCert), string(exporterCert), string(importerBankCert), string(exporterBankCert)}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("initTrade"), []byte(contractID),POJSON,[]byte(importerName), []byte(exporterName), []byte(importerBankName), []byte(exporterBankName), importerCert, exporterCert, importerBankCert, exporterBankCert}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
-
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
+	//This must succeed
+	if err = initTrade(stub, adminCert, "1002", poJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
 
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	// The importer bank alone can not cancel an irrevocable credit
+	if err = cancelTrade(stub, adminCert, "1002", "ImporterBank"); err != nil {
+		t.Fatal(err)
+	}
+	if err = cancelTrade(stub, adminCert, "1002", "Exporter"); err != nil {
+		t.Fatal(err)
+	}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	// This must fail
+	if err = submitED(stub, adminCert, "1002", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err == nil {
+		t.Fatal("submitED must fail on a cancelled contract")
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	//This must succeed
+	if err = initTrade(stub, adminCert, "1003", poJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if err = submitED(stub, adminCert, "1003", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err = acceptED(stub, adminCert, "1003"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = closeTrade(stub, adminCert, "1003"); err != nil {
+		t.Fatal(err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// Closed and cancelled contracts are only listed on request
+	cl, err = listContracts(stub)
+	contractsList = ContractsList{}
+	err = json.Unmarshal(cl, &contractsList)
+	if err != nil || len(contractsList.Contracts) != 2 {
+		t.Fatal(err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	cl, err = listAllContracts(stub)
+	contractsList = ContractsList{}
+	err = json.Unmarshal(cl, &contractsList)
+	if err != nil || len(contractsList.Contracts) != 4 {
+		t.Fatal(err)
+	}
 
-        return err
+	/* WORKFLOW 3: End */
 }
 
+// testPOJSON carries the PO fields the business process reads
+var testPOJSON = []byte(`{"Tag20":"L960477","Tag31D":"120831-USA","Tag32B":"USD10000","Tag40A":"IRREVOCABLE"}`)
 
-//submitED
-func submitED(admCert crypto.CertificateHandler, contractID string, BLPDF []byte, invoicePDF []byte, packingListPDF []byte) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
-
-        //chaincodeInput := &pb.ChaincodeInput{Function: "submitED", Args: []string{contractID, string(BLPDF), string(invoicePDF), string(packingListPDF), string(BLJSON), string(invoiceJSON), string(packingListJSON)}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("submitED"), []byte(contractID), BLPDF, invoicePDF, packingListPDF}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
+func TestAccessControl(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
 
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	stub := newMockStub()
+	importerCert, exporterCert := []byte(`ICert`), []byte(`ECert`)
+	importerBankCert, exporterBankCert := []byte(`IBCert`), []byte(`EBCert`)
+	outsiderCert := []byte(`OCert`)
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
-
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
-
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
-
-        return err
-}
-
-//getEDStatus
-func getEDStatus(contractID string) ([]byte, error) {
-        //chaincodeInput := &pb.ChaincodeInput{Function: "getEDStatus", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("getEDStatus"), []byte(contractID)}}
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	if err := initTrade(stub, importerBankCert, "1000", testPOJSON, "I", "E", "IB", "EB", importerCert, exporterCert, importerBankCert, exporterBankCert); err != nil {
+		t.Fatal(err)
+	}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	// Only the exporter bank submits the export documents
+	if err := submitED(stub, importerBankCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); errorCode(err) != codeAccessDenied {
+		t.Fatalf("submitED by the importer bank: %v", err)
+	}
+	if err := submitED(stub, exporterBankCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	// Only the importer bank examines them
+	if err := acceptED(stub, exporterBankCert, "1000"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("acceptED by the exporter bank: %v", err)
+	}
+	if err := rejectED(stub, exporterCert, "1000"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("rejectED by the exporter: %v", err)
+	}
+	if err := acceptED(stub, importerBankCert, "1000"); err != nil {
+		t.Fatal(err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// Participants only see their own contracts
+	if _, err := stub.query(outsiderCert, "getEDStatus", "1000"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("getEDStatus by an outsider: %v", err)
+	}
+	if _, err := stub.query(importerCert, "getEDStatus", "1000"); err != nil {
+		t.Fatal(err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	var contractsList ContractsList
+	b, err := stub.query(outsiderCert, "listContracts")
+	if err = json.Unmarshal(b, &contractsList); err != nil || len(contractsList.Contracts) != 0 {
+		t.Fatalf("listContracts by an outsider: %s %v", b, err)
+	}
+	b, err = stub.query(exporterCert, "listContractsByRole", "Exporter")
+	if err = json.Unmarshal(b, &contractsList); err != nil || len(contractsList.Contracts) != 1 {
+		t.Fatalf("listContractsByRole by the exporter: %s %v", b, err)
+	}
 
-        return result, err
+	// An unsigned transaction is nobody
+	if err := closeTrade(stub, nil, "1000"); err == nil {
+		t.Fatal("closeTrade without a signature must fail")
+	}
 }
 
-//getED
-func getED(contractID string, docType string, docFormat string) ([]byte, error) {
-        //chaincodeInput := &pb.ChaincodeInput{Function: "getED", Args: []string{contractID, docType, docFormat}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("getED"), []byte(contractID), []byte(docType), []byte(docFormat)}}
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
-
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+func TestInvokeRollback(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
-
-        // Now create the Transactions message and send to Peer.
-        transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	if err := initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := submitED(stub, adminCert, "1000", []byte(``), []byte(``), []byte(`PLPDF`)); err != nil {
+		t.Fatal(err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	// The packing list already exists, so the BL and the invoice written before must be discarded
+	if err := submitED(stub, adminCert, "1000", []byte(`BLPDF`), []byte(`INPDF`), []byte(`PLPDF`)); err == nil {
+		t.Fatal("submitED must fail on an existing packing list")
+	}
 
-        return result, err
+	b, err := stub.query(nil, "getED", "1000", "BL")
+	if errorCode(err) != codeNotFound {
+		t.Fatalf("BL of a failed transaction was kept: %s %v", b, err)
+	}
 }
 
-func updatePO(admCert crypto.CertificateHandler, contractID string, POJSON []byte) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
+func TestErrorCodes(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
 
-        //chaincodeInput := &pb.ChaincodeInput{Function: "submitED", Args: []string{contractID, string(BLPDF), string(invoicePDF), string(packingListPDF), string(BLJSON), string(invoiceJSON), string(packingListJSON)}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("updatePO"), []byte(contractID), POJSON}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
-
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	if err := initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)), codeDuplicate},
+		{acceptED(stub, adminCert, "9999"), codeNotFound},
+		{cancelTrade(stub, adminCert, "1000", "Importer"), codeInvalidArgs},
+		{closeTrade(stub, adminCert, "1000"), codeInvalidTransition},
+	}
+	for i, test := range tests {
+		if errorCode(test.err) != test.code {
+			t.Errorf("case %d: expected %s, got %v", i, test.code, test.err)
+		}
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	for _, function := range []string{"getPO", "getEDStatus", "getContractParticipants", "getContractSummary"} {
+		if _, err := stub.query(nil, function, "9999"); errorCode(err) != codeNotFound {
+			t.Errorf("%s on an unknown contract: %v", function, err)
+		}
+	}
+	if _, err := stub.query(nil, "getED", "9999", "BL"); errorCode(err) != codeNotFound {
+		t.Errorf("getED on an unknown contract: %v", err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	_, err := stub.query(nil, "getED", "1000", "BL")
+	if errorCode(err) != codeNotFound {
+		t.Errorf("getED before submission: %v", err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	// Export documents that were never submitted can not be examined
+	for _, function := range []string{"acceptED", "rejectED"} {
+		if _, err = stub.invoke(adminCert, function, `{"requestID": "req-`+function+`", "contractID": "1000"}`); errorCode(err) != codeNotFound {
+			t.Errorf("%s before submission: %v", function, err)
+		}
+		if record, err := requestStore.Get(stub, "1000", "req-"+function); err != nil || record != nil {
+			t.Errorf("%s before submission recorded: %v %v", function, record, err)
+		}
+	}
+	if _, err = stub.query(nil, "getEDStatus", "1000"); errorCode(err) != codeNotFound {
+		t.Errorf("getEDStatus before submission: %v", err)
+	}
+	for _, get := range []func(shim.ChaincodeStubInterface, []string) ([]byte, error){
+		new(BL).GetStatus, new(BL).GetPDF, new(Invoice).GetStatus, new(Invoice).GetPDF, new(PL).GetStatus, new(PL).GetPDF,
+	} {
+		if _, err = get(stub, []string{"1000"}); errorCode(err) != codeNotFound {
+			t.Errorf("document accessor before submission: %v", err)
+		}
+	}
+	for _, update := range []func(shim.ChaincodeStubInterface, []string) ([]byte, error){new(BL).UpdateStatus, new(Invoice).UpdateStatus, new(PL).UpdateStatus} {
+		if _, err = update(stub, []string{"1000", "ACCEPTED_BY_IB"}); errorCode(err) != codeNotFound {
+			t.Errorf("status update before submission: %v", err)
+		}
+	}
 
-        return err
+	// Errors reach the client as JSON
+	_, err = stub.invoke(adminCert, "noSuchFunction")
+	var e ChaincodeError
+	if jerr := json.Unmarshal([]byte(err.Error()), &e); jerr != nil || e.Code != codeInvalidArgs || e.Message != "Invalid invoke function name." {
+		t.Errorf("error is not serialised as JSON: %v", err)
+	}
 }
 
-/*func paymentReceived(admCert crypto.CertificateHandler, contractID string) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
-
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
-
-        //chaincodeInput := &pb.ChaincodeInput{Function: "paymentReceived", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("paymentReceived"), []byte(contractID)}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
-
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
-
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
-
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
-
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
-
-        return err
-}*/
-
-
-/*
-//acceptToPay
-func acceptToPay(admCert crypto.CertificateHandler, contractID string) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
-
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
-
-        //chaincodeInput := &pb.ChaincodeInput{Function: "acceptToPay", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("acceptToPay"), []byte(contractID)}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
-
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
-
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+func TestExpiryInLists(t *testing.T) {
+	stub := newMockStub()
+	if err := initTrade(stub, nil, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// The credit lapses after 31 August 2012 without any transaction on the contract
+	stub.Now = time.Date(2012, time.September, 2, 10, 0, 0, 0, time.UTC)
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	var list ContractsList
+	b, err := listContracts(stub)
+	if err = json.Unmarshal(b, &list); err != nil || len(list.Contracts) != 1 || list.Contracts[0].ContractStatus != statusExpired {
+		t.Fatalf("listContracts: %s %v", b, err)
+	}
+	b, err = listContractsByStatus(stub, statusExpired)
+	if err = json.Unmarshal(b, &list); err != nil || len(list.Contracts) != 1 {
+		t.Fatalf("listContractsByStatus: %s %v", b, err)
+	}
+	b, err = listContractsByStatus(stub, statusDocsPending)
+	if err = json.Unmarshal(b, &list); err != nil || len(list.Contracts) != 0 {
+		t.Fatalf("listContractsByStatus: %s %v", b, err)
+	}
 
-        return err
+	var summary ContractSummary
+	b, err = stub.query(nil, "getContractSummary", "1000")
+	if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != statusExpired {
+		t.Fatalf("getContractSummary: %s %v", b, err)
+	}
 }
 
-//defaultedOnPayment
-func defaultedOnPayment(admCert crypto.CertificateHandler, contractID string) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
-
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
-
-        //chaincodeInput := &pb.ChaincodeInput{Function: "defaultedOnPayment", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("defaultedOnPayment"), []byte(contractID)}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
-
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+func TestJSONArgs(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	// The PO may be given as an object
+	_, err := stub.invoke(adminCert, "initTrade", `{
+		"contractID": "1000",
+		"po": {"Tag20": "L960477", "Tag31D": "120831-USA", "Tag32B": "USD10000"},
+		"importerName": "I", "exporterName": "E", "importerBankName": "IB", "exporterBankName": "EB",
+		"importerCert": "ICert", "exporterCert": "ECert", "importerBankCert": "IBCert", "exporterBankCert": "EBCert"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	// Optional fields in between are left empty
+	if _, err = stub.invoke(adminCert, "submitED", `{"contractID": "1000", "packingListPDF": "PLPDF"}`); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := stub.query(nil, "getED", `{"contractID": "1000", "docType": "PACKINGLIST"}`); err != nil || string(b) != "PLPDF" {
+		t.Fatalf("getED: %s %v", b, err)
+	}
+	if _, err := stub.query(nil, "getED", `{"contractID": "1000", "docType": "BL"}`); errorCode(err) != codeNotFound {
+		t.Fatalf("getED of a document left out: %v", err)
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	var summary ContractSummary
+	b, err := stub.query(nil, "getContractSummary", `{"contractID": "1000"}`)
+	if err = json.Unmarshal(b, &summary); err != nil || summary.LCNumber != "L960477" || summary.Participants[0].ID != "I" {
+		t.Fatalf("getContractSummary: %s %v", b, err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	var contractsList ContractsList
+	b, err = stub.query(nil, "listContracts", `{"includeClosed": true}`)
+	if err = json.Unmarshal(b, &contractsList); err != nil || len(contractsList.Contracts) != 1 {
+		t.Fatalf("listContracts: %s %v", b, err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	tests := []struct {
+		function string
+		request  string
+		message  string
+	}{
+		{"cancelTrade", `{"contractID": "1000", "role": "ImporterBank", "reason": "x", "by": "y"}`, `Invalid request for cancelTrade: unknown field "by"; unknown field "reason".`},
+		{"cancelTrade", `{"contractID": "1000"}`, `Invalid request for cancelTrade: missing field "role".`},
+		{"updatePO", `{"contractID": "1000", "po": 5}`, `Invalid request for updatePO: field "po" should be a json.`},
+		{"acceptED", `{"contractID": `, ``},
+	}
+	for _, test := range tests {
+		_, err := stub.invoke(adminCert, test.function, test.request)
+		e, ok := err.(*ChaincodeError)
+		if !ok || e.Code != codeInvalidArgs || (test.message != "" && e.Message != test.message) {
+			t.Errorf("%s %s: %v", test.function, test.request, err)
+		}
+	}
 
-        return err
+	if _, err := stub.query(nil, "listContracts", `{"includeClosed": "yes"}`); errorCode(err) != codeInvalidArgs {
+		t.Errorf("listContracts with a string flag: %v", err)
+	}
 }
 
-*/
+// stringColumn builds a string column of a legacy table row
+func stringColumn(value string) *shim.Column {
+	return &shim.Column{Value: &shim.Column_String_{String_: value}}
+}
 
-/*func validatePO(POJSON []byte) ([]byte, error) {
-        //chaincodeInput := &pb.ChaincodeInput{Function: "validateLC", Args: []string{string(LCJSON)}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("validatePO"), POJSON}}
+// bytesColumn builds a bytes column of a legacy table row
+func bytesColumn(value []byte) *shim.Column {
+	return &shim.Column{Value: &shim.Column_Bytes{Bytes: value}}
+}
 
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+func TestIdempotentInvoke(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	initRequest := `{"requestID": "req-1", "contractID": "1000", "po": {"Tag20": "L960477"},
+		"importerName": "I", "exporterName": "E", "importerBankName": "IB", "exporterBankName": "EB",
+		"importerCert": "ICert", "exporterCert": "ECert", "importerBankCert": "IBCert", "exporterBankCert": "EBCert"}`
+	for i := 0; i < 2; i++ {
+		if _, err := stub.invoke(adminCert, "initTrade", initRequest); err != nil {
+			t.Fatalf("initTrade attempt %d: %v", i+1, err)
+		}
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	// The same ID for a different request on the contract is rejected
+	_, err := stub.invoke(adminCert, "initTrade", strings.Replace(initRequest, `"importerName": "I"`, `"importerName": "I2"`, 1))
+	if errorCode(err) != codeDuplicate {
+		t.Fatalf("initTrade reusing a request ID: %v", err)
+	}
+	if _, err = stub.invoke(adminCert, "closeTrade", `{"requestID": "req-1", "contractID": "1000"}`); errorCode(err) != codeDuplicate {
+		t.Fatalf("closeTrade reusing a request ID: %v", err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// Request IDs are scoped to their contract, clients of another contract may pick the same ones
+	if _, err = stub.invoke(adminCert, "initTrade", strings.Replace(initRequest, `"1000"`, `"1001"`, 1)); err != nil {
+		t.Fatalf("initTrade of another contract with the same request ID: %v", err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	for _, contractID := range []string{"1000", "1001"} {
+		if err = submitED(stub, adminCert, contractID, []byte(`BLPDF`), []byte(`InvoicePDF`), []byte(`PLPDF`)); err != nil {
+			t.Fatal(err)
+		}
 
-        return result, err
-}
-*/
+		// A retried acceptance moves the documents forward only once
+		for i := 0; i < 2; i++ {
+			if _, err = stub.invoke(adminCert, "acceptED", `{"requestID": "req-2", "contractID": "`+contractID+`"}`); err != nil {
+				t.Fatalf("acceptED of contract %s attempt %d: %v", contractID, i+1, err)
+			}
+		}
+	}
+	var status DocumentStatus
+	var b []byte
+	for _, contractID := range []string{"1000", "1001"} {
+		b, err = getEDStatus(stub, contractID)
+		if err = json.Unmarshal(b, &status); err != nil || status.Status != "ACCEPTED_BY_IB" {
+			t.Fatalf("getEDStatus of contract %s: %s %v", contractID, b, err)
+		}
+	}
 
-/*
-func getPOStatus(contractID string) ([]byte, error) {
-        //chaincodeInput := &pb.ChaincodeInput{Function: "getLCStatus", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("getPOStatus"), []byte(contractID)}}
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	// Positional invokes pass the request ID as their last argument
+	for i := 0; i < 2; i++ {
+		if _, err = stub.invoke(adminCert, "acceptED", "1000", "requestID=req-7"); err != nil {
+			t.Fatalf("positional acceptED attempt %d: %v", i+1, err)
+		}
+	}
+	b, err = getEDStatus(stub, "1000")
+	if err = json.Unmarshal(b, &status); err != nil || status.Status != "PAYMENT_INITIATED" {
+		t.Fatalf("getEDStatus after a positional retry: %s %v", b, err)
+	}
+	if _, err = stub.invoke(adminCert, "closeTrade", "1000", "requestID=req-1"); errorCode(err) != codeDuplicate {
+		t.Fatalf("positional closeTrade reusing a request ID: %v", err)
+	}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	// Only an argument after all the arguments of the function is a request ID
+	if err = initTrade(stub, adminCert, "1002", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	statement := "requestID=REF 1 - WE CERTIFY THE GOODS ARE NEW"
+	if _, err = stub.invoke(adminCert, "submitDocument", "1002", "BENEFICIARY_STATEMENT", statement); err != nil {
+		t.Fatalf("submitDocument with content looking like a request ID: %v", err)
+	}
+	if b, err = stub.query(nil, "getED", "1002", "BENEFICIARY_STATEMENT"); err != nil || string(b) != statement {
+		t.Fatalf("getED of content looking like a request ID: %q %v", b, err)
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	// Settle the payment so that the contract can be closed
+	for i := 0; i < 2; i++ {
+		if err = acceptED(stub, adminCert, "1000"); err != nil {
+			t.Fatal(err)
+		}
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// A retry is answered even when the action itself could not be applied again
+	closeRequest := `{"requestID": "req-3", "contractID": "1000"}`
+	for i := 0; i < 2; i++ {
+		if _, err = stub.invoke(adminCert, "closeTrade", closeRequest); err != nil {
+			t.Fatalf("closeTrade attempt %d: %v", i+1, err)
+		}
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return nil, fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	// Failed invokes are not recorded, so they can be retried under the same ID
+	if _, err = stub.invoke(adminCert, "rejectED", `{"requestID": "req-4", "contractID": "1000"}`); errorCode(err) != codeInvalidTransition {
+		t.Fatalf("rejectED on a closed contract: %v", err)
+	}
+	if record, err := requestStore.Get(stub, "1000", "req-4"); err != nil || record != nil {
+		t.Fatalf("failed request recorded: %v %v", record, err)
+	}
 
-        return result, err
+	if _, err = stub.invoke(adminCert, "closeTrade", `{"requestID": "req~5", "contractID": "1000"}`); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("request ID with a separator: %v", err)
+	}
+	if _, err = stub.query(nil, "getEDStatus", `{"requestID": "req-6", "contractID": "1000"}`); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("query with a request ID: %v", err)
+	}
 }
 
-func acceptPO(admCert crypto.CertificateHandler, contractID string) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
-
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
-
-        //chaincodeInput := &pb.ChaincodeInput{Function: "acceptLC", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("acceptPO"), []byte(contractID)}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
+func TestContractIDAllocation(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
 
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
-
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	allocate := func(importerBankName string) string {
+		b, err := stub.invoke(adminCert, "initTrade", "", string(testPOJSON), "I", "E", importerBankName, "EB", "ICert", "ECert", "IBCert", "EBCert")
+		var result TradeInitiated
+		if err == nil {
+			err = json.Unmarshal(b, &result)
+		}
+		if err != nil {
+			t.Fatalf("initTrade for %s: %s %v", importerBankName, b, err)
+		}
+		return result.ContractID
+	}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	// IDs chosen by the caller are kept and skipped by the allocation
+	if err := initTrade(stub, adminCert, "IB-2012-000002", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	for _, test := range []struct {
+		importerBankName string
+		contractID       string
+	}{
+		{"IB", "IB-2012-000001"},
+		{"IB", "IB-2012-000003"},
+		{"First Importer Bank", "FIRSTIMP-2012-000001"},
+		{"~", "LC-2012-000001"},
+	} {
+		if ID := allocate(test.importerBankName); ID != test.contractID {
+			t.Errorf("contract ID for %s: %s, want %s", test.importerBankName, ID, test.contractID)
+		}
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// Sequences restart every year
+	stub.Now = stub.Now.AddDate(1, 0, 0)
+	if ID := allocate("IB"); ID != "IB-2013-000001" {
+		t.Errorf("contract ID in 2013: %s", ID)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	// The contract ID may be left out of a JSON request
+	b, err := stub.invoke(adminCert, "initTrade", `{"po": {"Tag20": "L960477"},
+		"importerName": "I", "exporterName": "E", "importerBankName": "IB", "exporterBankName": "EB",
+		"importerCert": "ICert", "exporterCert": "ECert", "importerBankCert": "IBCert", "exporterBankCert": "EBCert"}`)
+	if err != nil || string(b) != `{"contractID":"IB-2013-000002"}` {
+		t.Fatalf("initTrade without a contract ID: %s %v", b, err)
+	}
+	if _, err = stub.query(nil, "getContractSummary", "IB-2013-000002"); err != nil {
+		t.Fatal(err)
+	}
 
-        return err
+	// The positional form still needs every argument
+	if _, err = stub.invoke(adminCert, "initTrade", string(testPOJSON), "I", "E", "IB", "EB", "ICert", "ECert", "IBCert", "EBCert"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("initTrade without the contract ID position: %v", err)
+	}
 }
 
-*/
-//getNumContracts
-func getNumContracts() ([]byte, error) {
-	//chaincodeInput := &pb.ChaincodeInput{Function: "getNumContracts", Args: []string{}}
-	chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("getNumContracts")}}
+func TestBulkInitTrades(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
 
-	// Prepare spec and submit
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              chaincodeInput,
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
+	trade := func(contractID string, extra string) string {
+		return `{"contractID": "` + contractID + `", "po": ` + string(testPOJSON) + `,
+			"importerName": "I", "exporterName": "E", "importerBankName": "IB", "exporterBankName": "EB",
+			"importerCert": "ICert", "exporterCert": "ECert", "importerBankCert": "IBCert", "exporterBankCert": "EBCert"` + extra + `}`
 	}
 
-	var ctx = context.Background()
-	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	var result BulkImportResult
+	b, err := stub.invoke(adminCert, "bulkInitTrades", "["+strings.Join([]string{
+		trade("2000", `, "blPDF": "BLPDF", "invoicePDF": "InvoicePDF", "packingListPDF": "PLPDF", "edStatus": "ACCEPTED_BY_IB"`),
+		trade("", ""),
+		trade("2001", `, "blPDF": "BLPDF", "edStatus": "PAYMENT_COMPLETED", "status": "CLOSED"`),
+	}, ",")+"]")
+	if err = json.Unmarshal(b, &result); err != nil || result.Imported != 3 || strings.Join(result.ContractIDs, ",") != "2000,IB-2012-000001,2001" {
+		t.Fatalf("bulkInitTrades: %s %v", b, err)
+	}
 
-	tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	for contractID, want := range map[string]string{"2000": "PAYMENT_PENDING", "IB-2012-000001": "DOCS_PENDING", "2001": "CLOSED"} {
+		var summary ContractSummary
+		b, err = getContractSummary(stub, contractID)
+		if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != want {
+			t.Errorf("imported contract %s: %s %v, want %s", contractID, b, err, want)
+		}
+	}
+	var status DocumentStatus
+	b, err = getEDStatus(stub, "2000")
+	if err = json.Unmarshal(b, &status); err != nil || status.Status != "ACCEPTED_BY_IB" {
+		t.Fatalf("getEDStatus of an imported trade: %s %v", b, err)
+	}
 
-	// Now create the Transactions message and send to Peer.
-	transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
+	// A single invalid trade stops the whole batch
+	_, err = stub.invoke(adminCert, "bulkInitTrades", `{"trades": [`+strings.Join([]string{
+		trade("3000", ""),
+		trade("2000", ""),
+		trade("3001", `, "importerName": "", "edStatus": "ACCEPTED_BY_IB"`),
+		trade("3~002", ""),
+		trade("3000", ""),
+	}, ",")+`]}`)
+	cerr, ok := err.(*ChaincodeError)
+	if !ok || cerr.Code != codeValidationFailed || len(cerr.Items) != 4 {
+		t.Fatalf("bulkInitTrades with invalid trades: %v", err)
+	}
+	for i, want := range []ItemError{
+		{Index: 1, Code: codeDuplicate},
+		{Index: 2, Code: codeValidationFailed, Message: "edStatus given without documents; missing importerName"},
+		{Index: 3, Code: codeInvalidArgs},
+		{Index: 4, Code: codeDuplicate, Message: "Contract 3000 is also trade 0 of the batch."},
+	} {
+		got := cerr.Items[i]
+		if got.Index != want.Index || got.Code != want.Code || (want.Message != "" && got.Message != want.Message) {
+			t.Errorf("item error %d: %+v, want %+v", i, got, want)
+		}
 	}
 
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
+	var count ContractCount
+	b, err = getNumContracts(stub)
+	if err = json.Unmarshal(b, &count); err != nil || count.NumContracts != 3 {
+		t.Fatalf("getNumContracts after a rejected batch: %s %v", b, err)
 	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
 
-	return result, err
+	if _, err = stub.invoke(adminCert, "bulkInitTrades", "[]"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("bulkInitTrades of an empty batch: %v", err)
+	}
+	if _, err = stub.invoke(adminCert, "bulkInitTrades", `{"trades": {}}`); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("bulkInitTrades of an object: %v", err)
+	}
 }
 
-//listContracts
-func listContracts() ([]byte, error) {
-	//chaincodeInput := &pb.ChaincodeInput{Function: "listContracts", Args: []string{}}
-	chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("listContracts")}}
+func TestMigrateTablesAdmin(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
 
-	// Prepare spec and submit
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              chaincodeInput,
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
+	stub := newMockStub()
+	deployerCert := []byte(`DeployerCert`)
+	if _, err := stub.deploy(deployerCert); err != nil {
+		t.Fatal(err)
 	}
 
-	var ctx = context.Background()
-	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-
-	tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
-
-	// Now create the Transactions message and send to Peer.
-	transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
+	if _, err := stub.invoke([]byte(`IBCert`), "migrateTables"); errorCode(err) != codeAccessDenied {
+		t.Fatalf("migrateTables by a bank: %v", err)
 	}
-
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
+	if _, err := stub.invoke(deployerCert, "migrateTables"); err != nil {
+		t.Fatalf("migrateTables by the admin: %v", err)
 	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
-
-	return result, err
 }
 
-//listContractsByRole
-func listContractsByRole(role string) ([]byte, error) {
-	//chaincodeInput := &pb.ChaincodeInput{Function: "listContractsByRole", Args: []string{role}}
-	chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("listContractsByRole"), []byte(role)}}
+func TestBulkInitTradesAdmin(t *testing.T) {
+	accessControlFlag = true
+	defer func() { accessControlFlag = false }()
 
-	// Prepare spec and submit
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              chaincodeInput,
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
+	stub := newMockStub()
+	deployerCert := []byte(`DeployerCert`)
+	if _, err := stub.deploy(deployerCert); err != nil {
+		t.Fatal(err)
 	}
 
-	var ctx = context.Background()
-	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-
-	tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	trades := `[{"contractID": "2000", "po": ` + string(testPOJSON) + `,
+		"importerName": "I", "exporterName": "E", "importerBankName": "IB", "exporterBankName": "EB",
+		"importerCert": "ICert", "exporterCert": "ECert", "importerBankCert": "IBCert", "exporterBankCert": "EBCert",
+		"blPDF": "BLPDF", "edStatus": "PAYMENT_COMPLETED", "status": "CLOSED"}]`
 
-	// Now create the Transactions message and send to Peer.
-	transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
+	// Imported trades skip the acceptance and the payment, only the admin may import them
+	if _, err := stub.invoke([]byte(`IBCert`), "bulkInitTrades", trades); errorCode(err) != codeAccessDenied {
+		t.Fatalf("bulkInitTrades by a bank: %v", err)
 	}
-
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
+	if _, err := stub.invoke(deployerCert, "bulkInitTrades", trades); err != nil {
+		t.Fatal(err)
 	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
 
-	return result, err
+	// An upgrade without deployer metadata keeps the admin
+	if _, err := stub.init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stub.invoke(deployerCert, "bulkInitTrades", strings.Replace(trades, "2000", "2001", 1)); err != nil {
+		t.Fatalf("bulkInitTrades after an upgrade: %v", err)
+	}
 }
 
-//acceptED
-func acceptED(admCert crypto.CertificateHandler, contractID string) error {
-        // Get a transaction handler to be used to submit the execute transaction
-        // and bind the chaincode access control logic using the binding
+func TestMigrateTables(t *testing.T) {
+	stub := newMockStub()
+	createLegacyTables(stub)
 
-        submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
-        if err != nil {
-                return err
-        }
-        txHandler, err := submittingCertHandler.GetTransactionHandler()
-        if err != nil {
-                return err
-        }
-        binding, err := txHandler.GetBinding()
-        if err != nil {
-                return err
-        }
-
-        //chaincodeInput := &pb.ChaincodeInput{Function: "acceptED", Args: []string{contractID}}
-        chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("acceptED"), []byte(contractID)}}
-        chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-        if err != nil {
-                return err
-        }
+	var result MigrationResult
+	b, err := stub.invoke(nil, "migrateTables")
+	if err = json.Unmarshal(b, &result); err != nil || result.Contracts != 2 || result.Documents != 4 {
+		t.Fatalf("migrateTables: %s %v", b, err)
+	}
 
-        // Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-        sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-        if err != nil {
-                return err
-        }
+	// The legacy IN_PROGRESS status is derived from the documents
+	var summary ContractSummary
+	b, err = getContractSummary(stub, "1000")
+	if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != statusUnderExamination || summary.LCNumber != "L960477" || summary.Documents[0].Status != "SUBMITTED_BY_EB" {
+		t.Fatalf("getContractSummary after migration: %s %v", b, err)
+	}
+	b, err = getContractSummary(stub, "1001")
+	if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != statusSettled {
+		t.Fatalf("getContractSummary of a paid contract after migration: %s %v", b, err)
+	}
+	var contractsList ContractsList
+	b, err = listContractsByStatus(stub, statusSettled)
+	if err = json.Unmarshal(b, &contractsList); err != nil || len(contractsList.Contracts) != 1 || contractsList.Contracts[0].ContractID != "1001" {
+		t.Fatalf("listContractsByStatus after migration: %s %v", b, err)
+	}
+	if err = closeTrade(stub, nil, "1001"); err != nil {
+		t.Fatalf("closeTrade of a migrated paid contract: %v", err)
+	}
 
-        // Prepare spec and submit
-        spec := &pb.ChaincodeSpec{
-                Type:                 1,
-                ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-                CtorMsg:              chaincodeInput,
-                Metadata:             sigma, // Proof of identity
-                ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-        }
+	// Migrating again copies nothing
+	b, err = stub.invoke(nil, "migrateTables")
+	if err = json.Unmarshal(b, &result); err != nil || result.Contracts != 0 || result.Documents != 0 {
+		t.Fatalf("second migrateTables: %s %v", b, err)
+	}
+}
 
-        var ctx = context.Background()
-        chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+func TestSchemaMigration(t *testing.T) {
+	stub := newMockStub()
 
-        tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+	var status SchemaStatus
+	b, err := stub.query(nil, "getSchemaVersion")
+	if err = json.Unmarshal(b, &status); err != nil || status.Version != latestSchemaVersion() || status.LatestVersion != latestSchemaVersion() {
+		t.Fatalf("getSchemaVersion of a new ledger: %s %v", b, err)
+	}
 
-        // Now create the Transactions message and send to Peer.
-        transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s ", err)
-        }
+	// A ledger written before schema versioning is upgraded by Init
+	delete(stub.state, "SCHEMA")
+	createLegacyTables(stub)
+	stub.Now = stub.Now.Add(time.Hour)
+	if _, err = stub.init(); err != nil {
+		t.Fatal(err)
+	}
 
-        ledger, err := ledger.GetLedger()
-        ledger.BeginTxBatch("1")
-        _, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-        if err != nil {
-                return fmt.Errorf("Error deploying chaincode: %s", err)
-        }
-        ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+	b, err = stub.query(nil, "getSchemaVersion")
+	if err = json.Unmarshal(b, &status); err != nil || status.Version != latestSchemaVersion() || status.MigratedAt != "2012-07-12T11:00:00Z" {
+		t.Fatalf("getSchemaVersion after the upgrade: %s %v", b, err)
+	}
 
-        return err
-}
+	var summary ContractSummary
+	b, err = getContractSummary(stub, "1000")
+	if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != statusUnderExamination || summary.LastUpdated != status.MigratedAt {
+		t.Fatalf("getContractSummary after the upgrade: %s %v", b, err)
+	}
 
-//rejectED
-func rejectED(admCert crypto.CertificateHandler, contractID string) error {
-	// Get a transaction handler to be used to submit the execute transaction
-	// and bind the chaincode access control logic using the binding
+	// Running Init again changes nothing
+	stub.Now = stub.Now.Add(time.Hour)
+	if _, err = stub.init(); err != nil {
+		t.Fatal(err)
+	}
+	b, err = stub.query(nil, "getSchemaVersion")
+	if err = json.Unmarshal(b, &status); err != nil || status.MigratedAt != "2012-07-12T11:00:00Z" {
+		t.Fatalf("getSchemaVersion after a second Init: %s %v", b, err)
+	}
 
-	submittingCertHandler, err := administrator.GetTCertificateHandlerNext()
+	// A ledger migrated before the legacy status was derived still holds IN_PROGRESS contracts
+	bp, err := bpStore.Get(stub, "1001")
 	if err != nil {
-		return err
+		t.Fatal(err)
 	}
-	txHandler, err := submittingCertHandler.GetTransactionHandler()
-	if err != nil {
-		return err
+	bp.Status = legacyStatusInProgress
+	if err = bpStore.Replace(stub, bp); err != nil {
+		t.Fatal(err)
 	}
-	binding, err := txHandler.GetBinding()
-	if err != nil {
-		return err
+	stub.state["SCHEMA"] = []byte(`{"Version": 3}`)
+	if _, err = stub.init(); err != nil {
+		t.Fatal(err)
 	}
-
-	//chaincodeInput := &pb.ChaincodeInput{Function: "rejectED", Args: []string{contractID}}
-	chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("rejectED"), []byte(contractID)}}
-
-	chaincodeInputRaw, err := proto.Marshal(chaincodeInput)
-	if err != nil {
-		return err
+	b, err = getContractSummary(stub, "1001")
+	if err = json.Unmarshal(b, &summary); err != nil || summary.ContractStatus != statusSettled || summary.LastUpdated != status.MigratedAt {
+		t.Fatalf("getContractSummary after upgrading a migrated ledger: %s %v", b, err)
 	}
 
-	// Access control. Administrator signs chaincodeInputRaw || binding to confirm his identity
-	sigma, err := admCert.Sign(append(chaincodeInputRaw, binding...))
-	if err != nil {
-		return err
+	// The chaincode refuses a ledger written by a newer version
+	stub.state["SCHEMA"] = []byte(`{"Version": 99}`)
+	if _, err = stub.init(); errorCode(err) != codeInternal {
+		t.Fatalf("Init of a newer ledger: %v", err)
 	}
+}
 
-	// Prepare spec and submit
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              chaincodeInput,
-		Metadata:             sigma, // Proof of identity
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
+// createLegacyTables writes contracts 1000, whose bill of lading is submitted, and 1001, whose payment is completed,
+// with their PO and bill of lading in the table layout, as an old ledger would have it. The table layout
+// left every contract IN_PROGRESS.
+func createLegacyTables(stub *mockStub) {
+	stub.CreateTable("BPTable", []*shim.ColumnDefinition{
+		&shim.ColumnDefinition{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
+		&shim.ColumnDefinition{Name: "UID", Type: shim.ColumnDefinition_STRING, Key: true},
+		&shim.ColumnDefinition{Name: "Status", Type: shim.ColumnDefinition_STRING, Key: false},
+		&shim.ColumnDefinition{Name: "ImporterName", Type: shim.ColumnDefinition_STRING, Key: false},
+		&shim.ColumnDefinition{Name: "ExporterName", Type: shim.ColumnDefinition_STRING, Key: false},
+		&shim.ColumnDefinition{Name: "ImporterBankName", Type: shim.ColumnDefinition_STRING, Key: false},
+		&shim.ColumnDefinition{Name: "ExporterBankName", Type: shim.ColumnDefinition_STRING, Key: false},
+		&shim.ColumnDefinition{Name: "ImporterCert", Type: shim.ColumnDefinition_BYTES, Key: false},
+		&shim.ColumnDefinition{Name: "ExporterCert", Type: shim.ColumnDefinition_BYTES, Key: false},
+		&shim.ColumnDefinition{Name: "ImporterBankCert", Type: shim.ColumnDefinition_BYTES, Key: false},
+		&shim.ColumnDefinition{Name: "ExporterBankCert", Type: shim.ColumnDefinition_BYTES, Key: false},
+	})
+	for _, contractID := range []string{"1000", "1001"} {
+		stub.InsertRow("BPTable", shim.Row{Columns: []*shim.Column{
+			stringColumn("BP"), stringColumn(contractID), stringColumn("IN_PROGRESS"),
+			stringColumn("I"), stringColumn("E"), stringColumn("IB"), stringColumn("EB"),
+			bytesColumn([]byte(`ICert`)), bytesColumn([]byte(`ECert`)), bytesColumn([]byte(`IBCert`)), bytesColumn([]byte(`EBCert`))}})
 	}
 
-	var ctx = context.Background()
-	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-
-	tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
-
-	// Now create the Transactions message and send to Peer.
-	transaction, err := txHandler.NewChaincodeExecute(chaincodeInvocationSpec, tid)
-	if err != nil {
-		return fmt.Errorf("Error deploying chaincode: %s ", err)
+	for _, tableName := range []string{"POTable", "BLTable"} {
+		stub.CreateTable(tableName, []*shim.ColumnDefinition{
+			&shim.ColumnDefinition{Name: "Type", Type: shim.ColumnDefinition_STRING, Key: true},
+			&shim.ColumnDefinition{Name: "UID", Type: shim.ColumnDefinition_STRING, Key: true},
+			&shim.ColumnDefinition{Name: "Doc", Type: shim.ColumnDefinition_BYTES, Key: false},
+			&shim.ColumnDefinition{Name: "Status", Type: shim.ColumnDefinition_STRING, Key: false},
+		})
 	}
-
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	_, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return fmt.Errorf("Error deploying chaincode: %s", err)
+	for contractID, blStatus := range map[string]string{"1000": "SUBMITTED_BY_EB", "1001": "PAYMENT_COMPLETED"} {
+		stub.InsertRow("POTable", shim.Row{Columns: []*shim.Column{stringColumn("DOC"), stringColumn(contractID), bytesColumn(testPOJSON), stringColumn("SUBMITTED_BY_IB")}})
+		stub.InsertRow("BLTable", shim.Row{Columns: []*shim.Column{stringColumn("DOC"), stringColumn(contractID), bytesColumn([]byte(`BLPDF`)), stringColumn(blStatus)}})
 	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+}
 
+//initTrade
+func initTrade(stub *mockStub, caller []byte, contractID string, POJSON []byte, importerName string, exporterName string, importerBankName string, exporterBankName string, importerCert []byte, exporterCert []byte, importerBankCert []byte, exporterBankCert []byte) error {
+	_, err := stub.invoke(caller, "initTrade", contractID, string(POJSON), importerName, exporterName, importerBankName, exporterBankName, string(importerCert), string(exporterCert), string(importerBankCert), string(exporterBankCert))
 	return err
 }
 
-//getContractParticipants
-func getContractParticipants(contractID string) ([]byte, error) {
-	//chaincodeInput := &pb.ChaincodeInput{Function: "getContractParticipants", Args: []string{contractID}}
-	chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("getContractParticipants"), []byte(contractID)}}
+//submitED
+func submitED(stub *mockStub, caller []byte, contractID string, BLPDF []byte, invoicePDF []byte, packingListPDF []byte) error {
+	_, err := stub.invoke(caller, "submitED", contractID, string(BLPDF), string(invoicePDF), string(packingListPDF))
+	return err
+}
 
-	// Prepare spec and submit
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              chaincodeInput,
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-	}
+//acceptED
+func acceptED(stub *mockStub, caller []byte, contractID string) error {
+	_, err := stub.invoke(caller, "acceptED", contractID)
+	return err
+}
 
-	var ctx = context.Background()
-	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+//rejectED
+func rejectED(stub *mockStub, caller []byte, contractID string) error {
+	_, err := stub.invoke(caller, "rejectED", contractID)
+	return err
+}
 
-	tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+//updatePO
+func updatePO(stub *mockStub, caller []byte, contractID string, POJSON []byte) error {
+	_, err := stub.invoke(caller, "updatePO", contractID, string(POJSON))
+	return err
+}
 
-	// Now create the Transactions message and send to Peer.
-	transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
-	}
+//cancelTrade
+func cancelTrade(stub *mockStub, caller []byte, contractID string, role string) error {
+	_, err := stub.invoke(caller, "cancelTrade", contractID, role)
+	return err
+}
 
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
-	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+//closeTrade
+func closeTrade(stub *mockStub, caller []byte, contractID string) error {
+	_, err := stub.invoke(caller, "closeTrade", contractID)
+	return err
+}
 
-	return result, err
+//getEDStatus
+func getEDStatus(stub *mockStub, contractID string) ([]byte, error) {
+	return stub.query(nil, "getEDStatus", contractID)
 }
 
-//listEDsByStatus
-func listEDsByStatus(status string) ([]byte, error) {
-	//chaincodeInput := &pb.ChaincodeInput{Function: "listEDsByStatus", Args: []string{status}}
-	chaincodeInput := &pb.ChaincodeInput{Args: [][]byte{[]byte("listEDsByStatus"), []byte(status)}}
+//getNumContracts
+func getNumContracts(stub *mockStub) ([]byte, error) {
+	return stub.query(nil, "getNumContracts")
+}
 
-	// Prepare spec and submit
-	spec := &pb.ChaincodeSpec{
-		Type:                 1,
-		ChaincodeID:          &pb.ChaincodeID{Name: "mycc"},
-		CtorMsg:              chaincodeInput,
-		ConfidentialityLevel: pb.ConfidentialityLevel_PUBLIC,
-	}
+//listContracts
+func listContracts(stub *mockStub) ([]byte, error) {
+	return stub.query(nil, "listContracts")
+}
 
-	var ctx = context.Background()
-	chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+//listAllContracts lists the contracts including the closed and cancelled ones
+func listAllContracts(stub *mockStub) ([]byte, error) {
+	return stub.query(nil, "listContracts", "true")
+}
 
-	tid := chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name
+//listContractsByRole
+func listContractsByRole(stub *mockStub, role string) ([]byte, error) {
+	return stub.query(nil, "listContractsByRole", role)
+}
 
-	// Now create the Transactions message and send to Peer.
-	transaction, err := administrator.NewChaincodeQuery(chaincodeInvocationSpec, tid)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
-	}
+//listContractsByStatus
+func listContractsByStatus(stub *mockStub, status string) ([]byte, error) {
+	return stub.query(nil, "listContractsByStatus", status)
+}
 
-	ledger, err := ledger.GetLedger()
-	ledger.BeginTxBatch("1")
-	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
-	if err != nil {
-		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
-	}
-	ledger.CommitTxBatch("1", []*pb.Transaction{transaction}, nil, nil)
+//listEDsByStatus
+func listEDsByStatus(stub *mockStub, status string) ([]byte, error) {
+	return stub.query(nil, "listEDsByStatus", status)
+}
 
-	return result, err
+//getContractParticipants
+func getContractParticipants(stub *mockStub, contractID string) ([]byte, error) {
+	return stub.query(nil, "getContractParticipants", contractID)
 }
 
+//getContractSummary
+func getContractSummary(stub *mockStub, contractID string) ([]byte, error) {
+	return stub.query(nil, "getContractSummary", contractID)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// schemaMigration upgrades the world state written by the previous schema version to Version
+type schemaMigration struct {
+	Version     int
+	Description string
+	Migrate     func(t *SBI, stub shim.ChaincodeStubInterface) error
+}
+
+// schemaMigrations are run in order by Init. New steps are appended with the next version,
+// existing steps are never changed since ledgers may already be past them.
+var schemaMigrations = []schemaMigration{
+	{
+		Version:     1,
+		Description: "Copy the legacy tables into the key-value layout",
+		Migrate: func(t *SBI, stub shim.ChaincodeStubInterface) error {
+			_, err := t.migrateTables(stub, nil)
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "Set the last update time of contracts copied from rows without one",
+		Migrate:     backfillLastUpdated,
+	},
+	{
+		Version:     3,
+		Description: "Derive the presentation checklist of existing contracts from Tag46A",
+		Migrate:     captureChecklists,
+	},
+	{
+		Version:     4,
+		Description: "Derive the status of contracts copied with the legacy IN_PROGRESS status",
+		Migrate:     deriveLegacyStatuses,
+	},
+}
+
+// SchemaStatus is the result of getSchemaVersion
+type SchemaStatus struct {
+	Version       int    `json:"version"`
+	LatestVersion int    `json:"latestVersion"`
+	MigratedAt    string `json:"migratedAt"`
+}
+
+// latestSchemaVersion is the schema version written by this chaincode
+func latestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].Version
+}
+
+// migrateSchema runs the migrations the world state has not been through yet, then records the latest version
+func (t *SBI) migrateSchema(stub shim.ChaincodeStubInterface) error {
+	current, err := schemaStore.Get(stub)
+	if err != nil {
+		return err
+	}
+
+	latest := latestSchemaVersion()
+	if current.Version > latest {
+		return newError(codeInternal, "Schema version %d of the ledger is newer than version %d of the chaincode.", current.Version, latest)
+	}
+	if current.Version == latest {
+		return nil
+	}
+
+	for _, migration := range schemaMigrations {
+		if migration.Version <= current.Version {
+			continue
+		}
+
+		myLogger.Debugf("Migrating schema to version %d: %s", migration.Version, migration.Description)
+		err = migration.Migrate(t, stub)
+		if err != nil {
+			return newError(codeInternal, "Failed migrating schema to version %d. Error %s", migration.Version, err.Error())
+		}
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	return schemaStore.Put(stub, &SchemaVersion{Version: latest, MigratedAt: now})
+}
+
+// backfillLastUpdated sets the last update time of the contracts without one to the time of the migration
+func backfillLastUpdated(t *SBI, stub shim.ChaincodeStubInterface) error {
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	bps, err := bpStore.List(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, bp := range bps {
+		if bp.LastUpdated != "" {
+			continue
+		}
+		bp.LastUpdated = now
+		err = bpStore.Replace(stub, bp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getSchemaVersion returns the schema version of the world state
+func (t *SBI) getSchemaVersion(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	current, err := schemaStore.Get(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(SchemaStatus{
+		Version:       current.Version,
+		LatestVersion: latestSchemaVersion(),
+		MigratedAt:    current.MigratedAt,
+	})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// SignedDocument is a detached signature of an export document submitted with submitED
+type SignedDocument struct {
+	DocType     string `json:"docType"`
+	Signature   []byte `json:"signature"`   // signature of the document content
+	Certificate string `json:"certificate"` // certificate of the signer
+}
+
+// SignatureVerification is the result of verifyDocumentSignature
+type SignatureVerification struct {
+	ContractID  string `json:"contractID"`
+	DocType     string `json:"docType"`
+	Signed      bool   `json:"signed"`
+	Valid       bool   `json:"valid"`            // the signature matches the document as it is on the ledger and the signer is trusted
+	Trusted     bool   `json:"trusted"`          // the signer is a participant of the contract or a registered party
+	Signer      string `json:"signer,omitempty"` // role of the signer on the contract, if any
+	Party       string `json:"party,omitempty"`  // ID of the signer as a registered party, if any
+	Subject     string `json:"subject,omitempty"`
+	Certificate []byte `json:"certificate,omitempty"`
+	SignedAt    string `json:"signedAt,omitempty"`
+}
+
+// verifyDetached checks signature of content by the holder of certificate, like isCaller checks the caller
+func verifyDetached(stub shim.ChaincodeStubInterface, certificate, signature, content []byte) (bool, error) {
+	ok, err := stub.VerifySignature(certificate, signature, content)
+	if err != nil {
+		myLogger.Error("Failed checking signature ", err.Error())
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// signDocument verifies the detached signature of the document of the contract and stores it
+func signDocument(stub shim.ChaincodeStubInterface, UID string, docType string, signature []byte, certificate string) error {
+	dt, ok := lookupDocumentType(docType)
+	if !ok {
+		return documentTypeError(docType)
+	}
+	if len(signature) == 0 || certificate == "" {
+		return newError(codeInvalidArgs, "A signature of %s needs both the signature and the signer certificate.", dt.Code)
+	}
+
+	doc, err := dt.Store.Get(stub, UID)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return newError(codeNotFound, "%s of contract %s does not exist.", dt.Code, UID)
+	}
+
+	ok, err = verifyDetached(stub, []byte(certificate), signature, doc.Content)
+	if err != nil || !ok {
+		return newError(codeValidationFailed, "Signature of %s of contract %s does not match the document.", dt.Code, UID)
+	}
+
+	bp, err := bpStore.Get(stub, UID)
+	if err != nil {
+		return err
+	}
+	if bp == nil {
+		return newError(codeNotFound, "Failed retrieving row with contract ID %s", UID)
+	}
+	role, party, err := signerOf(stub, bp, []byte(certificate))
+	if err != nil {
+		return err
+	}
+	if role == "" && party == "" {
+		return newError(codeValidationFailed, "Signer of %s of contract %s is neither a participant of the contract nor a registered party.", dt.Code, UID)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	ok, err = signatureStore.Insert(stub, &DocumentSignature{UID: UID, DocType: dt.Code, Certificate: []byte(certificate), Signature: signature, SignedAt: now})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newError(codeDuplicate, "%s of contract %s is already signed.", dt.Code, UID)
+	}
+
+	return nil
+}
+
+// signatureArg decodes a base64 signature argument
+func signatureArg(arg string) ([]byte, error) {
+	signature, err := base64.StdEncoding.DecodeString(arg)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Signature is not valid base64: %s", err.Error())
+	}
+
+	return signature, nil
+}
+
+// signDocuments verifies and stores the signatures passed to submitED as a JSON array
+func signDocuments(stub shim.ChaincodeStubInterface, UID string, signatures string) error {
+	var docs []SignedDocument
+	err := json.Unmarshal([]byte(signatures), &docs)
+	if err != nil {
+		return newError(codeInvalidArgs, "Signatures are not a valid JSON array of signed documents: %s", err.Error())
+	}
+
+	for _, doc := range docs {
+		err = signDocument(stub, UID, doc.DocType, doc.Signature, doc.Certificate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// certificateSubject returns the subject of an X.509 certificate in PEM or DER, or "" if it is not one
+func certificateSubject(certificate []byte) string {
+	der := certificate
+	if block, _ := pem.Decode(certificate); block != nil {
+		der = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ""
+	}
+
+	return cert.Subject.String()
+}
+
+// signerRole returns the role on the contract of the holder of certificate, or "" if they have none
+func signerRole(bp *BusinessProcess, certificate []byte) string {
+	for _, role := range []struct {
+		name string
+		cert []byte
+	}{
+		{"EXPORTER", bp.ExporterCert},
+		{"EXPORTER_BANK", bp.ExporterBankCert},
+		{"IMPORTER", bp.ImporterCert},
+		{"IMPORTER_BANK", bp.ImporterBankCert},
+		{"CONFIRMING_BANK", bp.ConfirmingBankCert},
+		{"REIMBURSING_BANK", bp.ReimbursingBankCert},
+	} {
+		if len(role.cert) != 0 && bytes.Equal(role.cert, certificate) {
+			return role.name
+		}
+	}
+
+	return ""
+}
+
+// signerOf returns the role on the contract of the holder of certificate and the ID of the registered party
+// holding it. Anyone can make a certificate, so the holder of one with neither is not trusted.
+func signerOf(stub shim.ChaincodeStubInterface, bp *BusinessProcess, certificate []byte) (string, string, error) {
+	parties, err := partyStore.List(stub)
+	if err != nil {
+		return "", "", err
+	}
+
+	var party string
+	for _, p := range parties {
+		if bytes.Equal(p.Cert, certificate) {
+			party = p.ID
+			break
+		}
+	}
+
+	return signerRole(bp, certificate), party, nil
+}
+
+// verifyDocumentSignature checks the signature of a document of the contract against its content on the ledger
+func (t *SBI) verifyDocumentSignature(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	contractID := args[0]
+
+	dt, ok := lookupDocumentType(args[1])
+	if !ok {
+		return nil, documentTypeError(args[1])
+	}
+
+	doc, err := dt.Store.Get(stub, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, newError(codeNotFound, "%s of contract %s does not exist.", dt.Code, contractID)
+	}
+
+	result := SignatureVerification{ContractID: contractID, DocType: dt.Code}
+
+	sig, err := signatureStore.Get(stub, dt.Code, contractID)
+	if err != nil {
+		return nil, err
+	}
+	if sig != nil {
+		bp, err := t.getContract(stub, contractID)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Signer, result.Party, err = signerOf(stub, bp, sig.Certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Signed = true
+		result.Trusted = result.Signer != "" || result.Party != ""
+		result.Valid, _ = verifyDetached(stub, sig.Certificate, sig.Signature, doc.Content)
+		result.Valid = result.Valid && result.Trusted
+		result.Subject = certificateSubject(sig.Certificate)
+		result.Certificate = sig.Certificate
+		result.SignedAt = sig.SignedAt
+	}
+
+	return json.Marshal(result)
+}
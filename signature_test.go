@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestDocumentSignatures(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+	carrierCert := []byte(`CarrierCert`)
+
+	if err := initTrade(stub, adminCert, "1000", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Signers who are not participants of the contract are trusted once registered
+	for _, party := range [][]string{{"CARRIER", "Carrier", "CarrierCert"}, {"CHAMBER", "Chamber of commerce", "ChamberCert"}} {
+		if _, err := stub.invoke([]byte(party[2]), "registerParty", party...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signatures := func(blSignature []byte) string {
+		b, _ := json.Marshal([]SignedDocument{
+			{DocType: "BL", Signature: blSignature, Certificate: string(carrierCert)},
+			{DocType: "INVOICE", Signature: mockSign([]byte(`ECert`), []byte(`InvoicePDF`)), Certificate: "ECert"},
+		})
+		return string(b)
+	}
+
+	// A signature that does not match its document rejects the whole submission
+	if _, err := stub.invoke(adminCert, "submitED", "1000", "BLPDF", "InvoicePDF", "PLPDF", "", signatures(mockSign(carrierCert, []byte(`Other`)))); errorCode(err) != codeValidationFailed {
+		t.Fatalf("submitED with a forged signature: %v", err)
+	}
+	if b, _ := stub.query(nil, "getED", "1000", "BL"); len(b) != 0 {
+		t.Fatalf("BL kept after a failed submission: %s", b)
+	}
+
+	if _, err := stub.invoke(adminCert, "submitED", "1000", "BLPDF", "InvoicePDF", "PLPDF", "", signatures(mockSign(carrierCert, []byte(`BLPDF`)))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stub.invoke(adminCert, "submitDocument", "1000", "CERT_OF_ORIGIN", "COOPDF", "", "ChamberCert"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("submitDocument with a certificate but no signature: %v", err)
+	}
+	selfMade := base64.StdEncoding.EncodeToString(mockSign([]byte(`SelfMadeCert`), []byte(`COOPDF`)))
+	if _, err := stub.invoke(adminCert, "submitDocument", "1000", "CERT_OF_ORIGIN", "COOPDF", selfMade, "SelfMadeCert"); errorCode(err) != codeValidationFailed {
+		t.Fatalf("submitDocument signed with an unknown certificate: %v", err)
+	}
+	signature := base64.StdEncoding.EncodeToString(mockSign([]byte(`ChamberCert`), []byte(`COOPDF`)))
+	if _, err := stub.invoke(adminCert, "submitDocument", "1000", "CERT_OF_ORIGIN", "COOPDF", signature, "ChamberCert"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A signature stored before signers were checked matches its document but is not trusted
+	if _, err := signatureStore.Insert(stub, &DocumentSignature{UID: "1000", DocType: "PACKINGLIST", Certificate: []byte(`SelfMadeCert`), Signature: mockSign([]byte(`SelfMadeCert`), []byte(`PLPDF`))}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		docType string
+		want    SignatureVerification
+	}{
+		{"BL", SignatureVerification{ContractID: "1000", DocType: "BL", Signed: true, Valid: true, Trusted: true, Party: "CARRIER", Certificate: carrierCert}},
+		{"INVOICE", SignatureVerification{ContractID: "1000", DocType: "INVOICE", Signed: true, Valid: true, Trusted: true, Signer: "EXPORTER", Certificate: []byte(`ECert`)}},
+		{"CERT_OF_ORIGIN", SignatureVerification{ContractID: "1000", DocType: "CERT_OF_ORIGIN", Signed: true, Valid: true, Trusted: true, Party: "CHAMBER", Certificate: []byte(`ChamberCert`)}},
+		{"PACKINGLIST", SignatureVerification{ContractID: "1000", DocType: "PACKINGLIST", Signed: true, Certificate: []byte(`SelfMadeCert`)}},
+	} {
+		b, err := stub.query(nil, "verifyDocumentSignature", "1000", test.docType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got SignatureVerification
+		if err = json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		got.SignedAt = ""
+		want, _ := json.Marshal(test.want)
+		if b, _ = json.Marshal(got); string(b) != string(want) {
+			t.Errorf("verifyDocumentSignature of %s = %s, want %s", test.docType, b, want)
+		}
+	}
+
+	if _, err := stub.query(nil, "verifyDocumentSignature", "1000", "INSPECTION_CERT"); errorCode(err) != codeNotFound {
+		t.Fatalf("verifyDocumentSignature of a document left out: %v", err)
+	}
+}
@@ -0,0 +1,924 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// keySeparator separates the parts of a composite key such as BP~UID or DOC~BL~UID
+const keySeparator = "~"
+
+// Stores of the chaincode. They are the only code touching the world state.
+var (
+	bpStore      = BusinessProcessStore{}
+	consentStore = ConsentStore{}
+	poStore      = DocumentStore{DocType: "PO"}
+	blStore      = DocumentStore{DocType: "BL"}
+	invoiceStore = DocumentStore{DocType: "INVOICE"}
+	plStore      = DocumentStore{DocType: "PACKINGLIST"}
+	requestStore = RequestStore{}
+	counterStore = CounterStore{}
+	schemaStore  = SchemaStore{}
+
+	invoiceDetailsStore = InvoiceDetailsStore{}
+	partyStore          = PartyStore{}
+	eblStore            = EBLStore{}
+	checklistStore      = ChecklistStore{}
+	signatureStore      = DocumentSignatureStore{}
+	complianceStore     = ComplianceStore{}
+	confirmationStore   = ConfirmationStore{}
+	reimbursementStore  = ReimbursementStore{}
+	settlementStore     = SettlementStore{}
+	adminStore          = AdminStore{}
+	amendmentStore      = AmendmentStore{}
+)
+
+// compositeKey joins the parts of a key. The parts may not contain the separator.
+func compositeKey(parts ...string) (string, error) {
+	for _, part := range parts {
+		if part == "" {
+			return "", newError(codeInvalidArgs, "Key parts can not be empty.")
+		}
+		if strings.Contains(part, keySeparator) {
+			return "", newError(codeInvalidArgs, "%s can not contain %s.", part, keySeparator)
+		}
+	}
+
+	return strings.Join(parts, keySeparator), nil
+}
+
+// getJSON reads the value stored at key into v. It returns false if the key does not exist.
+func getJSON(stub shim.ChaincodeStubInterface, key string, v interface{}) (bool, error) {
+	b, err := stub.GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("Failed retrieving %s. Error %s", key, err.Error())
+	}
+
+	// GetState returns nil if key does not exist
+	if len(b) == 0 {
+		return false, nil
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return false, fmt.Errorf("Failed decoding %s. Error %s", key, err.Error())
+	}
+
+	return true, nil
+}
+
+// putJSON stores v as JSON at key
+func putJSON(stub shim.ChaincodeStubInterface, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(key, b)
+}
+
+// insertJSON stores v at key unless the key already exists. It returns false if it does.
+func insertJSON(stub shim.ChaincodeStubInterface, key string, v interface{}) (bool, error) {
+	b, err := stub.GetState(key)
+	if err != nil {
+		return false, err
+	}
+	if len(b) != 0 {
+		return false, nil
+	}
+
+	return true, putJSON(stub, key, v)
+}
+
+// replaceJSON stores v at key if the key already exists. It returns false if it does not.
+func replaceJSON(stub shim.ChaincodeStubInterface, key string, v interface{}) (bool, error) {
+	b, err := stub.GetState(key)
+	if err != nil {
+		return false, err
+	}
+	if len(b) == 0 {
+		return false, nil
+	}
+
+	return true, putJSON(stub, key, v)
+}
+
+// rangeJSON calls fn with the value of every key starting with the given parts, in key order
+func rangeJSON(stub shim.ChaincodeStubInterface, fn func(key string, value []byte) error, parts ...string) error {
+	prefix, err := compositeKey(parts...)
+	if err != nil {
+		return err
+	}
+	prefix += keySeparator
+
+	// No key part sorts after the largest rune, so the range covers every key with the prefix
+	iter, err := stub.RangeQueryState(prefix, prefix+string(utf8.MaxRune))
+	if err != nil {
+		return fmt.Errorf("Failed querying %s. Error %s", prefix, err.Error())
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		err = fn(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BusinessProcess is a contract as stored under BP~UID
+type BusinessProcess struct {
+	UID              string
+	Status           string
+	ImporterName     string
+	ExporterName     string
+	ImporterBankName string
+	ExporterBankName string
+	ImporterCert     []byte
+	ExporterCert     []byte
+	ImporterBankCert []byte
+	ExporterBankCert []byte
+	LastUpdated      string
+
+	// Optional participants
+	ConfirmingBankName  string
+	ConfirmingBankCert  []byte
+	ReimbursingBankName string
+	ReimbursingBankCert []byte
+}
+
+// BusinessProcessStore stores BusinessProcess under BP~UID
+type BusinessProcessStore struct{}
+
+// Get returns the contract with the given UID, or nil if it does not exist
+func (s BusinessProcessStore) Get(stub shim.ChaincodeStubInterface, UID string) (*BusinessProcess, error) {
+	key, err := compositeKey("BP", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var bp BusinessProcess
+	ok, err := getJSON(stub, key, &bp)
+	if err != nil {
+		return nil, newError(codeInternal, "Failed retrieving row with contract ID %s. Error %s", UID, err.Error())
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &bp, nil
+}
+
+// Insert stores a new contract. It returns false if the contract already exists.
+func (s BusinessProcessStore) Insert(stub shim.ChaincodeStubInterface, bp *BusinessProcess) (bool, error) {
+	key, err := compositeKey("BP", bp.UID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, bp)
+}
+
+// Replace updates an existing contract
+func (s BusinessProcessStore) Replace(stub shim.ChaincodeStubInterface, bp *BusinessProcess) error {
+	key, err := compositeKey("BP", bp.UID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := replaceJSON(stub, key, bp)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newError(codeNotFound, "Failed updating row with contract ID %s", bp.UID)
+	}
+
+	return nil
+}
+
+// List returns all the contracts
+func (s BusinessProcessStore) List(stub shim.ChaincodeStubInterface) ([]*BusinessProcess, error) {
+	bps := make([]*BusinessProcess, 0)
+
+	err := rangeJSON(stub, func(key string, value []byte) error {
+		var bp BusinessProcess
+		err := json.Unmarshal(value, &bp)
+		if err != nil {
+			return fmt.Errorf("Failed decoding %s. Error %s", key, err.Error())
+		}
+		bps = append(bps, &bp)
+		return nil
+	}, "BP")
+	if err != nil {
+		return nil, err
+	}
+
+	return bps, nil
+}
+
+// Document is an export document or a PO as stored under DOC~type~UID
+type Document struct {
+	UID     string
+	Content []byte
+	Status  string
+}
+
+// DocumentStore stores the documents of one type under DOC~type~UID
+type DocumentStore struct {
+	DocType string
+}
+
+// key returns the key of the document of the contract with the given UID
+func (s DocumentStore) key(UID string) (string, error) {
+	return compositeKey("DOC", s.DocType, UID)
+}
+
+// Get returns the document of the contract with the given UID, or nil if it does not exist
+func (s DocumentStore) Get(stub shim.ChaincodeStubInterface, UID string) (*Document, error) {
+	key, err := s.key(UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc Document
+	ok, err := getJSON(stub, key, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("Error: Failed retrieving document with UID %s. Error %s", UID, err.Error())
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &doc, nil
+}
+
+// Insert stores a new document. It returns false if the document already exists.
+func (s DocumentStore) Insert(stub shim.ChaincodeStubInterface, doc *Document) (bool, error) {
+	key, err := s.key(doc.UID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, doc)
+}
+
+// Replace updates an existing document
+func (s DocumentStore) Replace(stub shim.ChaincodeStubInterface, doc *Document) error {
+	key, err := s.key(doc.UID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := replaceJSON(stub, key, doc)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newError(codeNotFound, "Document unable to Update.")
+	}
+
+	return nil
+}
+
+// Consent is the agreement of a party of a contract to a multi-party action such as a cancellation
+type Consent struct {
+	Action      string
+	UID         string
+	Role        string
+	ConsentedAt string
+}
+
+// ConsentStore stores Consent under CONSENT~action~UID~role
+type ConsentStore struct{}
+
+// Insert stores a new consent. It returns false if the role already consented to the action.
+func (s ConsentStore) Insert(stub shim.ChaincodeStubInterface, consent *Consent) (bool, error) {
+	key, err := compositeKey("CONSENT", consent.Action, consent.UID, consent.Role)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, consent)
+}
+
+// List returns the consents given to the action on the contract with the given UID
+func (s ConsentStore) List(stub shim.ChaincodeStubInterface, action string, UID string) ([]*Consent, error) {
+	consents := make([]*Consent, 0)
+
+	err := rangeJSON(stub, func(key string, value []byte) error {
+		var consent Consent
+		err := json.Unmarshal(value, &consent)
+		if err != nil {
+			return fmt.Errorf("Failed decoding %s. Error %s", key, err.Error())
+		}
+		consents = append(consents, &consent)
+		return nil
+	}, "CONSENT", action, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+// RequestRecord is a processed invoke request as stored under REQ~CONTRACT~ContractID~RequestID, or
+// REQ~LEDGER~RequestID for a request acting on no contract
+type RequestRecord struct {
+	RequestID   string
+	ContractID  string
+	Function    string
+	ArgsHash    string
+	Result      []byte
+	ProcessedAt string
+}
+
+// RequestStore stores RequestRecord under REQ~CONTRACT~ContractID~RequestID or REQ~LEDGER~RequestID
+type RequestStore struct{}
+
+// requestKey returns the key of a request. Request IDs are unique within the contract the request acts on.
+func requestKey(contractID string, requestID string) (string, error) {
+	if contractID == "" {
+		return compositeKey("REQ", "LEDGER", requestID)
+	}
+
+	return compositeKey("REQ", "CONTRACT", contractID, requestID)
+}
+
+// Get returns the record of the request with the given ID on the contract, or nil if it was not processed
+func (s RequestStore) Get(stub shim.ChaincodeStubInterface, contractID string, requestID string) (*RequestRecord, error) {
+	key, err := requestKey(contractID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var record RequestRecord
+	ok, err := getJSON(stub, key, &record)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &record, nil
+}
+
+// Insert records a processed request. It returns false if the request ID was already used on the contract.
+func (s RequestStore) Insert(stub shim.ChaincodeStubInterface, record *RequestRecord) (bool, error) {
+	key, err := requestKey(record.ContractID, record.RequestID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, record)
+}
+
+// Counter is a sequence stored under SEQ~name
+type Counter struct {
+	Last int
+}
+
+// CounterStore stores Counter under SEQ~name, where the name may have several parts
+type CounterStore struct{}
+
+// Next increments the counter with the given name and returns its new value. Counters start at 1.
+func (s CounterStore) Next(stub shim.ChaincodeStubInterface, name ...string) (int, error) {
+	key, err := compositeKey(append([]string{"SEQ"}, name...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	var counter Counter
+	_, err = getJSON(stub, key, &counter)
+	if err != nil {
+		return 0, err
+	}
+	counter.Last++
+
+	return counter.Last, putJSON(stub, key, &counter)
+}
+
+// SchemaVersion is the schema version of the world state as stored under SCHEMA
+type SchemaVersion struct {
+	Version    int
+	MigratedAt string
+}
+
+// SchemaStore stores the SchemaVersion under SCHEMA
+type SchemaStore struct{}
+
+// Get returns the schema version. Ledgers written before versioning are at version 0.
+func (s SchemaStore) Get(stub shim.ChaincodeStubInterface) (*SchemaVersion, error) {
+	var version SchemaVersion
+	_, err := getJSON(stub, "SCHEMA", &version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// Put records the schema version
+func (s SchemaStore) Put(stub shim.ChaincodeStubInterface, version *SchemaVersion) error {
+	return putJSON(stub, "SCHEMA", version)
+}
+
+// InvoiceDetails is an invoice submitted as UBL, as stored under INVOICEDATA~UID
+type InvoiceDetails struct {
+	UID     string
+	UBL     []byte
+	Invoice InvoiceData
+}
+
+// InvoiceDetailsStore stores InvoiceDetails under INVOICEDATA~UID
+type InvoiceDetailsStore struct{}
+
+// Get returns the UBL invoice of the contract with the given UID, or nil if it was not submitted as UBL
+func (s InvoiceDetailsStore) Get(stub shim.ChaincodeStubInterface, UID string) (*InvoiceDetails, error) {
+	key, err := compositeKey("INVOICEDATA", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var details InvoiceDetails
+	ok, err := getJSON(stub, key, &details)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &details, nil
+}
+
+// Insert stores a new UBL invoice. It returns false if the contract already has one.
+func (s InvoiceDetailsStore) Insert(stub shim.ChaincodeStubInterface, details *InvoiceDetails) (bool, error) {
+	key, err := compositeKey("INVOICEDATA", details.UID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, details)
+}
+
+// Party is a registered party that can hold the title of an electronic BL, as stored under PARTY~ID
+type Party struct {
+	ID           string
+	Name         string
+	Cert         []byte
+	RegisteredAt string
+}
+
+// PartyStore stores Party under PARTY~ID
+type PartyStore struct{}
+
+// Get returns the party with the given ID, or nil if it is not registered
+func (s PartyStore) Get(stub shim.ChaincodeStubInterface, ID string) (*Party, error) {
+	key, err := compositeKey("PARTY", ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var party Party
+	ok, err := getJSON(stub, key, &party)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &party, nil
+}
+
+// Insert registers a new party. It returns false if the ID is already registered.
+func (s PartyStore) Insert(stub shim.ChaincodeStubInterface, party *Party) (bool, error) {
+	key, err := compositeKey("PARTY", party.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, party)
+}
+
+// List returns all the registered parties
+func (s PartyStore) List(stub shim.ChaincodeStubInterface) ([]*Party, error) {
+	parties := make([]*Party, 0)
+
+	err := rangeJSON(stub, func(key string, value []byte) error {
+		var party Party
+		err := json.Unmarshal(value, &party)
+		if err != nil {
+			return fmt.Errorf("Failed decoding %s. Error %s", key, err.Error())
+		}
+		parties = append(parties, &party)
+		return nil
+	}, "PARTY")
+	if err != nil {
+		return nil, err
+	}
+
+	return parties, nil
+}
+
+// EBLStore stores the EBLTitle of a contract under EBL~UID
+type EBLStore struct{}
+
+// Get returns the title of the electronic BL of the contract, or nil if none was issued
+func (s EBLStore) Get(stub shim.ChaincodeStubInterface, UID string) (*EBLTitle, error) {
+	key, err := compositeKey("EBL", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var title EBLTitle
+	ok, err := getJSON(stub, key, &title)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &title, nil
+}
+
+// Put stores the title of the electronic BL of a contract
+func (s EBLStore) Put(stub shim.ChaincodeStubInterface, title *EBLTitle) error {
+	key, err := compositeKey("EBL", title.UID)
+	if err != nil {
+		return err
+	}
+
+	return putJSON(stub, key, title)
+}
+
+// Checklist is the list of documents required by the PO of a contract, as stored under CHECKLIST~UID
+type Checklist struct {
+	UID   string
+	Items []ChecklistItem
+}
+
+// ChecklistStore stores Checklist under CHECKLIST~UID
+type ChecklistStore struct{}
+
+// Get returns the checklist of the contract with the given UID, or nil if none was captured
+func (s ChecklistStore) Get(stub shim.ChaincodeStubInterface, UID string) (*Checklist, error) {
+	key, err := compositeKey("CHECKLIST", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var checklist Checklist
+	ok, err := getJSON(stub, key, &checklist)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &checklist, nil
+}
+
+// Put stores the checklist of a contract
+func (s ChecklistStore) Put(stub shim.ChaincodeStubInterface, checklist *Checklist) error {
+	key, err := compositeKey("CHECKLIST", checklist.UID)
+	if err != nil {
+		return err
+	}
+
+	return putJSON(stub, key, checklist)
+}
+
+// DocumentSignature is the detached signature of an export document
+type DocumentSignature struct {
+	UID         string
+	DocType     string
+	Certificate []byte
+	Signature   []byte
+	SignedAt    string
+}
+
+// DocumentSignatureStore stores DocumentSignature under DOCSIG~type~UID
+type DocumentSignatureStore struct{}
+
+// Get returns the signature of the document of the given type of the contract, or nil if it is not signed
+func (s DocumentSignatureStore) Get(stub shim.ChaincodeStubInterface, docType string, UID string) (*DocumentSignature, error) {
+	key, err := compositeKey("DOCSIG", docType, UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig DocumentSignature
+	ok, err := getJSON(stub, key, &sig)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &sig, nil
+}
+
+// Insert stores a new signature. It returns false if the document is already signed.
+func (s DocumentSignatureStore) Insert(stub shim.ChaincodeStubInterface, sig *DocumentSignature) (bool, error) {
+	key, err := compositeKey("DOCSIG", sig.DocType, sig.UID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, sig)
+}
+
+// ComplianceConfig holds the certificate of the compliance admin
+type ComplianceConfig struct {
+	AdminCert []byte
+}
+
+// SanctionsList is the watch list the contracts are screened against
+type SanctionsList struct {
+	Version   int              `json:"version"`
+	Entries   []WatchListEntry `json:"entries"`
+	UpdatedAt string           `json:"updatedAt"`
+}
+
+// ComplianceStore stores ComplianceConfig under COMPLIANCE, SanctionsList under SANCTIONS
+// and ComplianceHold under HOLD~UID
+type ComplianceStore struct{}
+
+// GetConfig returns the compliance configuration, empty if no admin was given
+func (s ComplianceStore) GetConfig(stub shim.ChaincodeStubInterface) (*ComplianceConfig, error) {
+	var config ComplianceConfig
+	_, err := getJSON(stub, "COMPLIANCE", &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// PutConfig stores the compliance configuration
+func (s ComplianceStore) PutConfig(stub shim.ChaincodeStubInterface, config *ComplianceConfig) error {
+	return putJSON(stub, "COMPLIANCE", config)
+}
+
+// GetList returns the sanctions list, empty if none was loaded
+func (s ComplianceStore) GetList(stub shim.ChaincodeStubInterface) (*SanctionsList, error) {
+	var list SanctionsList
+	_, err := getJSON(stub, "SANCTIONS", &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// PutList stores the sanctions list
+func (s ComplianceStore) PutList(stub shim.ChaincodeStubInterface, list *SanctionsList) error {
+	return putJSON(stub, "SANCTIONS", list)
+}
+
+// GetHold returns the screening outcome of the contract, or nil if it was never held
+func (s ComplianceStore) GetHold(stub shim.ChaincodeStubInterface, UID string) (*ComplianceHold, error) {
+	key, err := compositeKey("HOLD", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var hold ComplianceHold
+	ok, err := getJSON(stub, key, &hold)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &hold, nil
+}
+
+// PutHold stores the screening outcome of a contract
+func (s ComplianceStore) PutHold(stub shim.ChaincodeStubInterface, hold *ComplianceHold) error {
+	key, err := compositeKey("HOLD", hold.UID)
+	if err != nil {
+		return err
+	}
+
+	return putJSON(stub, key, hold)
+}
+
+// ConfirmationStore stores Confirmation under CONFIRM~UID
+type ConfirmationStore struct{}
+
+// Get returns the confirmation of the LC of the contract, or nil if it is not confirmed
+func (s ConfirmationStore) Get(stub shim.ChaincodeStubInterface, UID string) (*Confirmation, error) {
+	key, err := compositeKey("CONFIRM", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var confirmation Confirmation
+	ok, err := getJSON(stub, key, &confirmation)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &confirmation, nil
+}
+
+// Insert stores a new confirmation. It returns false if the LC is already confirmed.
+func (s ConfirmationStore) Insert(stub shim.ChaincodeStubInterface, confirmation *Confirmation) (bool, error) {
+	key, err := compositeKey("CONFIRM", confirmation.UID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, confirmation)
+}
+
+// sequenceKey formats a sequence number so that the keys sort in sequence order
+func sequenceKey(sequence int) string {
+	return fmt.Sprintf("%06d", sequence)
+}
+
+// ReimbursementStore stores ReimbursementAuthorisation under REIMB~UID and ReimbursementClaim under REIMBCLAIM~UID~sequence
+type ReimbursementStore struct{}
+
+// GetAuthorisation returns the reimbursement authorisation of the contract, or nil if there is none
+func (s ReimbursementStore) GetAuthorisation(stub shim.ChaincodeStubInterface, UID string) (*ReimbursementAuthorisation, error) {
+	key, err := compositeKey("REIMB", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var authorisation ReimbursementAuthorisation
+	ok, err := getJSON(stub, key, &authorisation)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &authorisation, nil
+}
+
+// InsertAuthorisation stores a new reimbursement authorisation. It returns false if the contract already has one.
+func (s ReimbursementStore) InsertAuthorisation(stub shim.ChaincodeStubInterface, authorisation *ReimbursementAuthorisation) (bool, error) {
+	key, err := compositeKey("REIMB", authorisation.UID)
+	if err != nil {
+		return false, err
+	}
+
+	return insertJSON(stub, key, authorisation)
+}
+
+// ListClaims returns the reimbursement claims of the contract in sequence order
+func (s ReimbursementStore) ListClaims(stub shim.ChaincodeStubInterface, UID string) ([]ReimbursementClaim, error) {
+	claims := make([]ReimbursementClaim, 0)
+
+	err := rangeJSON(stub, func(key string, value []byte) error {
+		var claim ReimbursementClaim
+		err := json.Unmarshal(value, &claim)
+		if err != nil {
+			return fmt.Errorf("Failed decoding %s. Error %s", key, err.Error())
+		}
+		claims = append(claims, claim)
+		return nil
+	}, "REIMBCLAIM", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// PutClaim stores a reimbursement claim
+func (s ReimbursementStore) PutClaim(stub shim.ChaincodeStubInterface, claim *ReimbursementClaim) error {
+	key, err := compositeKey("REIMBCLAIM", claim.UID, sequenceKey(claim.Sequence))
+	if err != nil {
+		return err
+	}
+
+	return putJSON(stub, key, claim)
+}
+
+// SettlementStore stores SettlementEntry under SETTLE~UID~sequence
+type SettlementStore struct{}
+
+// List returns the settlement entries of the contract in sequence order
+func (s SettlementStore) List(stub shim.ChaincodeStubInterface, UID string) ([]SettlementEntry, error) {
+	entries := make([]SettlementEntry, 0)
+
+	err := rangeJSON(stub, func(key string, value []byte) error {
+		var entry SettlementEntry
+		err := json.Unmarshal(value, &entry)
+		if err != nil {
+			return fmt.Errorf("Failed decoding %s. Error %s", key, err.Error())
+		}
+		entries = append(entries, entry)
+		return nil
+	}, "SETTLE", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Put stores a settlement entry
+func (s SettlementStore) Put(stub shim.ChaincodeStubInterface, entry *SettlementEntry) error {
+	key, err := compositeKey("SETTLE", entry.UID, sequenceKey(entry.Sequence))
+	if err != nil {
+		return err
+	}
+
+	return putJSON(stub, key, entry)
+}
+
+// Admin holds the certificate of the deployer of the chaincode
+type Admin struct {
+	Cert []byte
+}
+
+// AdminStore stores Admin under ADMIN
+type AdminStore struct{}
+
+// Get returns the admin, or nil if the chaincode was deployed without caller metadata
+func (s AdminStore) Get(stub shim.ChaincodeStubInterface) (*Admin, error) {
+	var admin Admin
+	ok, err := getJSON(stub, "ADMIN", &admin)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &admin, nil
+}
+
+// Put records the admin
+func (s AdminStore) Put(stub shim.ChaincodeStubInterface, admin *Admin) error {
+	return putJSON(stub, "ADMIN", admin)
+}
+
+// Amendments is the amendment history of the PO of a contract, as stored under AMENDMENTS~UID
+type Amendments struct {
+	UID       string
+	Issued    []byte // PO as issued, before the first amendment
+	Version   int    // 1 for the PO as issued, incremented by every amendment
+	AmendedAt string
+}
+
+// AmendmentStore stores Amendments under AMENDMENTS~UID
+type AmendmentStore struct{}
+
+// Get returns the amendment history of the contract with the given UID, or nil if its PO was never amended
+func (s AmendmentStore) Get(stub shim.ChaincodeStubInterface, UID string) (*Amendments, error) {
+	key, err := compositeKey("AMENDMENTS", UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var amendments Amendments
+	ok, err := getJSON(stub, key, &amendments)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &amendments, nil
+}
+
+// Put stores the amendment history of a contract
+func (s AmendmentStore) Put(stub shim.ChaincodeStubInterface, amendments *Amendments) error {
+	key, err := compositeKey("AMENDMENTS", amendments.UID)
+	if err != nil {
+		return err
+	}
+
+	return putJSON(stub, key, amendments)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// nsUBLInvoice is the namespace of the root element of a UBL 2.1 invoice
+const nsUBLInvoice = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+
+// Formats of an export document returned by getED
+const (
+	formatPDF = "PDF"
+	formatUBL = "UBL"
+)
+
+// InvoiceData is the structured content of an invoice submitted as UBL
+type InvoiceData struct {
+	ID                  string        `json:"id"`
+	IssueDate           string        `json:"issueDate"`
+	DueDate             string        `json:"dueDate,omitempty"`
+	Currency            string        `json:"currency"`
+	Seller              InvoiceParty  `json:"seller"`
+	Buyer               InvoiceParty  `json:"buyer"`
+	Lines               []InvoiceLine `json:"lines"`
+	LineExtensionAmount string        `json:"lineExtensionAmount"`
+	TaxExclusiveAmount  string        `json:"taxExclusiveAmount,omitempty"`
+	TaxAmount           string        `json:"taxAmount,omitempty"`
+	TaxInclusiveAmount  string        `json:"taxInclusiveAmount,omitempty"`
+	PayableAmount       string        `json:"payableAmount"`
+}
+
+// InvoiceParty is the seller or the buyer of an invoice
+type InvoiceParty struct {
+	Name    string `json:"name"`
+	TaxID   string `json:"taxID,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// InvoiceLine is a line item of an invoice
+type InvoiceLine struct {
+	ID       string `json:"id"`
+	Item     string `json:"item"`
+	Quantity string `json:"quantity"`
+	UnitCode string `json:"unitCode,omitempty"`
+	Price    string `json:"price,omitempty"`
+	Amount   string `json:"amount"`
+}
+
+// ublInvoice is the subset of a UBL 2.1 invoice read by the chaincode. Elements are matched by local
+// name, only the namespace of the root is checked.
+type ublInvoice struct {
+	XMLName      xml.Name
+	UBLVersionID string      `xml:"UBLVersionID"`
+	ID           string      `xml:"ID"`
+	IssueDate    string      `xml:"IssueDate"`
+	DueDate      string      `xml:"DueDate"`
+	Currency     string      `xml:"DocumentCurrencyCode"`
+	Seller       ublParty    `xml:"AccountingSupplierParty>Party"`
+	Buyer        ublParty    `xml:"AccountingCustomerParty>Party"`
+	TaxAmounts   []ublAmount `xml:"TaxTotal>TaxAmount"`
+	Totals       struct {
+		LineExtension ublAmount `xml:"LineExtensionAmount"`
+		TaxExclusive  ublAmount `xml:"TaxExclusiveAmount"`
+		TaxInclusive  ublAmount `xml:"TaxInclusiveAmount"`
+		Payable       ublAmount `xml:"PayableAmount"`
+	} `xml:"LegalMonetaryTotal"`
+	Lines []ublLine `xml:"InvoiceLine"`
+}
+
+type ublParty struct {
+	Name             string `xml:"PartyName>Name"`
+	RegistrationName string `xml:"PartyLegalEntity>RegistrationName"`
+	TaxID            string `xml:"PartyTaxScheme>CompanyID"`
+	Country          string `xml:"PostalAddress>Country>IdentificationCode"`
+}
+
+type ublAmount struct {
+	Currency string `xml:"currencyID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ublQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ublLine struct {
+	ID            string      `xml:"ID"`
+	Quantity      ublQuantity `xml:"InvoicedQuantity"`
+	LineExtension ublAmount   `xml:"LineExtensionAmount"`
+	Item          string      `xml:"Item>Name"`
+	Price         ublAmount   `xml:"Price>PriceAmount"`
+}
+
+var (
+	decimalPattern  = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// ublRules collects the rules an invoice breaks
+type ublRules []string
+
+func (r *ublRules) check(ok bool, problem string) {
+	if !ok {
+		*r = append(*r, problem)
+	}
+}
+
+// amount checks an amount of the invoice and returns its value, or nil if it is missing or invalid
+func (r *ublRules) amount(name string, a ublAmount, currency string, required bool) *big.Rat {
+	value := strings.TrimSpace(a.Value)
+	if value == "" {
+		r.check(!required, name+" is missing")
+		return nil
+	}
+	if !decimalPattern.MatchString(value) {
+		r.check(false, name+" is not a decimal number")
+		return nil
+	}
+	r.check(a.Currency == currency, name+" is not in the document currency")
+
+	rat, _ := new(big.Rat).SetString(value)
+	return rat
+}
+
+// isDate reports whether s is a YYYY-MM-DD date
+func isDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// partyName returns the trading name of a party, or its registration name
+func (p ublParty) partyName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.RegistrationName
+}
+
+// parseUBLInvoice reads a UBL 2.1 invoice and checks the core rules: the mandatory elements are
+// present, amounts are in the document currency and the totals add up.
+func parseUBLInvoice(msg string) (*InvoiceData, error) {
+	var inv ublInvoice
+	err := xml.Unmarshal([]byte(msg), &inv)
+	if err != nil {
+		return nil, newError(codeInvalidArgs, "Invalid UBL invoice: %s", err.Error())
+	}
+	if inv.XMLName.Space != nsUBLInvoice || inv.XMLName.Local != "Invoice" {
+		return nil, newError(codeInvalidArgs, "Document %s %s is not a UBL invoice.", inv.XMLName.Space, inv.XMLName.Local)
+	}
+
+	var rules ublRules
+	rules.check(inv.UBLVersionID == "" || inv.UBLVersionID == "2.1", "UBLVersionID should be 2.1")
+	rules.check(inv.ID != "", "ID is missing")
+	rules.check(isDate(inv.IssueDate), "IssueDate should be a YYYY-MM-DD date")
+	rules.check(inv.DueDate == "" || isDate(inv.DueDate), "DueDate should be a YYYY-MM-DD date")
+	rules.check(currencyPattern.MatchString(inv.Currency), "DocumentCurrencyCode should be an ISO 4217 code")
+	rules.check(inv.Seller.partyName() != "", "AccountingSupplierParty has no name")
+	rules.check(inv.Buyer.partyName() != "", "AccountingCustomerParty has no name")
+	rules.check(len(inv.Lines) != 0, "InvoiceLine is missing")
+
+	data := &InvoiceData{
+		ID:        inv.ID,
+		IssueDate: inv.IssueDate,
+		DueDate:   inv.DueDate,
+		Currency:  inv.Currency,
+		Seller:    InvoiceParty{Name: inv.Seller.partyName(), TaxID: inv.Seller.TaxID, Country: inv.Seller.Country},
+		Buyer:     InvoiceParty{Name: inv.Buyer.partyName(), TaxID: inv.Buyer.TaxID, Country: inv.Buyer.Country},
+		Lines:     make([]InvoiceLine, 0, len(inv.Lines)),
+	}
+
+	sum := new(big.Rat)
+	for _, line := range inv.Lines {
+		name := "InvoiceLine " + line.ID
+		rules.check(line.ID != "", "InvoiceLine has no ID")
+		rules.check(line.Item != "", name+" has no Item Name")
+		rules.check(decimalPattern.MatchString(strings.TrimSpace(line.Quantity.Value)), name+" InvoicedQuantity is not a decimal number")
+		rules.amount(name+" PriceAmount", line.Price, inv.Currency, false)
+		if amount := rules.amount(name+" LineExtensionAmount", line.LineExtension, inv.Currency, true); amount != nil {
+			sum.Add(sum, amount)
+		}
+
+		data.Lines = append(data.Lines, InvoiceLine{
+			ID:       line.ID,
+			Item:     line.Item,
+			Quantity: strings.TrimSpace(line.Quantity.Value),
+			UnitCode: line.Quantity.UnitCode,
+			Price:    strings.TrimSpace(line.Price.Value),
+			Amount:   strings.TrimSpace(line.LineExtension.Value),
+		})
+	}
+
+	lineTotal := rules.amount("LineExtensionAmount", inv.Totals.LineExtension, inv.Currency, true)
+	taxExclusive := rules.amount("TaxExclusiveAmount", inv.Totals.TaxExclusive, inv.Currency, false)
+	taxInclusive := rules.amount("TaxInclusiveAmount", inv.Totals.TaxInclusive, inv.Currency, false)
+	rules.amount("PayableAmount", inv.Totals.Payable, inv.Currency, true)
+
+	if lineTotal != nil && len(inv.Lines) != 0 {
+		rules.check(lineTotal.Cmp(sum) == 0, "LineExtensionAmount is not the sum of the lines")
+	}
+
+	tax := new(big.Rat)
+	for _, taxAmount := range inv.TaxAmounts {
+		if amount := rules.amount("TaxAmount", taxAmount, inv.Currency, true); amount != nil {
+			tax.Add(tax, amount)
+		}
+	}
+	if taxExclusive != nil && taxInclusive != nil {
+		rules.check(new(big.Rat).Add(taxExclusive, tax).Cmp(taxInclusive) == 0, "TaxInclusiveAmount is not TaxExclusiveAmount plus TaxAmount")
+	}
+
+	if len(rules) != 0 {
+		return nil, newError(codeValidationFailed, "UBL invoice breaks the core rules: %s.", strings.Join(rules, "; "))
+	}
+
+	data.LineExtensionAmount = strings.TrimSpace(inv.Totals.LineExtension.Value)
+	data.TaxExclusiveAmount = strings.TrimSpace(inv.Totals.TaxExclusive.Value)
+	data.TaxInclusiveAmount = strings.TrimSpace(inv.Totals.TaxInclusive.Value)
+	data.PayableAmount = strings.TrimSpace(inv.Totals.Payable.Value)
+	if len(inv.TaxAmounts) != 0 {
+		data.TaxAmount = tax.FloatString(2)
+	}
+
+	return data, nil
+}
+
+// getInvoice returns the structured content of the invoice of the contract, submitted as UBL
+func (t *SBI) getInvoice(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	details, err := invoiceDetailsStore.Get(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, newError(codeNotFound, "Invoice of contract %s has not been submitted as UBL.", args[0])
+	}
+
+	return json.Marshal(details.Invoice)
+}
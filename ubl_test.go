@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testUBLInvoice = `<?xml version="1.0" encoding="UTF-8"?>
+<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	xmlns:cac="urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2">
+	<cbc:UBLVersionID>2.1</cbc:UBLVersionID>
+	<cbc:ID>INV-042</cbc:ID>
+	<cbc:IssueDate>2012-07-20</cbc:IssueDate>
+	<cbc:DueDate>2012-08-20</cbc:DueDate>
+	<cbc:DocumentCurrencyCode>USD</cbc:DocumentCurrencyCode>
+	<cac:AccountingSupplierParty><cac:Party>
+		<cac:PartyName><cbc:Name>Exporter GmbH</cbc:Name></cac:PartyName>
+		<cac:PostalAddress><cac:Country><cbc:IdentificationCode>DE</cbc:IdentificationCode></cac:Country></cac:PostalAddress>
+		<cac:PartyTaxScheme><cbc:CompanyID>DE123456789</cbc:CompanyID></cac:PartyTaxScheme>
+	</cac:Party></cac:AccountingSupplierParty>
+	<cac:AccountingCustomerParty><cac:Party>
+		<cac:PartyLegalEntity><cbc:RegistrationName>Importer Inc</cbc:RegistrationName></cac:PartyLegalEntity>
+	</cac:Party></cac:AccountingCustomerParty>
+	<cac:TaxTotal><cbc:TaxAmount currencyID="USD">0.00</cbc:TaxAmount></cac:TaxTotal>
+	<cac:LegalMonetaryTotal>
+		<cbc:LineExtensionAmount currencyID="USD">10000.00</cbc:LineExtensionAmount>
+		<cbc:TaxExclusiveAmount currencyID="USD">10000.00</cbc:TaxExclusiveAmount>
+		<cbc:TaxInclusiveAmount currencyID="USD">10000.00</cbc:TaxInclusiveAmount>
+		<cbc:PayableAmount currencyID="USD">10000.00</cbc:PayableAmount>
+	</cac:LegalMonetaryTotal>
+	<cac:InvoiceLine>
+		<cbc:ID>1</cbc:ID>
+		<cbc:InvoicedQuantity unitCode="C62">400</cbc:InvoicedQuantity>
+		<cbc:LineExtensionAmount currencyID="USD">8000.00</cbc:LineExtensionAmount>
+		<cac:Item><cbc:Name>Widget</cbc:Name></cac:Item>
+		<cac:Price><cbc:PriceAmount currencyID="USD">20.00</cbc:PriceAmount></cac:Price>
+	</cac:InvoiceLine>
+	<cac:InvoiceLine>
+		<cbc:ID>2</cbc:ID>
+		<cbc:InvoicedQuantity unitCode="C62">100</cbc:InvoicedQuantity>
+		<cbc:LineExtensionAmount currencyID="USD">2000.00</cbc:LineExtensionAmount>
+		<cac:Item><cbc:Name>Gadget</cbc:Name></cac:Item>
+	</cac:InvoiceLine>
+</Invoice>`
+
+func TestParseUBLInvoice(t *testing.T) {
+	inv, err := parseUBLInvoice(testUBLInvoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.ID != "INV-042" || inv.Currency != "USD" || inv.PayableAmount != "10000.00" || inv.TaxAmount != "0.00" ||
+		inv.Seller.Name != "Exporter GmbH" || inv.Seller.TaxID != "DE123456789" || inv.Seller.Country != "DE" || inv.Buyer.Name != "Importer Inc" ||
+		len(inv.Lines) != 2 || inv.Lines[0].Item != "Widget" || inv.Lines[0].Quantity != "400" || inv.Lines[0].UnitCode != "C62" {
+		t.Fatalf("parseUBLInvoice: %+v", inv)
+	}
+
+	for _, test := range []struct {
+		name    string
+		invoice string
+		code    string
+		rule    string
+	}{
+		{"wrong line total", strings.Replace(testUBLInvoice, ">2000.00<", ">2500.00<", 1), codeValidationFailed, "LineExtensionAmount is not the sum of the lines"},
+		{"wrong tax total", strings.Replace(testUBLInvoice, `<cbc:TaxAmount currencyID="USD">0.00`, `<cbc:TaxAmount currencyID="USD">19.00`, 1), codeValidationFailed, "TaxInclusiveAmount is not TaxExclusiveAmount plus TaxAmount"},
+		{"foreign currency", strings.Replace(testUBLInvoice, `<cbc:PayableAmount currencyID="USD">`, `<cbc:PayableAmount currencyID="EUR">`, 1), codeValidationFailed, "PayableAmount is not in the document currency"},
+		{"missing issue date", strings.Replace(testUBLInvoice, "<cbc:IssueDate>2012-07-20</cbc:IssueDate>", "", 1), codeValidationFailed, "IssueDate should be a YYYY-MM-DD date"},
+		{"nameless item", strings.Replace(testUBLInvoice, "<cbc:Name>Gadget</cbc:Name>", "", 1), codeValidationFailed, "InvoiceLine 2 has no Item Name"},
+		{"other version", strings.Replace(testUBLInvoice, ">2.1<", ">2.0<", 1), codeValidationFailed, "UBLVersionID should be 2.1"},
+		{"credit note", strings.Replace(testUBLInvoice, "xsd:Invoice-2", "xsd:CreditNote-2", 1), codeInvalidArgs, ""},
+		{"malformed XML", testUBLInvoice[:100], codeInvalidArgs, ""},
+	} {
+		_, err := parseUBLInvoice(test.invoice)
+		if errorCode(err) != test.code || !strings.Contains(err.Error(), test.rule) {
+			t.Errorf("parseUBLInvoice with %s: %v", test.name, err)
+		}
+	}
+}
+
+func TestSubmitUBLInvoice(t *testing.T) {
+	stub := newMockStub()
+	adminCert := []byte(`AdminCert`)
+
+	for _, contractID := range []string{"1000", "1001"} {
+		if err := initTrade(stub, adminCert, contractID, testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The UBL invoice with its PDF rendition
+	if _, err := stub.invoke(adminCert, "submitED", "1000", "BLPDF", "InvoicePDF", "PLPDF", testUBLInvoice); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := stub.query(nil, "getED", "1000", "INVOICE"); err != nil || string(b) != "InvoicePDF" {
+		t.Fatalf("getED of the PDF rendition: %s %v", b, err)
+	}
+	if b, err := stub.query(nil, "getED", `{"contractID": "1000", "docType": "INVOICE", "format": "UBL"}`); err != nil || string(b) != testUBLInvoice {
+		t.Fatalf("getED of the UBL invoice: %.40s %v", b, err)
+	}
+
+	var inv InvoiceData
+	b, err := stub.query(nil, "getInvoice", "1000")
+	if err = json.Unmarshal(b, &inv); err != nil || inv.LineExtensionAmount != "10000.00" || len(inv.Lines) != 2 {
+		t.Fatalf("getInvoice: %s %v", b, err)
+	}
+
+	// The UBL invoice alone
+	if _, err = stub.invoke(adminCert, "submitED", `{"contractID": "1001", "blPDF": "BLPDF", "invoiceUBL": `+jsonString(testUBLInvoice)+`}`); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = stub.query(nil, "getED", "1001", "INVOICE"); err != nil || string(b) != testUBLInvoice {
+		t.Fatalf("getED of an invoice submitted as UBL only: %.40s %v", b, err)
+	}
+
+	if _, err = stub.query(nil, "getED", "1000", "BL", "UBL"); errorCode(err) != codeInvalidArgs {
+		t.Fatalf("getED of a BL as UBL: %v", err)
+	}
+	if _, err = stub.query(nil, "getInvoice", "1002"); errorCode(err) != codeNotFound {
+		t.Fatalf("getInvoice of an unknown contract: %v", err)
+	}
+
+	// Invalid invoices are not stored
+	if err = initTrade(stub, adminCert, "1002", testPOJSON, "I", "E", "IB", "EB", []byte(`ICert`), []byte(`ECert`), []byte(`IBCert`), []byte(`EBCert`)); err != nil {
+		t.Fatal(err)
+	}
+	invalid := strings.Replace(testUBLInvoice, ">2000.00<", ">2500.00<", 1)
+	if _, err = stub.invoke(adminCert, "submitED", "1002", "BLPDF", "", "", invalid); errorCode(err) != codeValidationFailed {
+		t.Fatalf("submitED of an invalid UBL invoice: %v", err)
+	}
+	if _, err = stub.query(nil, "getED", "1002", "BL"); errorCode(err) != codeNotFound {
+		t.Fatalf("BL stored along an invalid invoice: %v", err)
+	}
+}
+
+// jsonString quotes s as a JSON string
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}